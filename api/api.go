@@ -5,18 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
-	gsr "github.com/shakirovformal/unu_project_api_realizer/pkg/google-sheet-reader"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
 )
 
-var spreadsheetId = os.Getenv("SPREADSHEETID")
-
 type Client struct {
 	client_url   string
 	client_token string
@@ -29,10 +27,16 @@ func NewClient(input_url, input_token string) *Client {
 	}
 }
 
-func (c Client) post(action string, params map[string]interface{}) string {
+// requestTimeout ограничивает запрос к UNU API, даже если вызывающий не задал срок в ctx
+const requestTimeout = time.Minute
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// post отправляет запрос к UNU API. Ключ API добавляется после записи запроса в лог и в лог не попадает.
+// При ошибке сети или отмене ctx возвращает пустой ответ, который вызывающий разберёт как ошибку JSON
+func (c Client) post(ctx context.Context, action string, params map[string]interface{}) string {
 	formData := url.Values{
-		"api_key": {c.client_token},
-		"action":  {action}}
+		"action": {action}}
 	for key, value := range params {
 		switch v := value.(type) {
 		case string:
@@ -50,44 +54,58 @@ func (c Client) post(action string, params map[string]interface{}) string {
 			formData.Add(key, fmt.Sprintf("%v", v))
 		}
 	}
-	resp, err := http.PostForm(os.Getenv("URL_UNU"), formData)
+	slog.DebugContext(ctx, "Запрос к UNU API", "ACTION", action, "PARAMS", formData.Encode())
+	formData.Set("api_key", c.client_token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.client_url, strings.NewReader(formData.Encode()))
 	if err != nil {
-		log.Fatalf("Ошибка %v", err)
+		slog.ErrorContext(ctx, "Не удалось подготовить запрос к UNU API", "ACTION", action, "ERROR", err)
+		return ""
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "UNU API недоступен", "ACTION", action, "ERROR", err)
+		return ""
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("Ошибка %v", err)
+		slog.ErrorContext(ctx, "Не удалось прочитать ответ UNU API", "ACTION", action, "ERROR", err)
+		return ""
 	}
 	bodyString := string(body)
+	slog.DebugContext(ctx, "Ответ UNU API", "ACTION", action, "STATUS", resp.StatusCode, "BODY", bodyString)
 	return bodyString
 }
 
 type UNUAPI interface {
-	Get_balance() string
-	Get_folders() []struct {
-		ID   json.Number `json:"id"`
-		Name string      `json:"name"`
-	}
-	Create_folder(folder_name string) (int64, error)
-	Delete_folder(folder_id int) (bool, error)
-	// Move_task()
-	// Get_tasks()
-	// Get_reports()
-	// Approve_report()
-	// Reject_report()
-	// Get_expenses()
-	Add_task(ctx context.Context, rowWork string) (int, error)
-	// Del_task()
-	// Task_limit_add()
-	// Edit_task()
-	// Get_tariffs()
-	// Task_pause()
-	// Task_play()
-
-}
-
-func (c *Client) Get_balance() string {
+	Get_balance(ctx context.Context) (*Balance, error)
+	Get_folders(ctx context.Context) ([]Folder, error)
+	Create_folder(ctx context.Context, folder_name string) (int64, error)
+	Delete_folder(ctx context.Context, folder_id int) (bool, error)
+	Move_task(ctx context.Context, task_id, folder_id int) error
+	Get_tasks(ctx context.Context, folder_id int) ([]Task, error)
+	Get_reports(ctx context.Context, task_id, folder_id int) ([]Report, error)
+	Approve_report(ctx context.Context, report_id int) error
+	Reject_report(ctx context.Context, report_id int, comment string) error
+	Get_expenses(ctx context.Context, date_from, date_to time.Time, folder_id int) ([]Expense, error)
+	Add_task(ctx context.Context, params *TaskParams) (int, error)
+	Del_task(ctx context.Context, task_id int) error
+	Task_limit_add(ctx context.Context, task_id, add_to_limit int) error
+	Edit_task(ctx context.Context, task_id int, params *TaskEdit) error
+	Get_tariffs(ctx context.Context) ([]Tariff, error)
+	Task_pause(ctx context.Context, task_id int) error
+	Task_play(ctx context.Context, task_id int) error
+}
+
+// Balance состояние кошелька. Available — сколько можно потратить на новые задачи
+type Balance struct {
+	Balance   float64
+	Freeze    float64
+	Available float64
+}
+
+func (c *Client) Get_balance(ctx context.Context) (*Balance, error) {
 
 	type Response struct {
 		Success bool    `json:"success"`
@@ -97,44 +115,55 @@ func (c *Client) Get_balance() string {
 	}
 
 	slog.Info("goes to API for get balance wallet")
-	bytesRes := c.post("get_balance", nil)
+	bytesRes := c.post(ctx, "get_balance", nil)
 
 	var response Response
 	err := json.Unmarshal([]byte(bytesRes), &response)
 	if err != nil {
 		slog.Warn("Ошибка парсинга JSON:", "ERROR:", err)
+		return nil, models.ErrorUnmarshallJSON
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%w: %s", models.ErrorUNUAPI, response.Errors)
 	}
 
-	return fmt.Sprint(response.Balance)
+	return &Balance{
+		Balance:   response.Balance,
+		Freeze:    response.Freeze,
+		Available: response.Balance - response.Freeze,
+	}, nil
 }
 
-func (c *Client) Get_folders() []struct {
+// Folder папка из ответа get_folders
+type Folder struct {
 	ID   json.Number `json:"id"`
 	Name string      `json:"name"`
-} {
+}
+
+func (c *Client) Get_folders(ctx context.Context) ([]Folder, error) {
 	type Response struct {
-		Success bool   `json:"success"`
-		Errors  string `json:"errors"`
-		Folders []struct {
-			ID   json.Number `json:"id"`
-			Name string      `json:"name"`
-		} `json:"folders"`
+		Success bool     `json:"success"`
+		Errors  string   `json:"errors"`
+		Folders []Folder `json:"folders"`
 	}
 
 	slog.Info("goes to API for get folder list id`s")
-	bytesRes := c.post("get_folders", nil)
-	fmt.Println(bytesRes)
+	bytesRes := c.post(ctx, "get_folders", nil)
 	var response Response
 	err := json.Unmarshal([]byte(bytesRes), &response)
 	if err != nil {
 		slog.Warn("Ошибка парсинга JSON:", "ERROR:", err)
+		return nil, models.ErrorUnmarshallJSON
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%w: %s", models.ErrorUNUAPI, response.Errors)
 	}
 	slog.Info("Success get folders")
 
-	return response.Folders
+	return response.Folders, nil
 }
 
-func (c *Client) Create_folder(folder_name string) (int64, error) {
+func (c *Client) Create_folder(ctx context.Context, folder_name string) (int64, error) {
 	action_value := make(map[string]interface{})
 	action_value["name"] = folder_name
 	slog.Info(fmt.Sprintf("Creating folder with name %s", folder_name))
@@ -145,7 +174,7 @@ func (c *Client) Create_folder(folder_name string) (int64, error) {
 		Freeze    float64     `json:"freeze"`
 	}
 
-	bytesRes := c.post("create_folder", action_value)
+	bytesRes := c.post(ctx, "create_folder", action_value)
 	slog.Debug("We get result for creating:", "GETIING:", bytesRes)
 	var response Response
 	err := json.Unmarshal([]byte(bytesRes), &response)
@@ -163,7 +192,7 @@ func (c *Client) Create_folder(folder_name string) (int64, error) {
 	return folder_id, nil
 
 }
-func (c *Client) Delete_folder(folder_id int) (bool, error) {
+func (c *Client) Delete_folder(ctx context.Context, folder_id int) (bool, error) {
 	action_value := make(map[string]interface{})
 	action_value["folder_id"] = folder_id
 	slog.Info(fmt.Sprintf("Deleting folder with name %d", folder_id))
@@ -172,8 +201,7 @@ func (c *Client) Delete_folder(folder_id int) (bool, error) {
 		Errors  string `json:"errors"`
 	}
 
-	bytesRes := c.post("del_folder", action_value)
-	fmt.Println(bytesRes)
+	bytesRes := c.post(ctx, "del_folder", action_value)
 	var response Response
 
 	err := json.Unmarshal([]byte(bytesRes), &response)
@@ -181,15 +209,16 @@ func (c *Client) Delete_folder(folder_id int) (bool, error) {
 		slog.Warn("Ошибка парсинга JSON:", "ERROR:", err)
 		return false, err
 	}
-	slog.Info("Success delete folder")
-	if response.Success == false {
-		slog.Error("Ошибка при удалении")
-		return false, err
+	if !response.Success {
+		slog.Error("Ошибка при удалении", "ERROR", response.Errors)
+		return false, fmt.Errorf("%w: %s", models.ErrorUNUAPI, response.Errors)
 	}
+	slog.Info("Success delete folder")
 
 	return true, nil
 }
 
+// Входные данные add_task
 //     name (text) – название задачи
 //     descr (text) – текст задания
 //     link (text) – URL, необходимый для выполнения задания (необязательный параметр)
@@ -207,45 +236,283 @@ func (c *Client) Delete_folder(folder_id int) (bool, error) {
 
 // task_id (int) – идентификатор созданной задачи
 
-func (c *Client) Add_task(ctx context.Context, rowWork string) (int, error) {
-	//"""Обработка в функции идёт только 1 строки"""
+type TaskParams struct {
+	Name                  string  `json:"name"`
+	Descr                 string  `json:"descr"`
+	Link                  string  `json:"link"`
+	NeedForReport         string  `json:"need_for_report"`
+	Price                 float64 `json:"price"`
+	TarifId               int     `json:"tarif_id"`
+	FolderId              int     `json:"folder_id"`
+	NeedScreen            bool    `json:"need_screen"`
+	TimeForWork           int     `json:"time_for_work"`
+	TimeForCheck          int     `json:"time_for_check"`
+	TargetingGender       int     `json:"targeting_gender,omitempty"`
+	TargetingGeoCountryId int     `json:"targeting_geo_country_id,omitempty"`
+}
+
+func (p *TaskParams) actionValues() map[string]interface{} {
+	action_value := map[string]interface{}{
+		"name":            p.Name,
+		"descr":           p.Descr,
+		"need_for_report": p.NeedForReport,
+		"price":           p.Price,
+		"tarif_id":        p.TarifId,
+		"folder_id":       p.FolderId,
+		"time_for_work":   p.TimeForWork,
+		"time_for_check":  p.TimeForCheck,
+	}
+	if p.Link != "" {
+		action_value["link"] = p.Link
+	}
+	if p.NeedScreen {
+		action_value["need_screen"] = 1
+	}
+	if p.TargetingGender != 0 {
+		action_value["targeting_gender"] = p.TargetingGender
+	}
+	if p.TargetingGeoCountryId != 0 {
+		action_value["targeting_geo_country_id"] = p.TargetingGeoCountryId
+	}
+	return action_value
+}
+
+func (c *Client) Add_task(ctx context.Context, params *TaskParams) (int, error) {
+	slog.InfoContext(ctx, fmt.Sprintf("Creating task with name %s", params.Name))
+	type Response struct {
+		Success bool        `json:"success"`
+		Errors  string      `json:"errors"`
+		TaskId  json.Number `json:"task_id"`
+	}
+
+	bytesRes := c.post(ctx, "add_task", params.actionValues())
+	var response Response
+	err := json.Unmarshal([]byte(bytesRes), &response)
+	if err != nil {
+		slog.WarnContext(ctx, "Ошибка парсинга JSON:", "ERROR:", err)
+		return 0, models.ErrorUnmarshallJSON
+	}
+	if !response.Success {
+		slog.ErrorContext(ctx, "UNU отказал в создании задачи", "ERROR", response.Errors)
+		return 0, fmt.Errorf("%w: %s", models.ErrorUNUAPI, response.Errors)
+	}
+	task_id, err := response.TaskId.Int64()
+	if err != nil {
+		slog.WarnContext(ctx, "Некорректный ID задачи в ответе UNU", "ERROR", err)
+		return 0, err
+	}
+	slog.InfoContext(ctx, "Success create task", "TASK_ID", task_id)
+	return int(task_id), nil
+}
+
+// Task задача из ответа get_tasks
+type Task struct {
+	ID         json.Number `json:"id"`
+	Name       string      `json:"name"`
+	FolderId   json.Number `json:"folder_id"`
+	Status     json.Number `json:"status"`
+	Price      json.Number `json:"price"`
+	LimitTotal json.Number `json:"limit_total"`
+	CountDone  json.Number `json:"count_done"`
+}
+
+// OutOfLimit сообщает, что все выполнения задачи израсходованы и без увеличения лимита она не будет выполняться
+func (t Task) OutOfLimit() bool {
+	limit, err := t.LimitTotal.Int64()
+	if err != nil || limit <= 0 {
+		return false
+	}
+	done, err := t.CountDone.Int64()
+	if err != nil {
+		return false
+	}
+	return done >= limit
+}
+
+// Входные данные get_tasks
+//     folder_id (int) – идентификатор папки, задачи которой нужно получить (необязательный параметр)
+
+// Выходные данные
+
+// tasks – массив задач
+
+func (c *Client) Get_tasks(ctx context.Context, folder_id int) ([]Task, error) {
+	action_value := make(map[string]interface{})
+	if folder_id != 0 {
+		action_value["folder_id"] = folder_id
+	}
+	type Response struct {
+		Success bool   `json:"success"`
+		Errors  string `json:"errors"`
+		Tasks   []Task `json:"tasks"`
+	}
+
+	slog.Info("goes to API for get tasks", "FOLDER_ID", folder_id)
+	bytesRes := c.post(ctx, "get_tasks", action_value)
+	var response Response
+	err := json.Unmarshal([]byte(bytesRes), &response)
+	if err != nil {
+		slog.Warn("Ошибка парсинга JSON:", "ERROR:", err)
+		return nil, models.ErrorUnmarshallJSON
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%w: %s", models.ErrorUNUAPI, response.Errors)
+	}
+	return response.Tasks, nil
+}
 
-	//TODO: Пойти в таблицу и получить строку
+// postAction выполняет действие, в ответе которого нет ничего, кроме признака успеха и текста ошибки
+func (c *Client) postAction(ctx context.Context, action string, params map[string]interface{}) error {
+	type Response struct {
+		Success bool   `json:"success"`
+		Errors  string `json:"errors"`
+	}
 
-	resp, err := gsr.Reader(spreadsheetId, "BOT", rowWork)
+	bytesRes := c.post(ctx, action, params)
+	slog.Debug("We get result for action:", "ACTION", action, "GETIING:", bytesRes)
+	var response Response
+	err := json.Unmarshal([]byte(bytesRes), &response)
 	if err != nil {
-		slog.Error("Ошибка получения данных из таблицы")
+		slog.Warn("Ошибка парсинга JSON:", "ERROR:", err)
+		return models.ErrorUnmarshallJSON
 	}
-	//TODO: Получить имя для задачи
-	_ = resp
+	if !response.Success {
+		slog.Error("UNU вернул ошибку", "ACTION", action, "ERROR", response.Errors)
+		return fmt.Errorf("%w: %s", models.ErrorUNUAPI, response.Errors)
+	}
+	return nil
+}
+
+func (c *Client) Del_task(ctx context.Context, task_id int) error {
+	slog.Info(fmt.Sprintf("Deleting task with id %d", task_id))
+	return c.postAction(ctx, "del_task", map[string]interface{}{"task_id": task_id})
+}
+
+func (c *Client) Task_pause(ctx context.Context, task_id int) error {
+	slog.Info(fmt.Sprintf("Pausing task with id %d", task_id))
+	return c.postAction(ctx, "task_pause", map[string]interface{}{"task_id": task_id})
+}
+
+func (c *Client) Task_play(ctx context.Context, task_id int) error {
+	slog.Info(fmt.Sprintf("Starting task with id %d", task_id))
+	return c.postAction(ctx, "task_play", map[string]interface{}{"task_id": task_id})
+}
+
+func (c *Client) Move_task(ctx context.Context, task_id, folder_id int) error {
+	slog.Info(fmt.Sprintf("Moving task with id %d to folder %d", task_id, folder_id))
+	return c.postAction(ctx, "move_task", map[string]interface{}{"task_id": task_id, "folder_id": folder_id})
+}
 
-	//TODO: Получить описание задания
+// Входные данные task_limit_add
+//     task_id (int) – идентификатор задачи
+//     add_to_limit (int) – на сколько выполнений увеличить лимит задачи
 
-	//TODO: Получить ссылку для задания
+func (c *Client) Task_limit_add(ctx context.Context, task_id, add_to_limit int) error {
+	slog.Info(fmt.Sprintf("Adding limit %d to task with id %d", add_to_limit, task_id))
+	return c.postAction(ctx, "task_limit_add", map[string]interface{}{"task_id": task_id, "add_to_limit": add_to_limit})
+}
 
-	//TODO: Получить данные: что нужно для выполнения задания
+// TaskEdit изменяемые поля задачи для edit_task. Пустые поля не передаются и остаются без изменений
+type TaskEdit struct {
+	Name          string
+	Descr         string
+	Link          string
+	NeedForReport string
+	Price         float64
+}
 
-	//TODO: получить стоимость задания
+func (c *Client) Edit_task(ctx context.Context, task_id int, params *TaskEdit) error {
+	slog.Info(fmt.Sprintf("Editing task with id %d", task_id))
+	action_value := map[string]interface{}{"task_id": task_id}
+	if params.Name != "" {
+		action_value["name"] = params.Name
+	}
+	if params.Descr != "" {
+		action_value["descr"] = params.Descr
+	}
+	if params.Link != "" {
+		action_value["link"] = params.Link
+	}
+	if params.NeedForReport != "" {
+		action_value["need_for_report"] = params.NeedForReport
+	}
+	if params.Price > 0 {
+		action_value["price"] = params.Price
+	}
+	if len(action_value) == 1 {
+		return models.ErrorIncorrectData
+	}
+	return c.postAction(ctx, "edit_task", action_value)
+}
 
-	//TODO: понять, какой тариф выбрать
+// Report отчёт исполнителя из ответа get_reports
+type Report struct {
+	ID       json.Number `json:"id"`
+	TaskId   json.Number `json:"task_id"`
+	WorkerId json.Number `json:"worker_id"`
+	Status   json.Number `json:"status"`
+	Message  string      `json:"message"`
+	Files    []string    `json:"files"`
+	DateAdd  string      `json:"date_add"`
+	DateEnd  string      `json:"date_end"`
+}
 
-	//TODO: понять в какую папку сохранить задание
+// Время в ответах UNU указано по Москве
+var unuLocation = time.FixedZone("MSK", 3*60*60)
 
-	//TODO: Необходимость скриншота (по умолчанию всегда True)
+const unuTimeLayout = "2006-01-02 15:04:05"
 
-	//TODO: Время на выполнение 72 часа
+// Deadline возвращает крайний срок проверки отчёта
+func (r Report) Deadline() (time.Time, error) {
+	return time.ParseInLocation(unuTimeLayout, r.DateEnd, unuLocation)
+}
 
-	//TODO: Время на проверку 120 часов
+// Входные данные get_reports
+//     task_id (int) – идентификатор задачи (необязательный параметр)
+//     folder_id (int) – идентификатор папки (необязательный параметр)
 
-	//TODO: Получить значение гендерного пола для задания
+// Выходные данные
 
-	//TODO: Выбрать страну: Россия для задания
+// reports – массив отчётов, ожидающих проверки. date_end – крайний срок проверки (time_for_check)
 
-	//TODO: Добавить в базу значение строки
+func (c *Client) Get_reports(ctx context.Context, task_id, folder_id int) ([]Report, error) {
+	action_value := make(map[string]interface{})
+	if task_id != 0 {
+		action_value["task_id"] = task_id
+	}
+	if folder_id != 0 {
+		action_value["folder_id"] = folder_id
+	}
+	type Response struct {
+		Success bool     `json:"success"`
+		Errors  string   `json:"errors"`
+		Reports []Report `json:"reports"`
+	}
 
-	//TODO: Отправить запрос на API
+	slog.Info("goes to API for get reports", "TASK_ID", task_id, "FOLDER_ID", folder_id)
+	bytesRes := c.post(ctx, "get_reports", action_value)
+	var response Response
+	err := json.Unmarshal([]byte(bytesRes), &response)
+	if err != nil {
+		slog.Warn("Ошибка парсинга JSON:", "ERROR:", err)
+		return nil, models.ErrorUnmarshallJSON
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%w: %s", models.ErrorUNUAPI, response.Errors)
+	}
+	return response.Reports, nil
+}
+
+func (c *Client) Approve_report(ctx context.Context, report_id int) error {
+	slog.Info(fmt.Sprintf("Approving report with id %d", report_id))
+	return c.postAction(ctx, "approve_report", map[string]interface{}{"report_id": report_id})
+}
 
-	//TODO: Если ответ успешный, удалить из базы
+// Входные данные reject_report
+//     report_id (int) – идентификатор отчёта
+//     comment (text) – причина отклонения, её увидит исполнитель
 
-	return 0, nil
+func (c *Client) Reject_report(ctx context.Context, report_id int, comment string) error {
+	slog.Info(fmt.Sprintf("Rejecting report with id %d", report_id))
+	return c.postAction(ctx, "reject_report", map[string]interface{}{"report_id": report_id, "comment": comment})
 }
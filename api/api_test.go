@@ -0,0 +1,77 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportDeadline(t *testing.T) {
+	report := Report{DateEnd: "2025-05-17 10:00:00"}
+	deadline, err := report.Deadline()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 5, 17, 7, 0, 0, 0, time.UTC), deadline.UTC())
+
+	_, err = Report{}.Deadline()
+	require.Error(t, err)
+}
+
+func TestTaskOutOfLimit(t *testing.T) {
+	assert.True(t, Task{LimitTotal: json.Number("5"), CountDone: json.Number("5")}.OutOfLimit())
+	assert.False(t, Task{LimitTotal: json.Number("5"), CountDone: json.Number("4")}.OutOfLimit())
+	assert.False(t, Task{LimitTotal: json.Number("0"), CountDone: json.Number("0")}.OutOfLimit())
+	assert.False(t, Task{}.OutOfLimit())
+}
+
+func TestDeleteFolderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":false,"errors":"folder not found"}`))
+	}))
+	defer server.Close()
+
+	ok, err := NewClient(server.URL, "token").Delete_folder(context.Background(), 5)
+	assert.False(t, ok)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, models.ErrorUNUAPI))
+	assert.Contains(t, err.Error(), "folder not found")
+}
+
+func TestPostHidesKeyAndStopsOnCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("action") == "get_tasks" {
+			<-release
+		}
+		assert.Equal(t, "secret-token", r.FormValue("api_key"))
+		w.Write([]byte(`{"success":true,"balance":10}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(previous)
+
+	client := NewClient(server.URL, "secret-token")
+	balance, err := client.Get_balance(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, balance.Balance)
+	assert.Contains(t, logs.String(), "get_balance")
+	assert.NotContains(t, logs.String(), "secret-token")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Get_tasks(ctx, 0)
+	require.ErrorIs(t, err, models.ErrorUnmarshallJSON)
+}
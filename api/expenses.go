@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	expenseDateLayout = "2006-01-02"
+
+	// Проект для задач, которые создавались не через бота и строки для них нет в базе
+	UnknownProject = "Без проекта"
+)
+
+// Expense списание из ответа get_expenses
+type Expense struct {
+	Date     string      `json:"date"`
+	TaskId   json.Number `json:"task_id"`
+	FolderId json.Number `json:"folder_id"`
+	Amount   json.Number `json:"amount"`
+}
+
+// Day возвращает день списания в формате 2006-01-02
+func (e Expense) Day() string {
+	if len(e.Date) < len(expenseDateLayout) {
+		return e.Date
+	}
+	return e.Date[:len(expenseDateLayout)]
+}
+
+// Входные данные get_expenses
+//     date_from (date) – начало периода в формате ГГГГ-ММ-ДД (необязательный параметр)
+//     date_to (date) – конец периода включительно в формате ГГГГ-ММ-ДД (необязательный параметр)
+//     folder_id (int) – идентификатор папки (необязательный параметр)
+
+// Выходные данные
+
+// expenses – массив списаний: дата, задача, папка и сумма в рублях
+
+func (c *Client) Get_expenses(ctx context.Context, date_from, date_to time.Time, folder_id int) ([]Expense, error) {
+	action_value := make(map[string]interface{})
+	if !date_from.IsZero() {
+		action_value["date_from"] = date_from.Format(expenseDateLayout)
+	}
+	if !date_to.IsZero() {
+		action_value["date_to"] = date_to.Format(expenseDateLayout)
+	}
+	if folder_id != 0 {
+		action_value["folder_id"] = folder_id
+	}
+	type Response struct {
+		Success  bool      `json:"success"`
+		Errors   string    `json:"errors"`
+		Expenses []Expense `json:"expenses"`
+	}
+
+	slog.Info("goes to API for get expenses", "FROM", action_value["date_from"], "TO", action_value["date_to"], "FOLDER_ID", folder_id)
+	bytesRes := c.post(ctx, "get_expenses", action_value)
+	var response Response
+	err := json.Unmarshal([]byte(bytesRes), &response)
+	if err != nil {
+		slog.Warn("Ошибка парсинга JSON:", "ERROR:", err)
+		return nil, models.ErrorUnmarshallJSON
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%w: %s", models.ErrorUNUAPI, response.Errors)
+	}
+	return response.Expenses, nil
+}
+
+// ExpenseLine сумма расходов по одному дню, папке или проекту
+type ExpenseLine struct {
+	Key    string
+	Amount float64
+	Count  int
+}
+
+// ExpenseSummary расходы за период, сгруппированные по дням, папкам и проектам
+type ExpenseSummary struct {
+	From      time.Time
+	To        time.Time
+	Total     float64
+	ByDay     []ExpenseLine
+	ByFolder  []ExpenseLine
+	ByProject []ExpenseLine
+}
+
+// SummarizeExpenses группирует списания. folderNames и projects сопоставляют ID папки и ID задачи
+// с названием папки и проектом. Если сопоставления нет, в отчёт попадает ID папки или UnknownProject
+func SummarizeExpenses(expenses []Expense, from, to time.Time, folderNames, projects map[string]string) *ExpenseSummary {
+	byDay := make(map[string]*ExpenseLine)
+	byFolder := make(map[string]*ExpenseLine)
+	byProject := make(map[string]*ExpenseLine)
+	add := func(group map[string]*ExpenseLine, key string, amount float64) {
+		line, ok := group[key]
+		if !ok {
+			line = &ExpenseLine{Key: key}
+			group[key] = line
+		}
+		line.Amount += amount
+		line.Count++
+	}
+
+	summary := &ExpenseSummary{From: from, To: to}
+	for _, expense := range expenses {
+		amount, err := expense.Amount.Float64()
+		if err != nil {
+			slog.Warn("Некорректная сумма списания в ответе UNU", "TASK_ID", expense.TaskId.String(), "AMOUNT", expense.Amount.String())
+			continue
+		}
+		folder, ok := folderNames[expense.FolderId.String()]
+		if !ok {
+			folder = fmt.Sprintf("Папка %s", expense.FolderId.String())
+		}
+		project, ok := projects[expense.TaskId.String()]
+		if !ok || project == "" {
+			project = UnknownProject
+		}
+		summary.Total += amount
+		add(byDay, expense.Day(), amount)
+		add(byFolder, folder, amount)
+		add(byProject, project, amount)
+	}
+	summary.ByDay = sortedLines(byDay, false)
+	summary.ByFolder = sortedLines(byFolder, true)
+	summary.ByProject = sortedLines(byProject, true)
+	return summary
+}
+
+// sortedLines упорядочивает дни по дате, а папки и проекты — по убыванию суммы
+func sortedLines(group map[string]*ExpenseLine, byAmount bool) []ExpenseLine {
+	lines := make([]ExpenseLine, 0, len(group))
+	for _, line := range group {
+		lines = append(lines, *line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if byAmount && lines[i].Amount != lines[j].Amount {
+			return lines[i].Amount > lines[j].Amount
+		}
+		return lines[i].Key < lines[j].Key
+	})
+	return lines
+}
+
+// Rows возвращает отчёт в виде таблицы: одна и та же раскладка для CSV, XLSX и листа EXPENSES
+func (s *ExpenseSummary) Rows() [][]string {
+	rows := [][]string{
+		{"Период", s.From.Format("02.01.2006") + " - " + s.To.Format("02.01.2006"), "", ""},
+		{"Итого", "", formatAmount(s.Total), ""},
+	}
+	sections := []struct {
+		title string
+		lines []ExpenseLine
+	}{
+		{"По дням", s.ByDay},
+		{"По папкам", s.ByFolder},
+		{"По проектам", s.ByProject},
+	}
+	for _, section := range sections {
+		rows = append(rows, []string{"", "", "", ""}, []string{section.title, "", "Сумма, ₽", "Списаний"})
+		for _, line := range section.lines {
+			rows = append(rows, []string{"", line.Key, formatAmount(line.Amount), fmt.Sprint(line.Count)})
+		}
+	}
+	return rows
+}
+
+func formatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// WriteCSV записывает отчёт в CSV с разделителем ';', чтобы его сразу открывал Excel с русской локалью
+func (s *ExpenseSummary) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = ';'
+	err := writer.WriteAll(s.Rows())
+	if err != nil {
+		slog.Error("Не удалось записать отчёт о расходах в CSV", "ERROR", err)
+		return err
+	}
+	return nil
+}
+
+// Values возвращает Rows, в которых суммы записаны числами, чтобы в таблице по ним можно было считать формулы
+func (s *ExpenseSummary) Values() [][]interface{} {
+	rows := s.Rows()
+	values := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = make([]interface{}, len(row))
+		for j, value := range row {
+			values[i][j] = value
+			if amount, err := strconv.ParseFloat(value, 64); err == nil && j == 2 {
+				values[i][j] = amount
+			}
+		}
+	}
+	return values
+}
+
+// WriteXLSX записывает отчёт в книгу Excel с одним листом
+func (s *ExpenseSummary) WriteXLSX(w io.Writer) error {
+	book := excelize.NewFile()
+	defer book.Close()
+	sheet := book.GetSheetName(0)
+	for i, row := range s.Values() {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		err = book.SetSheetRow(sheet, cell, &row)
+		if err != nil {
+			slog.Error("Не удалось записать отчёт о расходах в XLSX", "ERROR", err)
+			return err
+		}
+	}
+	book.SetColWidth(sheet, "A", "A", 14)
+	book.SetColWidth(sheet, "B", "B", 40)
+	book.SetColWidth(sheet, "C", "D", 12)
+	_, err := book.WriteTo(w)
+	return err
+}
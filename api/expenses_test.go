@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func testExpenseSummary() *ExpenseSummary {
+	expenses := []Expense{
+		{Date: "2025-09-01 10:00:00", TaskId: json.Number("10"), FolderId: json.Number("1"), Amount: json.Number("15.5")},
+		{Date: "2025-09-01 12:00:00", TaskId: json.Number("11"), FolderId: json.Number("2"), Amount: json.Number("40")},
+		{Date: "2025-09-02 09:30:00", TaskId: json.Number("10"), FolderId: json.Number("1"), Amount: json.Number("15.5")},
+		{Date: "2025-09-03 09:30:00", TaskId: json.Number("12"), FolderId: json.Number("3"), Amount: json.Number("abc")},
+	}
+	folders := map[string]string{"1": "Кофейни", "2": "Салоны"}
+	projects := map[string]string{"10": "Кофе Хауз"}
+	return SummarizeExpenses(expenses, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC), folders, projects)
+}
+
+func TestSummarizeExpenses(t *testing.T) {
+	summary := testExpenseSummary()
+	assert.InDelta(t, 71.0, summary.Total, 0.001)
+	assert.Equal(t, []ExpenseLine{
+		{Key: "2025-09-01", Amount: 55.5, Count: 2},
+		{Key: "2025-09-02", Amount: 15.5, Count: 1},
+	}, summary.ByDay)
+	assert.Equal(t, []ExpenseLine{
+		{Key: "Салоны", Amount: 40, Count: 1},
+		{Key: "Кофейни", Amount: 31, Count: 2},
+	}, summary.ByFolder)
+	assert.Equal(t, []ExpenseLine{
+		{Key: UnknownProject, Amount: 40, Count: 1},
+		{Key: "Кофе Хауз", Amount: 31, Count: 2},
+	}, summary.ByProject)
+}
+
+func TestExpenseSummaryExport(t *testing.T) {
+	summary := testExpenseSummary()
+
+	var csvFile bytes.Buffer
+	require.NoError(t, summary.WriteCSV(&csvFile))
+	lines := strings.Split(strings.TrimSpace(csvFile.String()), "\n")
+	assert.Equal(t, "Период;01.09.2025 - 30.09.2025;;", lines[0])
+	assert.Equal(t, "Итого;;71.00;", lines[1])
+	assert.Contains(t, lines, ";Кофе Хауз;31.00;2")
+
+	var xlsxFile bytes.Buffer
+	require.NoError(t, summary.WriteXLSX(&xlsxFile))
+	book, err := excelize.OpenReader(&xlsxFile)
+	require.NoError(t, err)
+	defer book.Close()
+	total, err := book.GetCellValue(book.GetSheetName(0), "C2")
+	require.NoError(t, err)
+	assert.Equal(t, "71", total)
+}
@@ -3,18 +3,16 @@ package api
 import (
 	"fmt"
 	"log/slog"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	gsr "github.com/shakirovformal/unu_project_api_realizer/pkg/google-sheet-reader"
 	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
 	"google.golang.org/api/sheets/v4"
 )
 
-func getName(respData *sheets.ValueRange) (string, error) {
+func getName(sheet SheetSource, respData *sheets.ValueRange) (string, error) {
 
 	// TODO: Название выстраивается за счёт данных:
 	task_name := ""
@@ -22,7 +20,7 @@ func getName(respData *sheets.ValueRange) (string, error) {
 	publicationDate := normalizeData(gettedDate)
 	// Получаем ссылку
 	link := fmt.Sprint(respData.Values[0][1])
-	ref, err := checkReferenceFromLink(link)
+	ref, err := checkReferenceFromLink(sheet, link)
 	if err != nil {
 		slog.Error("Ошибка при попытке мэтчинга сайта по ссылке")
 		return "", models.ErrorGoogleSheet
@@ -37,6 +35,35 @@ func getName(respData *sheets.ValueRange) (string, error) {
 	return task_name, nil
 }
 
+// getDescription собирает текст задания для исполнителя
+func getDescription(respData *sheets.ValueRange) string {
+	link := fmt.Sprint(respData.Values[0][1])
+	gender := checkGender(fmt.Sprint(respData.Values[0][2]))
+	text := strings.TrimSpace(fmt.Sprint(respData.Values[0][3]))
+	publicationDate := normalizeData(fmt.Sprint(respData.Values[0][5]))
+
+	descr := fmt.Sprintf("Опубликуйте готовый отзыв по ссылке: %s\n", link)
+	if gender != "" {
+		descr += fmt.Sprintf("Отзыв нужно опубликовать с аккаунта, пол владельца которого %s.\n", gender)
+	}
+	if publicationDate != "" {
+		descr += fmt.Sprintf("Дата публикации: %s.\n", publicationDate)
+	}
+	descr += fmt.Sprintf("Текст отзыва скопируйте без изменений:\n%s", text)
+	return descr
+}
+
+// genderCode переводит пол из таблицы в значение targeting_gender: 1 – женский, 2 – мужской
+func genderCode(gender string) int {
+	switch strings.TrimSpace(gender) {
+	case "ж":
+		return 1
+	case "м":
+		return 2
+	}
+	return 0
+}
+
 func checkGender(gender string) string {
 	switch gender {
 	case "м":
@@ -256,21 +283,25 @@ func convertTwoDigitYear(year string) string {
 	return "19" + padZero(year)
 }
 
-func checkReferenceFromLink(link string) (string, error) {
+func checkReferenceFromLink(sheet SheetSource, link string) (string, error) {
 	patternSlice := NewSiteMatcher()
 	siteCell, err := patternSlice.GetCellForURL(link)
 	if err != nil {
 		return "", err
 	}
-	resp, err := gsr.ReaderFromCell(os.Getenv("SPREADSHEETID"), "REFERENCE", siteCell)
+	resp, err := sheet.ReaderFromCell("REFERENCE", siteCell)
+	if err != nil {
+		return "", err
+	}
 	textReference := fmt.Sprint(resp.Values[0][0])
 	return textReference, nil
 }
 
-// SitePattern хранит шаблон URL и соответствующую ячейку
+// SitePattern хранит шаблон URL, соответствующую ячейку и название площадки
 type SitePattern struct {
-	Pattern *regexp.Regexp
-	Cell    string
+	Pattern  *regexp.Regexp
+	Cell     string
+	Platform string
 }
 
 // SiteMatcher содержит все паттерны для сопоставления
@@ -283,28 +314,40 @@ func NewSiteMatcher() *SiteMatcher {
 	return &SiteMatcher{
 		patterns: []SitePattern{
 			{
-				Pattern: regexp.MustCompile(`maps\.app\.goo\.gl`),
-				Cell:    "A2",
+				Pattern:  regexp.MustCompile(`maps\.app\.goo\.gl`),
+				Cell:     "A2",
+				Platform: "Google Карты",
 			},
 			{
-				Pattern: regexp.MustCompile(`yandex\.(ru|com)/maps`),
-				Cell:    "B2",
+				// Полные ссылки Google Карт, которые исполнители присылают в отчётах
+				Pattern:  regexp.MustCompile(`google\.[a-z.]+/maps|goo\.gl/maps`),
+				Cell:     "A2",
+				Platform: "Google Карты",
 			},
 			{
-				Pattern: regexp.MustCompile(`otzovik\.com`),
-				Cell:    "C2",
+				Pattern:  regexp.MustCompile(`yandex\.(ru|com)/maps`),
+				Cell:     "B2",
+				Platform: "Яндекс Карты",
 			},
 			{
-				Pattern: regexp.MustCompile(`irecommend\.ru`),
-				Cell:    "D2",
+				Pattern:  regexp.MustCompile(`otzovik\.com`),
+				Cell:     "C2",
+				Platform: "Отзовик",
 			},
 			{
-				Pattern: regexp.MustCompile(`prodoctorov\.ru`),
-				Cell:    "E2",
+				Pattern:  regexp.MustCompile(`irecommend\.ru`),
+				Cell:     "D2",
+				Platform: "irecommend",
 			},
 			{
-				Pattern: regexp.MustCompile(`sravni\.ru`),
-				Cell:    "F2",
+				Pattern:  regexp.MustCompile(`prodoctorov\.ru`),
+				Cell:     "E2",
+				Platform: "ПроДокторов",
+			},
+			{
+				Pattern:  regexp.MustCompile(`sravni\.ru`),
+				Cell:     "F2",
+				Platform: "Сравни",
 			},
 		},
 	}
@@ -319,3 +362,13 @@ func (sm *SiteMatcher) GetCellForURL(url string) (string, error) {
 	}
 	return "", models.ErrorMatchingSite // или какое-то значение по умолчанию
 }
+
+// GetPlatformForURL возвращает название площадки для данного URL
+func (sm *SiteMatcher) GetPlatformForURL(url string) (string, error) {
+	for _, pattern := range sm.patterns {
+		if pattern.Pattern.MatchString(url) {
+			return pattern.Platform, nil
+		}
+	}
+	return "", models.ErrorMatchingSite
+}
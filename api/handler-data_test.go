@@ -160,7 +160,7 @@ func TestGetName(t *testing.T) {
 	}
 
 	for _, value := range useCase {
-		result, err := getName(value.resp)
+		result, err := getName(googlesheetreader.New(spreadsheetId, googlesheetreader.DefaultCredentialsFile), value.resp)
 		require.NoError(t, err)
 		if assert.Equal(t, value.expRes, result) {
 			fmt.Println("EXPECTED:", value.expRes, "\nGOT:", result)
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/config"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	gsr "github.com/shakirovformal/unu_project_api_realizer/pkg/google-sheet-reader"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/logger"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/validation"
+	"google.golang.org/api/sheets/v4"
+)
+
+const (
+	// Лист таблицы, из которого берутся строки для задач
+	SheetBot = "BOT"
+
+	needForReport = "Ссылка на опубликованный отзыв, текст отзыва и скриншот"
+	timeForWork   = 72
+	timeForCheck  = 120
+)
+
+// TaskSettings общие для всех задач настройки
+type TaskSettings struct {
+	Price                 float64
+	TarifId               int
+	Limit                 int // сколько выполнений нужно по одной строке таблицы
+	TargetingGeoCountryId int
+	Sheets                SheetSource // таблица со строками и справочником REFERENCE
+}
+
+// SheetSource Google таблица, из которой читаются строки. Реализуется gsr.Sheets, в тестах подменяется
+type SheetSource interface {
+	Reader(spreadsheetName, rowNumber string) (*sheets.ValueRange, error)
+	ReaderFromCell(spreadsheetName, cell string) (*sheets.ValueRange, error)
+	Writer(spreadsheetName string, values [][]interface{}) error
+}
+
+// RowCost возвращает стоимость задачи по одной строке таблицы
+func (s *TaskSettings) RowCost() float64 {
+	return s.Price * float64(s.Limit)
+}
+
+// NewTaskSettings берёт настройки задач и таблицы из проверенной конфигурации
+func NewTaskSettings(cfg *config.Config) *TaskSettings {
+	return &TaskSettings{
+		Price:                 cfg.UNU.TaskPrice,
+		TarifId:               cfg.UNU.TarifId,
+		Limit:                 cfg.UNU.TaskLimit,
+		TargetingGeoCountryId: cfg.UNU.GeoCountryId,
+		Sheets:                gsr.New(cfg.Sheet.SpreadsheetId, cfg.Sheet.CredentialsFile),
+	}
+}
+
+// Validate проверяет общие настройки задач по каталогу тарифов до чтения строк таблицы
+func (s *TaskSettings) Validate(tariffs []Tariff) error {
+	return ValidateTariff(tariffs, &TaskParams{
+		Price:                 s.Price,
+		TarifId:               s.TarifId,
+		TargetingGeoCountryId: s.TargetingGeoCountryId,
+	})
+}
+
+// BuildTask собирает параметры задачи для add_task из строки таблицы
+func BuildTask(respData *sheets.ValueRange, settings *TaskSettings, folderId int) (*TaskParams, error) {
+	name, err := getName(settings.Sheets, respData)
+	if err != nil {
+		return nil, err
+	}
+	return &TaskParams{
+		Name:                  name,
+		Descr:                 getDescription(respData),
+		Link:                  strings.TrimSpace(fmt.Sprint(respData.Values[0][1])),
+		NeedForReport:         needForReport,
+		Price:                 settings.Price,
+		TarifId:               settings.TarifId,
+		FolderId:              folderId,
+		NeedScreen:            true,
+		TimeForWork:           timeForWork,
+		TimeForCheck:          timeForCheck,
+		TargetingGender:       genderCode(fmt.Sprint(respData.Values[0][2])),
+		TargetingGeoCountryId: settings.TargetingGeoCountryId,
+	}, nil
+}
+
+// renderTask собирает параметры задачи и, если передан каталог тарифов, проверяет их по нему
+func renderTask(respData *sheets.ValueRange, settings *TaskSettings, tariffs []Tariff, folderId int) (*TaskParams, error) {
+	params, err := BuildTask(respData, settings, folderId)
+	if err != nil {
+		return nil, err
+	}
+	if tariffs != nil {
+		err = ValidateTariff(tariffs, params)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
+// newRowObject переводит строку таблицы в объект для хранения в базе
+func newRowObject(userId int, respData *sheets.ValueRange) *models.RowObject {
+	return models.NewRowObject(
+		userId,
+		strings.TrimSpace(fmt.Sprint(respData.Values[0][0])),
+		strings.TrimSpace(fmt.Sprint(respData.Values[0][1])),
+		genderCode(fmt.Sprint(respData.Values[0][2])),
+		strings.TrimSpace(fmt.Sprint(respData.Values[0][3])),
+		normalizeData(fmt.Sprint(respData.Values[0][5])),
+	)
+}
+
+// RowValidator проверки строки перед отправкой в UNU: все поля по validation.DefaultRules,
+// поддерживаемая площадка по ссылке и, если не allowDuplicate, повтор текста в других необработанных строках
+func RowValidator(ctx context.Context, store database.Store, allowDuplicate bool) *validation.Validator {
+	rules := validation.DefaultRules
+	rules.Platform = NewSiteMatcher().GetPlatformForURL
+	validator := validation.NewValidator(rules)
+	if allowDuplicate {
+		return validator
+	}
+	pending, err := store.ListPending(ctx)
+	if err == nil {
+		var rows map[string]*models.RowObject
+		rows, err = store.GetRows(ctx, pending)
+		for rowNumber, rowObject := range rows {
+			validator.Remember(rowNumber, rowObject.Object.TextDescription)
+		}
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Не удалось загрузить необработанные строки, повторы текста не проверяются", "ERROR", err)
+	}
+	return validator
+}
+
+// RowValidators отдаёт RowValidator для серии строк, например для одного обработчика очереди.
+// Необработанные строки загружаются из базы не на каждую строку, а не чаще раза в maxAge.
+// Тексты строк, проверенных за это время, проверка запоминает сама. Не для одновременного использования
+type RowValidators struct {
+	store  database.Store
+	maxAge time.Duration
+	strict *validation.Validator
+	loaded time.Time
+}
+
+func NewRowValidators(store database.Store, maxAge time.Duration) *RowValidators {
+	return &RowValidators{store: store, maxAge: maxAge}
+}
+
+// Get возвращает проверку строки. С allowDuplicate повторы не ищутся и загружать строки не нужно
+func (v *RowValidators) Get(ctx context.Context, allowDuplicate bool) *validation.Validator {
+	if allowDuplicate {
+		return RowValidator(ctx, v.store, true)
+	}
+	if v.strict == nil || time.Since(v.loaded) > v.maxAge {
+		v.strict = RowValidator(ctx, v.store, false)
+		v.loaded = time.Now()
+	}
+	return v.strict
+}
+
+// CheckRow переводит прочитанную строку таблицы в объект и проверяет его через validator (см. RowValidator).
+// Ошибка — validation.Errors со всеми полями, которые нужно исправить
+func CheckRow(validator *validation.Validator, userId int, row string, respData *sheets.ValueRange) (*models.RowObject, error) {
+	rowObject := newRowObject(userId, respData)
+	return rowObject, validator.Validate(row, rowObject)
+}
+
+// TextHistory тексты отзывов, по которым уже создавались задачи. Реализация в Redis — database.TextHistory
+type TextHistory interface {
+	FindSimilarTexts(ctx context.Context, text string) ([]models.TextMatch, error)
+	SaveText(ctx context.Context, text string, fp models.TextFingerprint) error
+}
+
+// maxTextMatches сколько прошлых задач с таким же текстом перечислять в ошибке
+const maxTextMatches = 3
+
+// CheckDuplicateText ищет текст строки среди отправленных раньше.
+// Если нашёлся повтор, возвращает models.ErrorDuplicateText со списком прошлых строк и задач
+func CheckDuplicateText(ctx context.Context, texts TextHistory, text string) error {
+	matches, err := texts.FindSimilarTexts(ctx, text)
+	if err != nil {
+		slog.WarnContext(ctx, "Не удалось проверить повтор текста", "ERROR", err)
+		return nil
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	described := make([]string, 0, maxTextMatches)
+	for _, match := range matches[:min(len(matches), maxTextMatches)] {
+		described = append(described, fmt.Sprintf("строка %s, задача %d от %s (совпадение %.0f%%)",
+			match.Row, match.TaskId, match.Time.Format("02.01.2006"), match.Similarity*100))
+	}
+	if len(matches) > maxTextMatches {
+		described = append(described, fmt.Sprintf("и ещё %d", len(matches)-maxTextMatches))
+	}
+	return fmt.Errorf("%w: такой текст уже отправлялся: %s", models.ErrorDuplicateText, strings.Join(described, "; "))
+}
+
+// markRow сохраняет этап обработки строки. Ошибка записи этапа не прерывает создание задачи
+func markRow(ctx context.Context, store database.Store, row string, event models.RowEvent) {
+	err := store.SetRowStatus(ctx, row, event)
+	if err != nil {
+		slog.WarnContext(ctx, "Не удалось сохранить этап обработки строки", "STATUS", event.Status, "ERROR", err)
+	}
+}
+
+// RowHash хэш содержимого строки таблицы. По нему видно, что в строку с уже созданной задачей внесли новые данные
+func RowHash(respData *sheets.ValueRange) string {
+	hash := sha256.New()
+	for _, values := range respData.Values {
+		for _, value := range values {
+			fmt.Fprintf(hash, "%s\x1f", strings.TrimSpace(fmt.Sprint(value)))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// CreateTaskFromRow читает строку таблицы, сохраняет её в базу как незавершённую,
+// создаёт по ней задачу и удаляет строку из базы после успешного ответа UNU.
+// Если создать задачу не удалось, строка остаётся в базе до повторной обработки.
+// Каждый этап (reading, validated, sent, created, failed, skipped) записывается в историю строки.
+// Если передан каталог тарифов, задача проверяется по нему до отправки в UNU.
+// Если передана история текстов, повтор отправленного раньше текста останавливает строку,
+// пока оператор не разрешит его через allowDuplicate.
+// Если по строке с тем же содержимым задача уже создана, возвращается её ID и models.ErrorTaskCreated,
+// с allowDuplicate задача создаётся заново. Новое содержимое в той же строке создаёт новую задачу.
+// validator строится один раз на серию строк (RowValidator или RowValidators), а не на каждую строку
+func CreateTaskFromRow(ctx context.Context, client UNUAPI, store database.Store, settings *TaskSettings, tariffs []Tariff, texts TextHistory, validator *validation.Validator, userId int, row string, folderId int, allowDuplicate bool) (int, error) {
+	ctx = logger.WithRow(ctx, row)
+	fail := func(err error) (int, error) {
+		markRow(ctx, store, row, models.RowEvent{Status: models.RowFailed, Reason: err.Error()})
+		return 0, err
+	}
+
+	previous, _ := store.GetRowStatus(ctx, row)
+	markRow(ctx, store, row, models.RowEvent{Status: models.RowReading})
+	resp, err := settings.Sheets.Reader(SheetBot, row)
+	if errors.Is(err, models.ErrorZeroValue) {
+		markRow(ctx, store, row, models.RowEvent{Status: models.RowSkipped, Reason: "строка пустая или заполнена не полностью"})
+		return 0, err
+	}
+	if err != nil {
+		return fail(err)
+	}
+	hash := RowHash(resp)
+	if previous != nil && !allowDuplicate {
+		// Задача, созданная до того, как стали запоминать содержимое, считается созданной по этому же содержимому
+		if created := previous.LastCreated(); created != nil && (created.Hash == "" || created.Hash == hash) {
+			markRow(ctx, store, row, models.RowEvent{Status: models.RowCreated, TaskId: created.TaskId, Hash: created.Hash,
+				Reason: "задача по этой строке уже создана"})
+			return created.TaskId, fmt.Errorf("%w: задача %d", models.ErrorTaskCreated, created.TaskId)
+		}
+	}
+	rowObject, err := CheckRow(validator, userId, row, resp)
+	if err != nil {
+		return fail(err)
+	}
+	if texts != nil && !allowDuplicate {
+		err = CheckDuplicateText(ctx, texts, rowObject.Object.TextDescription)
+		if err != nil {
+			return fail(err)
+		}
+	}
+	err = store.AddRow(ctx, row, rowObject)
+	if err != nil {
+		return fail(err)
+	}
+	params, err := renderTask(resp, settings, tariffs, folderId)
+	if err != nil {
+		return fail(err)
+	}
+	markRow(ctx, store, row, models.RowEvent{Status: models.RowValidated})
+
+	markRow(ctx, store, row, models.RowEvent{Status: models.RowSent})
+	task_id, err := client.Add_task(ctx, params)
+	if err != nil {
+		return fail(err)
+	}
+	markRow(ctx, store, row, models.RowEvent{Status: models.RowCreated, TaskId: task_id, Hash: hash})
+	err = client.Task_limit_add(ctx, task_id, settings.Limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Задача создана, но не удалось добавить ей лимит выполнений", "TASK_ID", task_id, "ERROR", err)
+	}
+	if texts != nil {
+		err = texts.SaveText(ctx, rowObject.Object.TextDescription, models.TextFingerprint{
+			Row:     row,
+			TaskId:  task_id,
+			Project: rowObject.Object.Project,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Не удалось сохранить текст задачи для поиска повторов", "TASK_ID", task_id, "ERROR", err)
+		}
+	}
+	err = store.SaveTaskRow(ctx, task_id, rowObject)
+	if err != nil {
+		slog.ErrorContext(ctx, "Не удалось сохранить строку задачи для проверки отчётов", "TASK_ID", task_id, "ERROR", err)
+	}
+	_, err = store.DelRow(ctx, row)
+	if err != nil {
+		slog.ErrorContext(ctx, "Задача создана, но строку не удалось удалить из базы", "TASK_ID", task_id, "ERROR", err)
+	}
+	return task_id, nil
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/sheets/v4"
+)
+
+func newTestRow() *sheets.ValueRange {
+	return &sheets.ValueRange{
+		Values: [][]interface{}{
+			{"убрир екб", "https://yandex.ru/maps/org/123", "ж", "Отличный сервис, всем советую", "", "12.05.2025"},
+		},
+	}
+}
+
+func TestNewRowObject(t *testing.T) {
+	rowObject := newRowObject(7, newTestRow())
+	assert.Equal(t, 7, rowObject.UserId)
+	assert.Equal(t, "убрир екб", rowObject.Object.Project)
+	assert.Equal(t, 1, rowObject.Object.Gender)
+	assert.Equal(t, "12.05.2025", rowObject.Object.DateOfPublication)
+}
+
+func TestGetDescription(t *testing.T) {
+	descr := getDescription(newTestRow())
+	assert.Contains(t, descr, "https://yandex.ru/maps/org/123")
+	assert.Contains(t, descr, "женский")
+	assert.Contains(t, descr, "12.05.2025")
+	assert.Contains(t, descr, "Отличный сервис, всем советую")
+}
+
+func TestActionValues(t *testing.T) {
+	params := &TaskParams{
+		Name:            "task",
+		Price:           150,
+		TarifId:         3,
+		FolderId:        10,
+		NeedScreen:      true,
+		TargetingGender: 2,
+	}
+	values := params.actionValues()
+	assert.Equal(t, 1, values["need_screen"])
+	assert.Equal(t, 2, values["targeting_gender"])
+	assert.NotContains(t, values, "link")
+	assert.NotContains(t, values, "targeting_geo_country_id")
+}
+
+func TestCheckRow(t *testing.T) {
+	ctx := context.TODO()
+	store := database.NewMemoryStore()
+	require.NoError(t, store.AddRow(ctx, "3", models.NewRowObject(7, "убрир екб", "https://yandex.ru/maps/org/1", 1, "Отличный сервис, всем советую", "12.05.2025")))
+
+	// Текст совпадает с необработанной строкой 3
+	_, err := CheckRow(RowValidator(ctx, store, false), 7, "4", newTestRow())
+	require.ErrorIs(t, err, models.ErrorIncorrectData)
+	require.ErrorContains(t, err, "строке 3")
+
+	// Оператор разрешил повтор
+	_, err = CheckRow(RowValidator(ctx, store, true), 7, "4", newTestRow())
+	require.NoError(t, err)
+
+	// Сама строка 3 повтором не считается
+	rowObject, err := CheckRow(RowValidator(ctx, store, false), 7, "3", newTestRow())
+	require.NoError(t, err)
+	require.Equal(t, "убрир екб", rowObject.Object.Project)
+
+	row := newTestRow()
+	row.Values[0][1] = "https://example.com/place"
+	_, err = CheckRow(RowValidator(ctx, store, false), 7, "5", row)
+	var fieldErrors validation.Errors
+	require.ErrorAs(t, err, &fieldErrors)
+	require.Equal(t, []string{validation.FieldLink, validation.FieldText}, fieldErrors.Fields())
+}
+
+// countingStore считает загрузки необработанных строк
+type countingStore struct {
+	database.Store
+	listed int
+}
+
+func (s *countingStore) ListPending(ctx context.Context) ([]string, error) {
+	s.listed++
+	return s.Store.ListPending(ctx)
+}
+
+func TestRowValidators(t *testing.T) {
+	ctx := context.TODO()
+	store := &countingStore{Store: database.NewMemoryStore()}
+	require.NoError(t, store.AddRow(ctx, "3", models.NewRowObject(7, "убрир екб", "https://yandex.ru/maps/org/1", 1, "Отличный сервис, всем советую", "12.05.2025")))
+	validators := NewRowValidators(store, time.Hour)
+
+	// Необработанные строки загружаются один раз на серию строк
+	for _, rowNumber := range []string{"4", "5", "6"} {
+		_, err := CheckRow(validators.Get(ctx, false), 7, rowNumber, newTestRow())
+		require.ErrorContains(t, err, "строке 3")
+	}
+	assert.Equal(t, 1, store.listed)
+
+	// Текст проверенной строки ищется в следующих строках без новой загрузки
+	row := newTestRow()
+	row.Values[0][3] = "Новый текст отзыва"
+	_, err := CheckRow(validators.Get(ctx, false), 7, "7", row)
+	require.NoError(t, err)
+	_, err = CheckRow(validators.Get(ctx, false), 7, "8", row)
+	require.ErrorContains(t, err, "строке 7")
+	assert.Equal(t, 1, store.listed)
+
+	_, err = CheckRow(validators.Get(ctx, true), 7, "8", row)
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.listed)
+
+	// Устаревшая проверка загружается заново
+	validators = NewRowValidators(store, 0)
+	validators.Get(ctx, false)
+	time.Sleep(time.Millisecond)
+	validators.Get(ctx, false)
+	assert.Equal(t, 3, store.listed)
+}
+
+// fakeTextHistory история текстов в памяти для тестов
+type fakeTextHistory struct {
+	matches []models.TextMatch
+	err     error
+}
+
+func (h *fakeTextHistory) FindSimilarTexts(ctx context.Context, text string) ([]models.TextMatch, error) {
+	return h.matches, h.err
+}
+
+func (h *fakeTextHistory) SaveText(ctx context.Context, text string, fp models.TextFingerprint) error {
+	return nil
+}
+
+func TestCheckDuplicateText(t *testing.T) {
+	ctx := context.TODO()
+	require.NoError(t, CheckDuplicateText(ctx, &fakeTextHistory{}, "текст"))
+	// Недоступная история не останавливает создание задач
+	require.NoError(t, CheckDuplicateText(ctx, &fakeTextHistory{err: models.ErrorDatabase}, "текст"))
+
+	match := func(row string, task_id int, similarity float64) models.TextMatch {
+		return models.TextMatch{
+			TextFingerprint: models.TextFingerprint{Row: row, TaskId: task_id, Time: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)},
+			Similarity:      similarity,
+		}
+	}
+	err := CheckDuplicateText(ctx, &fakeTextHistory{matches: []models.TextMatch{
+		match("5", 1234, 1), match("9", 1240, 0.87), match("11", 1250, 0.85), match("12", 1251, 0.81),
+	}}, "текст")
+	require.ErrorIs(t, err, models.ErrorDuplicateText)
+	require.ErrorContains(t, err, "строка 5, задача 1234 от 01.09.2025 (совпадение 100%); строка 9, задача 1240 от 01.09.2025 (совпадение 87%)")
+	require.ErrorContains(t, err, "и ещё 1")
+}
+
+// fakeUNU клиент UNU для CreateTaskFromRow: создаёт задачи с ID по порядку или возвращает addErr.
+// Остальные методы не вызываются
+type fakeUNU struct {
+	UNUAPI
+	addErr  error
+	created []*TaskParams
+}
+
+func (f *fakeUNU) Add_task(ctx context.Context, params *TaskParams) (int, error) {
+	if f.addErr != nil {
+		return 0, f.addErr
+	}
+	f.created = append(f.created, params)
+	return 1000 + len(f.created), nil
+}
+
+func (f *fakeUNU) Task_limit_add(ctx context.Context, task_id, add_to_limit int) error { return nil }
+
+func TestCreateTaskFromRowStatuses(t *testing.T) {
+	emptyText := newTestRow()
+	emptyText.Values[0][3] = ""
+	cases := []struct {
+		name     string
+		rows     map[string]*sheets.ValueRange
+		sheetErr error
+		tariffs  []Tariff
+		texts    *fakeTextHistory
+		addErr   error
+		wantErr  error
+		statuses []string
+	}{
+		{
+			name:     "created",
+			rows:     map[string]*sheets.ValueRange{"2": newTestRow()},
+			statuses: []string{models.RowReading, models.RowValidated, models.RowSent, models.RowCreated},
+		},
+		{
+			name:     "empty row",
+			rows:     map[string]*sheets.ValueRange{},
+			wantErr:  models.ErrorZeroValue,
+			statuses: []string{models.RowReading, models.RowSkipped},
+		},
+		{
+			name:     "sheet error",
+			sheetErr: models.ErrorGoogleSheet,
+			wantErr:  models.ErrorGoogleSheet,
+			statuses: []string{models.RowReading, models.RowFailed},
+		},
+		{
+			name:     "invalid row",
+			rows:     map[string]*sheets.ValueRange{"2": emptyText},
+			statuses: []string{models.RowReading, models.RowFailed},
+		},
+		{
+			name:     "duplicate text",
+			rows:     map[string]*sheets.ValueRange{"2": newTestRow()},
+			texts:    &fakeTextHistory{matches: []models.TextMatch{{TextFingerprint: models.TextFingerprint{Row: "5", TaskId: 1234}, Similarity: 1}}},
+			wantErr:  models.ErrorDuplicateText,
+			statuses: []string{models.RowReading, models.RowFailed},
+		},
+		{
+			name:     "unknown tariff",
+			rows:     map[string]*sheets.ValueRange{"2": newTestRow()},
+			tariffs:  []Tariff{},
+			wantErr:  models.ErrorUnknownTariff,
+			statuses: []string{models.RowReading, models.RowFailed},
+		},
+		{
+			name:     "unu error",
+			rows:     map[string]*sheets.ValueRange{"2": newTestRow()},
+			addErr:   models.ErrorUNUAPI,
+			wantErr:  models.ErrorUNUAPI,
+			statuses: []string{models.RowReading, models.RowValidated, models.RowSent, models.RowFailed},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+			store := database.NewMemoryStore()
+			client := &fakeUNU{addErr: tc.addErr}
+			settings := &TaskSettings{
+				Price:   15,
+				TarifId: 4,
+				Limit:   2,
+				Sheets:  &fakeSheets{rows: tc.rows, err: tc.sheetErr},
+			}
+			var texts TextHistory
+			if tc.texts != nil {
+				texts = tc.texts
+			}
+
+			task_id, err := CreateTaskFromRow(ctx, client, store, settings, tc.tariffs, texts, RowValidator(ctx, store, false), 7, "2", 5, false)
+			state, stateErr := store.GetRowStatus(ctx, "2")
+			require.NoError(t, stateErr)
+			var statuses []string
+			for _, event := range state.History {
+				statuses = append(statuses, event.Status)
+			}
+			assert.Equal(t, tc.statuses, statuses)
+
+			if tc.statuses[len(tc.statuses)-1] == models.RowCreated {
+				require.NoError(t, err)
+				assert.Equal(t, 1001, task_id)
+				assert.Equal(t, task_id, state.TaskId)
+				return
+			}
+			require.Error(t, err)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			}
+			assert.Empty(t, client.created)
+			if state.Status == models.RowFailed {
+				assert.Equal(t, err.Error(), state.Reason)
+				assert.Equal(t, 1, state.Attempts)
+			}
+		})
+	}
+}
+
+// По строке с тем же содержимым задача второй раз не создаётся, новое содержимое той же строки создаёт новую задачу
+func TestCreateTaskFromRowAgain(t *testing.T) {
+	ctx := context.TODO()
+	store := database.NewMemoryStore()
+	client := &fakeUNU{}
+	row := newTestRow()
+	settings := &TaskSettings{Price: 15, TarifId: 4, Limit: 2, Sheets: &fakeSheets{rows: map[string]*sheets.ValueRange{"7": row}}}
+	create := func(allowDuplicate bool) (int, error) {
+		return CreateTaskFromRow(ctx, client, store, settings, nil, nil, RowValidator(ctx, store, allowDuplicate), 7, "7", 5, allowDuplicate)
+	}
+
+	task_id, err := create(false)
+	require.NoError(t, err)
+	assert.Equal(t, 1001, task_id)
+
+	// Строку снова поставили в очередь, содержимое не менялось
+	require.NoError(t, store.SetRowStatus(ctx, "7", models.RowEvent{Status: models.RowQueued}))
+	task_id, err = create(false)
+	require.ErrorIs(t, err, models.ErrorTaskCreated)
+	assert.Equal(t, 1001, task_id)
+	assert.Len(t, client.created, 1)
+	state, err := store.GetRowStatus(ctx, "7")
+	require.NoError(t, err)
+	assert.Equal(t, models.RowCreated, state.Status)
+
+	// Оператор попросил создать заново
+	task_id, err = create(true)
+	require.NoError(t, err)
+	assert.Equal(t, 1002, task_id)
+
+	// В строку внесли новый отзыв
+	row.Values[0][3] = "Новый отзыв о другом визите"
+	task_id, err = create(false)
+	require.NoError(t, err)
+	assert.Equal(t, 1003, task_id)
+	assert.Contains(t, client.created[2].Descr, "Новый отзыв о другом визите")
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/validation"
+)
+
+// TaskPreview задача, которую создал бы CreateTaskFromRow по строке, или причина, по которой строку не отправить
+type TaskPreview struct {
+	Row   int         `json:"row"`
+	Task  *TaskParams `json:"task,omitempty"`
+	Limit int         `json:"limit,omitempty"`
+	Cost  float64     `json:"cost,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// Preview итог пробного прогона по нескольким строкам
+type Preview struct {
+	Tasks     []TaskPreview `json:"tasks"`
+	Ready     int           `json:"ready"`
+	Failed    int           `json:"failed"`
+	TotalCost float64       `json:"total_cost"`
+}
+
+// PreviewTasks выполняет для каждой строки те же чтения и проверки, что CreateTaskFromRow,
+// но ничего не пишет в базу и не вызывает add_task. Повтор текста ищется и среди строк самой пачки,
+// как если бы они уже были сохранены
+func PreviewTasks(ctx context.Context, store database.Store, settings *TaskSettings, tariffs []Tariff, texts TextHistory, jobs []models.TaskJob) *Preview {
+	preview := &Preview{Tasks: make([]TaskPreview, 0, len(jobs))}
+	strict := RowValidator(ctx, store, false)
+	for _, job := range jobs {
+		validator := strict
+		if job.AllowDuplicate {
+			validator = RowValidator(ctx, store, true)
+		}
+		task := TaskPreview{Row: job.Row}
+		params, err := previewTask(ctx, validator, settings, tariffs, texts, job)
+		if err != nil {
+			task.Error = err.Error()
+			preview.Failed++
+		} else {
+			task.Task = params
+			task.Limit = settings.Limit
+			task.Cost = settings.RowCost()
+			preview.Ready++
+			preview.TotalCost += task.Cost
+		}
+		preview.Tasks = append(preview.Tasks, task)
+	}
+	return preview
+}
+
+// previewTask собирает параметры задачи по строке job.Row без побочных эффектов
+func previewTask(ctx context.Context, validator *validation.Validator, settings *TaskSettings, tariffs []Tariff, texts TextHistory, job models.TaskJob) (*TaskParams, error) {
+	row := strconv.Itoa(job.Row)
+	resp, err := settings.Sheets.Reader(SheetBot, row)
+	if err != nil {
+		return nil, err
+	}
+	rowObject, err := CheckRow(validator, int(job.UserId), row, resp)
+	if err != nil {
+		return nil, err
+	}
+	if texts != nil && !job.AllowDuplicate {
+		err = CheckDuplicateText(ctx, texts, rowObject.Object.TextDescription)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return renderTask(resp, settings, tariffs, job.FolderId)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/sheets/v4"
+)
+
+// fakeSheets таблица в памяти: строки листа BOT и один шаблон для любой ячейки REFERENCE
+type fakeSheets struct {
+	rows map[string]*sheets.ValueRange
+	// Ошибка чтения любой строки, например недоступная таблица
+	err error
+}
+
+func (s *fakeSheets) Reader(spreadsheetName, rowNumber string) (*sheets.ValueRange, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	resp, ok := s.rows[rowNumber]
+	if !ok {
+		return nil, models.ErrorZeroValue
+	}
+	return resp, nil
+}
+
+func (s *fakeSheets) ReaderFromCell(spreadsheetName, cell string) (*sheets.ValueRange, error) {
+	return &sheets.ValueRange{Values: [][]interface{}{{"ОПУБЛИКОВАТЬ ГОТОВЫЙ"}}}, nil
+}
+
+func (s *fakeSheets) Writer(spreadsheetName string, values [][]interface{}) error { return nil }
+
+func TestPreviewTasks(t *testing.T) {
+	ctx := context.TODO()
+	store := database.NewMemoryStore()
+	copyRow := newTestRow()
+	copyRow.Values[0][0] = "другой проект"
+	settings := &TaskSettings{
+		Price:   15,
+		TarifId: 4,
+		Limit:   2,
+		Sheets:  &fakeSheets{rows: map[string]*sheets.ValueRange{"2": newTestRow(), "3": copyRow}},
+	}
+	jobs := []models.TaskJob{
+		{Row: 2, FolderId: 5, UserId: 7},
+		{Row: 3, FolderId: 5, UserId: 7},
+		{Row: 4, FolderId: 5, UserId: 7},
+	}
+
+	preview := PreviewTasks(ctx, store, settings, nil, &fakeTextHistory{}, jobs)
+	require.Len(t, preview.Tasks, 3)
+	assert.Equal(t, 1, preview.Ready)
+	assert.Equal(t, 2, preview.Failed)
+	assert.Equal(t, 30.0, preview.TotalCost)
+
+	task := preview.Tasks[0].Task
+	require.NotNil(t, task)
+	assert.Equal(t, "12.05.2025 ОПУБЛИКОВАТЬ ГОТОВЫЙ женский отзыв", task.Name)
+	assert.Equal(t, "https://yandex.ru/maps/org/123", task.Link)
+	assert.Equal(t, 5, task.FolderId)
+	assert.Equal(t, 1, task.TargetingGender)
+	// Строка 3 повторяет текст строки 2 из той же пачки
+	assert.Contains(t, preview.Tasks[1].Error, "строке 2")
+	assert.Contains(t, preview.Tasks[2].Error, models.ErrorZeroValue.Error())
+
+	// Пробный прогон ничего не сохраняет
+	pending, err := store.ListPending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	_, err = store.GetRowStatus(ctx, "2")
+	assert.Error(t, err)
+
+	jobs[1].AllowDuplicate = true
+	preview = PreviewTasks(ctx, store, settings, nil, &fakeTextHistory{}, jobs)
+	assert.Equal(t, 2, preview.Ready)
+	assert.Equal(t, 60.0, preview.TotalCost)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// Тарифы меняются редко, поэтому список хранится в Redis и запрашивается у UNU не чаще раза в tariffsTTL
+const tariffsTTL = 6 * time.Hour
+
+// flag признак из ответа UNU: приходит как 0/1, "0"/"1" или true/false
+type flag bool
+
+func (f *flag) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "1", "true":
+		*f = true
+	case "0", "false", "", "null":
+		*f = false
+	default:
+		return models.ErrorUnmarshallJSON
+	}
+	return nil
+}
+
+// Tariff тариф из ответа get_tariffs
+type Tariff struct {
+	ID              json.Number `json:"id"`
+	Name            string      `json:"name"`
+	MinPrice        json.Number `json:"min_price"`
+	TargetingGender flag        `json:"targeting_gender"`
+	TargetingGeo    flag        `json:"targeting_geo"`
+}
+
+// Выходные данные get_tariffs
+
+// tariffs – массив тарифов: минимальная цена выполнения и поддерживаемый таргетинг
+
+func (c *Client) Get_tariffs(ctx context.Context) ([]Tariff, error) {
+	type Response struct {
+		Success bool     `json:"success"`
+		Errors  string   `json:"errors"`
+		Tariffs []Tariff `json:"tariffs"`
+	}
+
+	slog.Info("goes to API for get tariffs")
+	bytesRes := c.post(ctx, "get_tariffs", nil)
+	var response Response
+	err := json.Unmarshal([]byte(bytesRes), &response)
+	if err != nil {
+		slog.Warn("Ошибка парсинга JSON:", "ERROR:", err)
+		return nil, models.ErrorUnmarshallJSON
+	}
+	if !response.Success {
+		return nil, fmt.Errorf("%w: %s", models.ErrorUNUAPI, response.Errors)
+	}
+	return response.Tariffs, nil
+}
+
+// CachedTariffs возвращает тарифы из кэша, а если их там нет — запрашивает у UNU и кэширует
+func CachedTariffs(ctx context.Context, db *database.Db, rdb *redis.Client, clienObj UNUAPI) ([]Tariff, error) {
+	data, err := db.GetTariffs(ctx, rdb)
+	if err == nil {
+		var tariffs []Tariff
+		err = json.Unmarshal(data, &tariffs)
+		if err == nil {
+			return tariffs, nil
+		}
+		slog.Warn("Кэш тарифов повреждён, запрашиваем заново", "ERROR", err)
+	}
+	tariffs, err := clienObj.Get_tariffs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(tariffs)
+	if err == nil {
+		db.SaveTariffs(ctx, rdb, data, tariffsTTL)
+	}
+	return tariffs, nil
+}
+
+// FindTariff ищет тариф по ID
+func FindTariff(tariffs []Tariff, tarif_id int) (*Tariff, error) {
+	for i := range tariffs {
+		if tariffs[i].ID.String() == fmt.Sprint(tarif_id) {
+			return &tariffs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d", models.ErrorUnknownTariff, tarif_id)
+}
+
+// Validate проверяет, что тариф примет задачу: цена не ниже минимальной и тариф поддерживает выбранный таргетинг
+func (t *Tariff) Validate(params *TaskParams) error {
+	minPrice, err := t.MinPrice.Float64()
+	if err == nil && params.Price < minPrice {
+		return fmt.Errorf("%w: %v ₽ при минимуме %v ₽ для тарифа '%s'", models.ErrorTariffPrice, params.Price, minPrice, t.Name)
+	}
+	if params.TargetingGender != 0 && !t.TargetingGender {
+		return fmt.Errorf("%w: тариф '%s' не поддерживает таргетинг по полу", models.ErrorTariffTargeting, t.Name)
+	}
+	if params.TargetingGeoCountryId != 0 && !t.TargetingGeo {
+		return fmt.Errorf("%w: тариф '%s' не поддерживает геотаргетинг", models.ErrorTariffTargeting, t.Name)
+	}
+	return nil
+}
+
+// ValidateTariff проверяет задачу по каталогу тарифов
+func ValidateTariff(tariffs []Tariff, params *TaskParams) error {
+	tariff, err := FindTariff(tariffs, params.TarifId)
+	if err != nil {
+		return err
+	}
+	return tariff.Validate(params)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTariffUnmarshal(t *testing.T) {
+	var tariffs []Tariff
+	err := json.Unmarshal([]byte(`[
+		{"id": 3, "name": "Отзывы", "min_price": "12.5", "targeting_gender": 1, "targeting_geo": "0"},
+		{"id": "4", "name": "Отзывы+", "min_price": 20, "targeting_gender": true, "targeting_geo": true}
+	]`), &tariffs)
+	require.NoError(t, err)
+	require.Len(t, tariffs, 2)
+	assert.True(t, bool(tariffs[0].TargetingGender))
+	assert.False(t, bool(tariffs[0].TargetingGeo))
+	assert.True(t, bool(tariffs[1].TargetingGeo))
+}
+
+func TestValidateTariff(t *testing.T) {
+	tariffs := []Tariff{
+		{ID: "3", Name: "Отзывы", MinPrice: "12.5", TargetingGender: true},
+		{ID: "4", Name: "Отзывы+", MinPrice: "20", TargetingGender: true, TargetingGeo: true},
+	}
+
+	require.NoError(t, ValidateTariff(tariffs, &TaskParams{TarifId: 3, Price: 12.5, TargetingGender: 1}))
+	require.NoError(t, ValidateTariff(tariffs, &TaskParams{TarifId: 4, Price: 25, TargetingGeoCountryId: 1}))
+
+	require.ErrorIs(t, ValidateTariff(tariffs, &TaskParams{TarifId: 5, Price: 25}), models.ErrorUnknownTariff)
+	require.ErrorIs(t, ValidateTariff(tariffs, &TaskParams{TarifId: 3, Price: 10}), models.ErrorTariffPrice)
+	require.ErrorIs(t, ValidateTariff(tariffs, &TaskParams{TarifId: 3, Price: 15, TargetingGeoCountryId: 1}), models.ErrorTariffTargeting)
+
+	settings := &TaskSettings{Price: 15, TarifId: 4, Limit: 1}
+	require.ErrorIs(t, settings.Validate(tariffs), models.ErrorTariffPrice)
+}
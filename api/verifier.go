@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/utils"
+)
+
+const (
+	// Совпадение текста, начиная с которого отчёт можно принимать
+	similarityApprove = 0.8
+	// Совпадение текста, ниже которого отчёт стоит отклонить
+	similarityReject = 0.5
+)
+
+var urlRegexp = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// Verdict рекомендация автопроверки по отчёту. Решение всё равно принимает оператор
+type Verdict struct {
+	Checked  bool     // была ли строка задачи, с которой можно сравнить отчёт
+	Approve  bool     // рекомендуем принять
+	Score    float64  // совпадение текста отчёта с текстом из таблицы, от 0 до 1
+	Platform string   // площадка, на которой нужно было опубликовать отзыв
+	Reasons  []string // причины для отклонения
+	Warnings []string // что стоит посмотреть глазами, но не повод отклонять
+}
+
+// VerifyReport сравнивает отчёт исполнителя со строкой таблицы, по которой создана задача:
+// есть ли скриншот, есть ли ссылка на нужную площадку и совпадает ли опубликованный текст
+func VerifyReport(report Report, row *models.RowObject) *Verdict {
+	verdict := &Verdict{}
+	if row == nil {
+		verdict.Warnings = append(verdict.Warnings, "Нет данных о задаче, проверьте вручную")
+		return verdict
+	}
+	verdict.Checked = true
+
+	if len(report.Files) == 0 {
+		verdict.Reasons = append(verdict.Reasons, "Нет скриншота опубликованного отзыва")
+	}
+
+	matcher := NewSiteMatcher()
+	links := urlRegexp.FindAllString(report.Message, -1)
+	platform, err := matcher.GetPlatformForURL(row.Object.Link)
+	verdict.Platform = platform
+	switch {
+	case len(links) == 0:
+		verdict.Reasons = append(verdict.Reasons, "Нет ссылки на опубликованный отзыв")
+	case err != nil:
+		verdict.Warnings = append(verdict.Warnings, "Не удалось определить площадку задачи, проверьте ссылку вручную")
+	default:
+		found := false
+		for _, link := range links {
+			if linkPlatform, err := matcher.GetPlatformForURL(link); err == nil && linkPlatform == platform {
+				found = true
+				break
+			}
+		}
+		if !found {
+			verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("Отзыв опубликован не на той площадке (нужна %s)", platform))
+		}
+	}
+
+	if strings.TrimSpace(row.Object.TextDescription) != "" {
+		text := urlRegexp.ReplaceAllString(report.Message, " ")
+		verdict.Score = utils.Containment(row.Object.TextDescription, text)
+		switch {
+		case verdict.Score < similarityReject:
+			verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("Текст отзыва не совпадает с заданием (совпадение %.0f%%)", verdict.Score*100))
+		case verdict.Score < similarityApprove:
+			verdict.Warnings = append(verdict.Warnings, fmt.Sprintf("Текст отзыва отличается от задания (совпадение %.0f%%)", verdict.Score*100))
+		}
+	}
+
+	verdict.Approve = len(verdict.Reasons) == 0
+	return verdict
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadReportFixtures(t *testing.T) map[string]Report {
+	data, err := os.ReadFile("testdata/reports.json")
+	require.NoError(t, err)
+	var response struct {
+		Reports []Report `json:"reports"`
+	}
+	require.NoError(t, json.Unmarshal(data, &response))
+	reports := make(map[string]Report, len(response.Reports))
+	for _, report := range response.Reports {
+		reports[report.ID.String()] = report
+	}
+	return reports
+}
+
+func TestVerifyReport(t *testing.T) {
+	reports := loadReportFixtures(t)
+	rows := map[string]*models.RowObject{
+		"501": models.NewRowObject(1, "убрир екб", "https://yandex.ru/maps/org/ubrir/1234", 1,
+			"Отличный банк, быстро открыли счёт, менеджер всё подробно объяснил.", "12.05.2025"),
+	}
+
+	type useCase struct {
+		approve bool
+		reasons int
+	}
+	useCases := map[string]useCase{
+		"9001": {approve: true, reasons: 0},  // всё в порядке
+		"9002": {approve: false, reasons: 1}, // нет скриншота
+		"9003": {approve: false, reasons: 1}, // другая площадка
+		"9004": {approve: false, reasons: 1}, // другой текст
+		"9005": {approve: false, reasons: 1}, // нет ссылки
+	}
+	for id, value := range useCases {
+		report := reports[id]
+		verdict := VerifyReport(report, rows[report.TaskId.String()])
+		assert.True(t, verdict.Checked, id)
+		assert.Equal(t, value.approve, verdict.Approve, id)
+		assert.Len(t, verdict.Reasons, value.reasons, id)
+		assert.Equal(t, "Яндекс Карты", verdict.Platform, id)
+	}
+
+	verdict := VerifyReport(reports["9001"], rows["501"])
+	assert.Equal(t, 1.0, verdict.Score)
+
+	verdict = VerifyReport(reports["9006"], rows["777"])
+	assert.False(t, verdict.Checked)
+	assert.False(t, verdict.Approve)
+	assert.NotEmpty(t, verdict.Warnings)
+}
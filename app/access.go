@@ -0,0 +1,224 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// role роль пользователя Telegram. Администраторы из настроек имеют доступ всегда, остальные роли хранятся в Redis
+func (a *App) role(ctx context.Context, userId int64) string {
+	if a.admins[userId] {
+		return dbmodels.RoleAdmin
+	}
+	role, err := a.db.GetRole(ctx, a.rdb, userId)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось проверить роль пользователя", "USER", userId, "ERROR", err)
+		return ""
+	}
+	return role
+}
+
+// updateSender возвращает автора сообщения или нажатия на кнопку
+func updateSender(update *models.Update) *models.User {
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		return update.Message.From
+	case update.CallbackQuery != nil:
+		return &update.CallbackQuery.From
+	}
+	return nil
+}
+
+// requireRole пропускает к обработчику только пользователей с ролью не ниже required
+func (a *App) requireRole(required string) bot.Middleware {
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+			user := updateSender(update)
+			if user == nil {
+				return
+			}
+			role := a.role(ctx, user.ID)
+			if dbmodels.RoleLevel(role) >= dbmodels.RoleLevel(required) {
+				next(ctx, b, update)
+				return
+			}
+			a.denyAccess(ctx, b, update, user, role, required)
+		}
+	}
+}
+
+func (a *App) denyAccess(ctx context.Context, b *bot.Bot, update *models.Update, user *models.User, role, required string) {
+	text := ""
+	var chatID int64
+	if update.Message != nil {
+		text = update.Message.Text
+		chatID = update.Message.Chat.ID
+	} else if update.CallbackQuery != nil {
+		text = update.CallbackQuery.Data
+		if update.CallbackQuery.Message.Message != nil {
+			chatID = update.CallbackQuery.Message.Message.Chat.ID
+		}
+		b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+		})
+	}
+	a.logger.WarnContext(ctx, "Попытка доступа без необходимых прав", "USER", user.ID, "USERNAME", user.Username, "TEXT", text, "ROLE", role, "REQUIRED", required)
+	err := a.db.AddAuditEntry(ctx, a.rdb, &database.AuditEntry{
+		UserId:   user.ID,
+		Username: user.Username,
+		Text:     text,
+		Role:     role,
+		Required: required,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось записать попытку доступа в журнал", "ERROR", err)
+	}
+	if chatID == 0 {
+		return
+	}
+	msg := fmt.Sprintf("⛔ Извините, у вас нет доступа к этой команде.\nВаш ID: %d\nЕсли доступ нужен, передайте этот ID администратору.", user.ID)
+	if role != "" {
+		msg = fmt.Sprintf("⛔ Извините, для этой команды нужна роль '%s', а у вас '%s'.", required, role)
+	}
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   msg,
+	})
+}
+
+// commandArgs возвращает аргументы команды, переданные через пробел
+func commandArgs(text string) []string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields[1:]
+}
+
+func (a *App) grantRole(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for grant role", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+	args := commandArgs(update.Message.Text)
+	if len(args) != 2 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Использование: /grant <ID пользователя> <admin|operator|viewer>",
+		})
+		return
+	}
+	userId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "ID пользователя должен быть числом.",
+		})
+		return
+	}
+	role := strings.ToLower(args[1])
+	err = a.db.SetRole(ctx, a.rdb, userId, role)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Ошибка выдачи роли:", "ERROR:", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ Не удалось выдать роль: %v", err),
+		})
+		return
+	}
+	a.logger.InfoContext(ctx, "Выдана роль", "ADMIN", update.Message.From.ID, "USER", userId, "ROLE", role)
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   fmt.Sprintf("✅ Пользователю %d выдана роль '%s'", userId, role),
+	})
+}
+
+func (a *App) revokeRole(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for revoke role", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+	args := commandArgs(update.Message.Text)
+	if len(args) != 1 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Использование: /revoke <ID пользователя>",
+		})
+		return
+	}
+	userId, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "ID пользователя должен быть числом.",
+		})
+		return
+	}
+	if a.admins[userId] {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Этот администратор задан в конфигурации (TG_ADMIN_IDS), отозвать его роль из бота нельзя.",
+		})
+		return
+	}
+	deleted, err := a.db.DelRole(ctx, a.rdb, userId)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Ошибка отзыва роли:", "ERROR:", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ Не удалось отозвать роль: %v", err),
+		})
+		return
+	}
+	if deleted == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("У пользователя %d нет выданной роли.", userId),
+		})
+		return
+	}
+	a.logger.InfoContext(ctx, "Отозвана роль", "ADMIN", update.Message.From.ID, "USER", userId)
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   fmt.Sprintf("✅ У пользователя %d отозван доступ", userId),
+	})
+}
+
+func (a *App) listUsers(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for list users", update.Message.Chat.Username, update.Message.Text))
+	roles, err := a.db.ListRoles(ctx, a.rdb)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Ошибка получения списка ролей:", "ERROR:", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   fmt.Sprintf("❌ Не удалось получить список пользователей: %v", err),
+		})
+		return
+	}
+	for userId := range a.admins {
+		roles[userId] = dbmodels.RoleAdmin
+	}
+	ids := make([]int64, 0, len(roles))
+	for userId := range roles {
+		ids = append(ids, userId)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	result_text := "Пользователи с доступом:"
+	for _, userId := range ids {
+		result_text += fmt.Sprintf("\nID: %d. Роль: %s", userId, roles[userId])
+		if a.admins[userId] {
+			result_text += " (из конфигурации)"
+		}
+	}
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   result_text,
+	})
+}
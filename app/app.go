@@ -0,0 +1,242 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/config"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/logger"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// App бот со всеми зависимостями. Обработчики команд — методы App, поэтому бота можно собрать
+// с подделками вместо UNU, Google таблицы и хранилища и проверить на синтетических обновлениях
+type App struct {
+	cfg      *config.Config
+	client   api.UNUAPI
+	store    database.Store
+	sheets   api.SheetSource
+	settings *api.TaskSettings
+	logger   *slog.Logger
+
+	// Redis хранит роли, блокировки строк, очередь заданий и историю текстов
+	db     *database.Db
+	rdb    *redis.Client
+	admins map[int64]bool
+
+	states   map[int64]*UserState
+	statesMu sync.RWMutex
+	// chatLocks блокировки чатов для lockChat, создаются под statesMu
+	chatLocks map[int64]*sync.Mutex
+	// redisDown отмечает, что администраторам уже сообщили о потере связи с Redis
+	redisDown atomic.Bool
+}
+
+// Deps внешние зависимости App. Если Client или Sheets не заданы, они создаются по настройкам,
+// вместо пустого Logger используется slog.Default()
+type Deps struct {
+	Client api.UNUAPI
+	Store  database.Store
+	Sheets api.SheetSource
+	DB     *database.Db
+	Redis  *redis.Client
+	Logger *slog.Logger
+}
+
+func New(cfg *config.Config, deps Deps) *App {
+	settings := api.NewTaskSettings(cfg)
+	if deps.Sheets != nil {
+		settings.Sheets = deps.Sheets
+	}
+	if deps.Client == nil {
+		deps.Client = api.NewClient(cfg.UNU.URL, cfg.UNU.Token)
+	}
+	if deps.Logger == nil {
+		deps.Logger = slog.Default()
+	}
+	admins := make(map[int64]bool)
+	for _, userId := range cfg.Telegram.AdminIDs {
+		admins[userId] = true
+	}
+	if len(admins) == 0 {
+		deps.Logger.Warn("TG_ADMIN_IDS не задан, выдавать роли будет некому")
+	}
+	return &App{
+		cfg:       cfg,
+		client:    deps.Client,
+		store:     deps.Store,
+		sheets:    settings.Sheets,
+		settings:  settings,
+		logger:    deps.Logger,
+		db:        deps.DB,
+		rdb:       deps.Redis,
+		admins:    admins,
+		states:    make(map[int64]*UserState),
+		chatLocks: make(map[int64]*sync.Mutex),
+	}
+}
+
+// Connect подключается к Redis, открывает хранилище строк по настройкам и собирает App.
+// Вызов close закрывает хранилище и соединения с Redis
+func Connect(ctx context.Context, cfg *config.Config) (a *App, close func(), err error) {
+	db, rdb, err := connectDB(ctx, cfg)
+	if err != nil {
+		slog.Error("Нет связи с Redis. Проверьте DB_HOST, DB_PASSWORD и что Redis запущен", "ERROR", err)
+		return nil, nil, err
+	}
+	store, err := connectStore(cfg, db, rdb)
+	if err != nil {
+		rdb.Close()
+		return nil, nil, err
+	}
+	if backend := cfg.Store.Backend; backend == database.BackendRedis || backend == "" {
+		migrateBareRowKeys(ctx, db, rdb)
+	}
+	close = func() {
+		store.Close()
+		rdb.Close()
+		slog.Info("Подключение к Redis закрыто")
+	}
+	return New(cfg, Deps{Store: store, DB: db, Redis: rdb}), close, nil
+}
+
+// NewBot создаёт бота Telegram с токеном из настроек и регистрирует обработчики команд.
+// opts дополняют настройки бота, например адресом тестового сервера
+func (a *App) NewBot(opts ...bot.Option) (*bot.Bot, error) {
+	opts = append([]bot.Option{
+		bot.WithDefaultHandler(a.requireRole(dbmodels.RoleViewer)(a.handler)),
+		bot.WithMiddlewares(withUpdateContext),
+	}, opts...)
+
+	b, err := bot.New(a.cfg.Telegram.Token, opts...)
+	if err != nil {
+		return nil, err
+	}
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypeExact, a.welcomeMessage)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/help", bot.MatchTypeExact, a.helpMessage)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/balance", bot.MatchTypeExact, a.checkBalance, a.requireRole(dbmodels.RoleViewer))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/tariffs", bot.MatchTypeExact, a.listTariffs, a.requireRole(dbmodels.RoleViewer))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/expenses", bot.MatchTypePrefix, a.expensesReport, a.requireRole(dbmodels.RoleViewer))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/get_folders_id", bot.MatchTypeExact, a.getFoldersId, a.requireRole(dbmodels.RoleViewer))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/create_folder", bot.MatchTypeExact, a.createFolder, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/delete_folder", bot.MatchTypeExact, a.deleteFolder, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/row", bot.MatchTypePrefix, a.rowStatus, a.requireRole(dbmodels.RoleViewer))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/create_task", bot.MatchTypePrefix, a.createTask, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/failed", bot.MatchTypeExact, a.failedJobs, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/retry", bot.MatchTypePrefix, a.retryJobs, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/drop", bot.MatchTypePrefix, a.dropJobs, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/fix", bot.MatchTypePrefix, a.fixJob, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/delete_task", bot.MatchTypePrefix, a.deleteTask, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/pause_task", bot.MatchTypePrefix, a.pauseTask, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/play_task", bot.MatchTypePrefix, a.playTask, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/move_task", bot.MatchTypePrefix, a.moveTask, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/edit_task", bot.MatchTypePrefix, a.editTask, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/add_limit", bot.MatchTypePrefix, a.addLimit, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, CALLBACK_CONFIRM, bot.MatchTypePrefix, a.handleConfirmCallback, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, CALLBACK_FOLDER, bot.MatchTypePrefix, a.handleFolderCallback, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, CALLBACK_EDIT, bot.MatchTypePrefix, a.handleEditFieldCallback, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/reports", bot.MatchTypePrefix, a.reviewReports, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, CALLBACK_REPORT, bot.MatchTypePrefix, a.handleReportCallback, a.requireRole(dbmodels.RoleOperator))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/grant", bot.MatchTypePrefix, a.grantRole, a.requireRole(dbmodels.RoleAdmin))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/revoke", bot.MatchTypePrefix, a.revokeRole, a.requireRole(dbmodels.RoleAdmin))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/users", bot.MatchTypeExact, a.listUsers, a.requireRole(dbmodels.RoleAdmin))
+	return b, nil
+}
+
+// withUpdateContext помечает контекст обработчика ID обновления и чата, чтобы связать записи лога одного обновления,
+// и запоминает отправителя для setState
+func withUpdateContext(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		var chatID int64
+		switch {
+		case update.Message != nil:
+			chatID = update.Message.Chat.ID
+		case callbackMessage(update) != nil:
+			// Кнопку могут нажать в группе, тогда чат не совпадает с пользователем
+			chatID = callbackMessage(update).Chat.ID
+		case update.CallbackQuery != nil:
+			chatID = update.CallbackQuery.From.ID
+		}
+		ctx = logger.WithUpdate(ctx, update.ID, chatID)
+		if user := updateSender(update); user != nil {
+			ctx = context.WithValue(ctx, senderKey{}, user.ID)
+		}
+		next(ctx, b, update)
+	}
+}
+
+type senderKey struct{}
+
+// senderID пользователь, от которого пришло обрабатываемое обновление (см. withUpdateContext)
+func senderID(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(senderKey{}).(int64)
+	return userID, ok
+}
+
+// Run запускает бота, фоновую проверку и обработчики очереди и работает до отмены ctx
+func (a *App) Run(ctx context.Context) error {
+	b, err := a.NewBot()
+	if err != nil {
+		return err
+	}
+	a.logger.InfoContext(ctx, "BOT STARTED")
+	poller := a.startPoller(ctx, b)
+	workers := a.startWorkers(ctx, b)
+	b.Start(ctx)
+	poller.Wait()
+	workers.Wait()
+	a.logger.InfoContext(ctx, "BOT STOPPED")
+	return nil
+}
+
+// connectDB создаёт единственный пул соединений с Redis по настройкам cfg.Redis и проверяет связь
+func connectDB(ctx context.Context, cfg *config.Config) (*database.Db, *redis.Client, error) {
+	db := database.NewDB(cfg.Redis.Host, cfg.Redis.Password, cfg.Redis.DB).ForSheet(cfg.Sheet.SpreadsheetId, api.SheetBot)
+	db.Conn = database.ConnOptions{
+		PoolSize:       cfg.Redis.PoolSize,
+		MinIdleConns:   cfg.Redis.MinIdleConns,
+		DialTimeout:    cfg.Redis.DialTimeout,
+		ReadTimeout:    cfg.Redis.ReadTimeout,
+		WriteTimeout:   cfg.Redis.WriteTimeout,
+		TLS:            cfg.Redis.TLS,
+		SentinelMaster: cfg.Redis.SentinelMaster,
+		SentinelAddrs:  cfg.Redis.SentinelAddrs,
+	}
+	rdb, err := db.Open(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, rdb, nil
+}
+
+// connectStore открывает хранилище строк cfg.Store: redis, sqlite или memory.
+// Подключение к Redis нужно при любом выборе, см. config.Store
+// migrateBareRowKeys переносит строки старых версий в новую схему ключей Redis
+func migrateBareRowKeys(ctx context.Context, db *database.Db, rdb *redis.Client) {
+	migrated, err := db.MigrateBareRowKeys(ctx, rdb)
+	if err != nil {
+		slog.Error("Не удалось перенести строки из старых ключей", "ERROR", err)
+	} else if migrated > 0 {
+		slog.Info("Строки перенесены в новую схему ключей", "COUNT", migrated)
+	}
+}
+
+func connectStore(cfg *config.Config, db *database.Db, rdb *redis.Client) (database.Store, error) {
+	backend := cfg.Store.Backend
+	store, err := database.NewStore(backend, db, rdb, cfg.Store.SQLitePath)
+	if err != nil {
+		slog.Error("Не удалось открыть хранилище строк", "BACKEND", backend, "ERROR", err)
+		return nil, err
+	}
+	if backend == database.BackendMemory {
+		slog.Warn("Строки хранятся в памяти и пропадут после перезапуска бота")
+	}
+	return store, nil
+}
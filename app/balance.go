@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-telegram/bot"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+const (
+	NOTIFY_LOW_BALANCE = "balance"
+
+	// Как часто напоминать о низком балансе, пока его не пополнят
+	lowBalanceRepeat = 6 * time.Hour
+)
+
+// forecast оценка расходов на строки, которые ждут обработки
+type forecast struct {
+	pendingRows int
+	cost        float64
+}
+
+// spendForecast считает, сколько будут стоить задачи по всем необработанным строкам в базе
+// и по строкам из очереди заданий, включая отложенные повторы. Строка в обоих списках считается один раз
+func (a *App) spendForecast(ctx context.Context) (*forecast, error) {
+	pending, err := a.store.ListPending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queued, err := a.db.QueuedRows(ctx, a.rdb)
+	if err != nil {
+		return nil, err
+	}
+	rows := make(map[string]bool, len(pending)+len(queued))
+	for _, row := range pending {
+		rows[row] = true
+	}
+	for _, row := range queued {
+		rows[strconv.Itoa(row)] = true
+	}
+	return &forecast{
+		pendingRows: len(rows),
+		cost:        float64(len(rows)) * a.settings.RowCost(),
+	}, nil
+}
+
+// checkBalanceAlerts предупреждает администраторов, если доступный баланс ниже BALANCE_ALERT_THRESHOLD
+// или его не хватит на задачи по строкам из очереди
+func (a *App) checkBalanceAlerts(ctx context.Context, b *bot.Bot) {
+	clienObj := a.client
+	balance, err := clienObj.Get_balance(ctx)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Фоновая проверка: не удалось получить баланс", "ERROR", err)
+		return
+	}
+
+	threshold := a.cfg.UNU.BalanceAlertThreshold
+
+	reasons := ""
+	if balance.Available < threshold {
+		reasons += fmt.Sprintf("\nДоступно меньше порога в %s ₽", formatPrice(threshold))
+	}
+	if forecast, err := a.spendForecast(ctx); err == nil && forecast.cost > balance.Available {
+		reasons += fmt.Sprintf("\nНе хватит на %d строк(и) в очереди: нужно ~%s ₽", forecast.pendingRows, formatPrice(forecast.cost))
+	}
+	if reasons == "" {
+		a.db.ClearNotified(ctx, a.rdb, NOTIFY_LOW_BALANCE, "low")
+		return
+	}
+	isNew, err := a.db.MarkNotified(ctx, a.rdb, NOTIFY_LOW_BALANCE, "low", lowBalanceRepeat)
+	if err != nil || !isNew {
+		return
+	}
+	a.notifyRole(ctx, b, dbmodels.RoleAdmin, fmt.Sprintf("💸 Заканчиваются деньги на балансе UNU!\nДоступно: %s ₽ (заморожено %s ₽)%s\nПополните баланс, иначе часть задач не создастся.",
+		formatPrice(balance.Available), formatPrice(balance.Freeze), reasons))
+}
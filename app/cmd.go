@@ -1,3 +1,440 @@
 package app
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/config"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/logger"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/utils"
+)
+
+const cliUsage = `Использование: unu [--config файл.yaml] [--dry-run] <команда>
+
+Команды:
+  bot                                   запустить Telegram бота (по умолчанию)
+  balance                               баланс кошелька и стоимость очереди
+  folders list                          список папок
+  folders create <название>             создать папку
+  folders delete <ID>                   удалить папку
+  tasks create --rows 2-15 --folder ID  создать задачи по строкам таблицы
+  pending list                          необработанные строки из базы
+  pending resume --folder ID            создать задачи по необработанным строкам
+  pending drop <строки>                 убрать строки из необработанных
+  config check                          проверить настройки
+
+--dry-run показывает, что будет сделано, ничего не меняя в UNU и базе.
+Для tasks create и pending resume печатает в JSON задачи, которые ушли бы в add_task, и их стоимость.
+--force у tasks create и pending resume создаёт задачи и по строкам, где задача с тем же содержимым уже есть
+`
+
+// errUsage команда вызвана с неверными аргументами
+var errUsage = errors.New("неверные аргументы")
+
+// cli одна команда командной строки. Команды используют те же сервисы App, что и бот
+type cli struct {
+	cfg    *config.Config
+	dryRun bool
+	stdout io.Writer
+}
+
+type cliCommand func(c *cli, ctx context.Context, args []string) error
+
+var cliCommands = map[string]cliCommand{
+	"bot":     (*cli).bot,
+	"balance": (*cli).balance,
+	"folders": (*cli).folders,
+	"tasks":   (*cli).tasks,
+	"pending": (*cli).pending,
+	"config":  (*cli).config,
+}
+
+// Main выполняет команду из аргументов командной строки и возвращает код завершения:
+// 0 — успех, 1 — ошибка, 2 — неверные аргументы. Без команды запускается бот
+func Main(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout}
+	flags := flag.NewFlagSet("unu", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, cliUsage) }
+	configPath := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML файл с настройками")
+	flags.BoolVar(&c.dryRun, "dry-run", false, "ничего не менять")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	args = flags.Args()
+	name := "bot"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	command, ok := cliCommands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "Неизвестная команда %q\n\n%s", name, cliUsage)
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	c.cfg = cfg
+	log, err := logger.New(stderr, logger.Options{Level: cfg.Log.Level, Format: cfg.Log.Format, Secrets: cfg.Secrets()})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	slog.SetDefault(log)
+	err = command(c, ctx, args)
+	switch {
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "%v\n\n%s", err, cliUsage)
+		return 2
+	case err != nil:
+		fmt.Fprintln(stderr, "Ошибка:", err)
+		return 1
+	}
+	return 0
+}
+
+// connect подключает App к Redis и хранилищу строк так же, как при запуске бота
+func (c *cli) connect(ctx context.Context) (*App, func(), error) {
+	return Connect(ctx, c.cfg)
+}
+
+// parseFlags разбирает флаги подкоманды и возвращает остальные аргументы. Флаги, в том числе --dry-run,
+// можно писать и до, и после аргументов: folders delete 12 --dry-run
+func (c *cli) parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	flags.SetOutput(io.Discard)
+	flags.BoolVar(&c.dryRun, "dry-run", c.dryRun, "ничего не менять")
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional, args = append(positional, args[0]), args[1:]
+	}
+}
+
+func (c *cli) printf(format string, args ...interface{}) {
+	fmt.Fprintf(c.stdout, format, args...)
+}
+
+func (c *cli) bot(ctx context.Context, args []string) error {
+	if c.dryRun {
+		return fmt.Errorf("%w: бот нельзя запустить с --dry-run", errUsage)
+	}
+	if err := c.cfg.CheckTelegram(); err != nil {
+		return err
+	}
+	a, close, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer close()
+	return a.Run(ctx)
+}
+
+func (c *cli) balance(ctx context.Context, args []string) error {
+	a, close, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer close()
+	balance, err := a.client.Get_balance(ctx)
+	if err != nil {
+		return err
+	}
+	c.printf("Баланс: %s ₽\nЗаморожено: %s ₽\nДоступно: %s ₽\n",
+		formatPrice(balance.Balance), formatPrice(balance.Freeze), formatPrice(balance.Available))
+	if forecast, err := a.spendForecast(ctx); err == nil && forecast.pendingRows > 0 {
+		c.printf("В очереди %d необработанных строк на ~%s ₽\n", forecast.pendingRows, formatPrice(forecast.cost))
+	}
+	return nil
+}
+
+func (c *cli) folders(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: укажите list, create или delete", errUsage)
+	}
+	action, args := args[0], args[1:]
+	flags := flag.NewFlagSet("folders "+action, flag.ContinueOnError)
+	args, err := c.parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	a, close, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer close()
+	switch action {
+	case "list":
+		folders, err := a.client.Get_folders(ctx)
+		if err != nil {
+			return err
+		}
+		for _, folder := range folders {
+			c.printf("%s\t%s\n", folder.ID.String(), folder.Name)
+		}
+		return nil
+	case "create":
+		name := strings.TrimSpace(strings.Join(args, " "))
+		if name == "" {
+			return fmt.Errorf("%w: укажите название папки", errUsage)
+		}
+		if c.dryRun {
+			c.printf("--dry-run: будет создана папка '%s'\n", name)
+			return nil
+		}
+		folder_id, err := a.client.Create_folder(ctx, name)
+		if err != nil {
+			return err
+		}
+		c.printf("Папка '%s' создана, ID: %d\n", name, folder_id)
+		return nil
+	case "delete":
+		if len(args) != 1 {
+			return fmt.Errorf("%w: укажите ID папки", errUsage)
+		}
+		folder_id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("%w: ID папки должен быть числом", errUsage)
+		}
+		if c.dryRun {
+			c.printf("--dry-run: будет удалена папка %d\n", folder_id)
+			return nil
+		}
+		ok, err := a.client.Delete_folder(ctx, folder_id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("UNU не удалил папку %d", folder_id)
+		}
+		c.printf("Папка %d удалена\n", folder_id)
+		return nil
+	}
+	return fmt.Errorf("%w: неизвестное действие folders %s", errUsage, action)
+}
+
+// taskFlags общие флаги создания задач: папка, автор и разрешение повторять тексты
+type taskFlags struct {
+	folder int
+	user   int64
+	force  bool
+}
+
+func (c *cli) addTaskFlags(flags *flag.FlagSet) *taskFlags {
+	options := &taskFlags{}
+	flags.IntVar(&options.folder, "folder", 0, "ID папки для задач")
+	if len(c.cfg.Telegram.AdminIDs) > 0 {
+		options.user = c.cfg.Telegram.AdminIDs[0]
+	}
+	flags.Int64Var(&options.user, "user", options.user, "ID пользователя, от имени которого создаются задачи")
+	flags.BoolVar(&options.force, "force", false, "создать задачи, даже если такой текст уже отправлялся или задача по строке уже есть")
+	return options
+}
+
+func (c *cli) tasks(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return fmt.Errorf("%w: укажите tasks create", errUsage)
+	}
+	flags := flag.NewFlagSet("tasks create", flag.ContinueOnError)
+	rowsArg := flags.String("rows", "", "номера строк, например 2-15 или 3, 5, 7-9")
+	options := c.addTaskFlags(flags)
+	if _, err := c.parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+	rows, err := utils.ParseNumberRanges(*rowsArg)
+	if err != nil || rows[0] < 2 {
+		return fmt.Errorf("%w: --rows 2-15 или 3, 5, 7-9 (не больше %d строк, начиная со 2-й)", errUsage, utils.MaxRangeSize)
+	}
+	if options.folder <= 0 || options.user <= 0 {
+		return fmt.Errorf("%w: укажите --folder и --user (или TG_ADMIN_IDS)", errUsage)
+	}
+
+	a, close, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer close()
+	jobs := make([]dbmodels.TaskJob, len(rows))
+	for i, row := range rows {
+		jobs[i] = c.taskJob(row, options.folder, options.user, options.force)
+	}
+	return c.createTasks(ctx, a, jobs)
+}
+
+func (c *cli) taskJob(row, folderId int, userId int64, force bool) dbmodels.TaskJob {
+	hostname, _ := os.Hostname()
+	return dbmodels.TaskJob{
+		Row:            row,
+		FolderId:       folderId,
+		UserId:         userId,
+		Owner:          "cli@" + hostname,
+		AllowDuplicate: force,
+	}
+}
+
+// createTasks создаёт задачи по строкам сразу, без очереди бота, и печатает итог по каждой строке.
+// Возвращает ошибку, если хотя бы одну строку обработать не удалось
+func (c *cli) createTasks(ctx context.Context, a *App, jobs []dbmodels.TaskJob) error {
+	tariffs, err := api.CachedTariffs(ctx, a.db, a.rdb, a.client)
+	if err != nil {
+		a.logger.WarnContext(ctx, "Не удалось проверить тариф перед созданием задач", "ERROR", err)
+	} else if err = a.settings.Validate(tariffs); err != nil {
+		return fmt.Errorf("UNU не примет задачи с текущими настройками: %w", err)
+	}
+	if c.dryRun {
+		// Вместо add_task печатаем в JSON задачи, которые ушли бы в UNU, и их общую стоимость
+		preview := api.PreviewTasks(ctx, a.store, a.settings, tariffs, database.NewTextHistory(a.rdb), jobs)
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(preview)
+	}
+
+	created, failed := 0, 0
+	// Повторы текста ищутся по строкам, загруженным один раз на весь запуск
+	validators := api.NewRowValidators(a.store, rowValidatorMaxAge)
+	for _, job := range jobs {
+		task_id, err := a.createLockedTask(ctx, a.settings, tariffs, validators, job)
+		line, jobFailed := taskResultLine(job.Row, task_id, err)
+		if err == nil {
+			created++
+		} else if jobFailed {
+			failed++
+		}
+		c.printf("%s\n", line)
+	}
+	c.printf("Создано задач: %d из %d\n", created, len(jobs))
+	if failed > 0 {
+		return fmt.Errorf("не удалось создать задачи по %d строкам", failed)
+	}
+	return nil
+}
+
+func (c *cli) pending(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: укажите list, resume или drop", errUsage)
+	}
+	action, args := args[0], args[1:]
+	flags := flag.NewFlagSet("pending "+action, flag.ContinueOnError)
+	var options *taskFlags
+	if action == "resume" {
+		options = c.addTaskFlags(flags)
+	}
+	args, err := c.parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if action != "list" && action != "resume" && action != "drop" {
+		return fmt.Errorf("%w: неизвестное действие pending %s", errUsage, action)
+	}
+	if action == "resume" && options.folder <= 0 {
+		return fmt.Errorf("%w: укажите --folder", errUsage)
+	}
+
+	a, close, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer close()
+	pending, err := a.store.ListPending(ctx)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "list":
+		if len(pending) == 0 {
+			c.printf("Необработанных строк нет\n")
+			return nil
+		}
+		rowObjects, err := a.store.GetRows(ctx, pending)
+		if err != nil {
+			return err
+		}
+		c.printf("Необработанных строк: %d%s\n", len(pending), formatPendingRows(pending, rowObjects))
+		return nil
+	case "resume":
+		if len(pending) == 0 {
+			c.printf("Необработанных строк нет\n")
+			return nil
+		}
+		rowObjects, err := a.store.GetRows(ctx, pending)
+		if err != nil {
+			return err
+		}
+		jobs := make([]dbmodels.TaskJob, 0, len(pending))
+		for _, rowNumber := range pending {
+			row, err := strconv.Atoi(rowNumber)
+			if err != nil {
+				continue
+			}
+			userId := options.user
+			if rowObject, ok := rowObjects[rowNumber]; ok && rowObject.UserId > 0 {
+				// Задача создаётся от имени того, кто начинал обработку строки
+				userId = int64(rowObject.UserId)
+			}
+			jobs = append(jobs, c.taskJob(row, options.folder, userId, options.force))
+		}
+		return c.createTasks(ctx, a, jobs)
+	}
+
+	// Номера можно передать и одним аргументом через запятую, и отдельными аргументами: pending drop 2 3
+	rows, err := utils.ParseNumberRanges(strings.Join(args, ","))
+	if err != nil {
+		return fmt.Errorf("%w: укажите номера строк из pending list", errUsage)
+	}
+	dropped := 0
+	for _, row := range rows {
+		rowNumber := strconv.Itoa(row)
+		if !slices.Contains(pending, rowNumber) {
+			c.printf("⏭ Строки %d нет среди необработанных\n", row)
+			continue
+		}
+		if c.dryRun {
+			c.printf("--dry-run: строка %d будет убрана\n", row)
+			continue
+		}
+		if _, err := a.store.DelRow(ctx, rowNumber); err != nil {
+			return err
+		}
+		a.store.SetRowStatus(ctx, rowNumber, dbmodels.RowEvent{
+			Status: dbmodels.RowSkipped,
+			Reason: "убрана из необработанных через командную строку",
+		})
+		dropped++
+	}
+	c.printf("Убрано строк: %d\n", dropped)
+	return nil
+}
+
+// config проверяет настройки: Main уже загрузил их и завершился бы с ошибкой, если они неверны
+func (c *cli) config(ctx context.Context, args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return fmt.Errorf("%w: укажите config check", errUsage)
+	}
+	c.printf("Настройки в порядке: %s\n", c.cfg)
+	if err := c.cfg.CheckTelegram(); err != nil {
+		c.printf("Команды CLI работают, но бот не запустится: %v\n", err)
+	}
+	return nil
+}
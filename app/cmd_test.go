@@ -0,0 +1,144 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setCLIEnv задаёт настройки для Main: Redis в памяти и UNU, который падает при любом запросе
+func setCLIEnv(t *testing.T) database.Store {
+	t.Helper()
+	server := miniredis.RunT(t)
+	unu := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("неожиданный запрос к UNU: %s", r.FormValue("action"))
+	}))
+	t.Cleanup(unu.Close)
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("TG_TOKEN", "123:test")
+	t.Setenv("TG_ADMIN_IDS", "100")
+	t.Setenv("URL_UNU", unu.URL)
+	t.Setenv("UNU_API_TOKEN", "secret")
+	t.Setenv("UNU_TASK_PRICE", "15")
+	t.Setenv("UNU_TARIF_ID", "4")
+	t.Setenv("SPREADSHEETID", "cli-sheet")
+	t.Setenv("DB_HOST", server.Addr())
+	t.Setenv("STORE_BACKEND", "redis")
+
+	db := database.NewDB(server.Addr(), "", 0).ForSheet("cli-sheet", api.SheetBot)
+	rdb := db.Connect()
+	t.Cleanup(func() { rdb.Close() })
+	store, err := database.NewStore(database.BackendRedis, db, rdb, "")
+	require.NoError(t, err)
+	return store
+}
+
+func runMain(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Main(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestMainConfigCheck(t *testing.T) {
+	setCLIEnv(t)
+
+	code, stdout, _ := runMain("config", "check")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Настройки в порядке")
+	assert.NotContains(t, stdout, "secret")
+
+	// Токен бота нужен только команде bot
+	t.Setenv("TG_TOKEN", "")
+	code, stdout, _ = runMain("config", "check")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "бот не запустится")
+	assert.Contains(t, stdout, "TG_TOKEN")
+
+	code, stdout, _ = runMain("pending", "list")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Необработанных строк")
+
+	code, _, stderr := runMain("bot")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "TG_TOKEN")
+}
+
+func TestMainUsage(t *testing.T) {
+	setCLIEnv(t)
+
+	for _, args := range [][]string{
+		{"unknown"},
+		{"folders"},
+		{"tasks", "create", "--rows", "1-3", "--folder", "5"},
+		{"tasks", "create", "--rows", "2-3"},
+		{"pending", "resume"},
+		{"--dry-run", "bot"},
+	} {
+		code, _, stderr := runMain(args...)
+		assert.Equal(t, 2, code, args)
+		assert.Contains(t, stderr, "Использование", args)
+	}
+}
+
+func TestMainDryRunFolders(t *testing.T) {
+	setCLIEnv(t)
+
+	code, stdout, _ := runMain("--dry-run", "folders", "create", "Новая", "папка")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "будет создана папка 'Новая папка'")
+
+	code, stdout, _ = runMain("folders", "delete", "12", "--dry-run")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "будет удалена папка 12")
+}
+
+func TestMainPending(t *testing.T) {
+	store := setCLIEnv(t)
+	ctx := context.Background()
+	for _, row := range []string{"5", "6"} {
+		require.NoError(t, store.AddRow(ctx, row, dbmodels.NewRowObject(100, "Кофейня", "https://yandex.ru/maps/org/1", 1, "Текст отзыва "+row, "01.09.2025")))
+	}
+
+	code, stdout, _ := runMain("pending", "list")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Необработанных строк: 2")
+	assert.Contains(t, stdout, "строка 5 — Кофейня")
+
+	code, stdout, _ = runMain("--dry-run", "pending", "drop", "5")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "строка 5 будет убрана")
+	pending, err := store.ListPending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	code, stdout, _ = runMain("pending", "drop", "5, 9")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Строки 9 нет среди необработанных")
+	assert.Contains(t, stdout, "Убрано строк: 1")
+	pending, err = store.ListPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"6"}, pending)
+	state, err := store.GetRowStatus(ctx, "5")
+	require.NoError(t, err)
+	assert.Equal(t, dbmodels.RowSkipped, state.Status)
+
+	// Строки отдельными аргументами
+	for _, row := range []string{"7", "8"} {
+		require.NoError(t, store.AddRow(ctx, row, dbmodels.NewRowObject(100, "Кофейня", "https://yandex.ru/maps/org/1", 1, "Текст отзыва "+row, "01.09.2025")))
+	}
+	code, stdout, _ = runMain("pending", "drop", "6", "7")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Убрано строк: 2")
+	pending, err = store.ListPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8"}, pending)
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	// Префикс callback data у кнопок подтверждения: confirm:<token>:yes|no
+	CALLBACK_CONFIRM = "confirm:"
+
+	ACTION_DELETE_FOLDER = "delete_folder"
+	ACTION_CREATE_TASKS  = "create_tasks"
+)
+
+// pendingAction действие, которое ждёт подтверждения пользователя
+type pendingAction func(a *App, ctx context.Context, b *bot.Bot, chatID int64, state *UserState)
+
+var pendingActions = map[string]pendingAction{
+	ACTION_DELETE_FOLDER: (*App).runDeleteFolder,
+	ACTION_CREATE_TASKS:  (*App).runCreateTasks,
+	ACTION_DELETE_TASKS:  (*App).runTaskCommand,
+	ACTION_PAUSE_TASKS:   (*App).runTaskCommand,
+	ACTION_PLAY_TASKS:    (*App).runTaskCommand,
+	ACTION_MOVE_TASKS:    (*App).runTaskCommand,
+	ACTION_EDIT_TASKS:    (*App).runTaskCommand,
+	ACTION_ADD_LIMIT:     (*App).runTaskCommand,
+	ACTION_APPROVE_ALL:   (*App).runApproveAll,
+}
+
+// askConfirmation сохраняет действие в состоянии пользователя и отправляет вопрос с кнопками.
+// Токен в callback data не даёт подтвердить действие устаревшей клавиатурой.
+// Если в data есть "previous" (*UserState), после отмены пользователь вернётся к этому состоянию
+func (a *App) askConfirmation(ctx context.Context, b *bot.Bot, chatID int64, action string, data map[string]interface{}, question, yesText, noText string) {
+	token := strconv.FormatInt(time.Now().UnixNano(), 36)
+	data["action"] = action
+	data["token"] = token
+	a.setState(ctx, chatID, &UserState{
+		State:   STATE_WAIT_CONFIRM,
+		Data:    data,
+		Command: action,
+	})
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   question,
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{
+					{Text: yesText, CallbackData: CALLBACK_CONFIRM + token + ":yes"},
+					{Text: noText, CallbackData: CALLBACK_CONFIRM + token + ":no"},
+				},
+			},
+		},
+	})
+}
+
+// callbackMessage возвращает сообщение, к которому привязана нажатая кнопка
+func callbackMessage(update *models.Update) *models.Message {
+	if update.CallbackQuery == nil {
+		return nil
+	}
+	return update.CallbackQuery.Message.Message
+}
+
+// takeConfirmState забирает состояние подтверждения с токеном token и убирает его под одной блокировкой.
+// Из двух быстрых нажатий на кнопку состояние получит только первое, поэтому действие выполнится один раз
+func (a *App) takeConfirmState(chatID int64, token string) *UserState {
+	a.statesMu.Lock()
+	defer a.statesMu.Unlock()
+	state, exists := a.states[chatID]
+	if !exists || state.State != STATE_WAIT_CONFIRM || state.Data["token"] != token {
+		return nil
+	}
+	delete(a.states, chatID)
+	return state
+}
+
+func (a *App) handleConfirmCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	message := callbackMessage(update)
+	if message == nil {
+		return
+	}
+	chatID := message.Chat.ID
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' pressed '%s'", update.CallbackQuery.From.Username, update.CallbackQuery.Data))
+
+	parts := strings.Split(strings.TrimPrefix(update.CallbackQuery.Data, CALLBACK_CONFIRM), ":")
+	var state *UserState
+	if len(parts) == 2 {
+		state = a.takeConfirmState(chatID, parts[0])
+	}
+	if state == nil {
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      message.Text + "\n\n⚠️ Это подтверждение устарело. Начните заново.",
+		})
+		return
+	}
+	if time.Since(state.CreatedAt) > 5*time.Minute {
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      message.Text + "\n\nВремя сессии истекло. Начните заново.",
+		})
+		return
+	}
+	if parts[1] != "yes" {
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      message.Text + "\n\n❎ Отменено.",
+		})
+		// Отмена возвращает к диалогу, из которого спросили подтверждение, например к проверке отчётов
+		if previous, ok := state.Data["previous"].(*UserState); ok {
+			a.setState(ctx, chatID, previous)
+		}
+		return
+	}
+
+	action, ok := pendingActions[fmt.Sprint(state.Data["action"])]
+	if !ok {
+		a.logger.ErrorContext(ctx, "Неизвестное действие для подтверждения", "ACTION", state.Data["action"])
+		return
+	}
+	b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: message.ID,
+		Text:      message.Text + "\n\n✅ Подтверждено.",
+	})
+	action(a, ctx, b, chatID, state)
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+const (
+	// Лист таблицы, в который выгружается сводка по расходам
+	sheetExpenses = "EXPENSES"
+
+	expensesUsage = `Использование: /expenses [период] [csv|xlsx] [sheet]
+Период: 2025-09 или 09.2025 — месяц, 01.09.2025-15.09.2025 — диапазон дат. Без периода — текущий месяц.
+sheet — дополнительно записать сводку на лист EXPENSES таблицы.
+Пример: /expenses 09.2025 xlsx`
+	expensesTopLines = 5
+)
+
+// parseExpensePeriod разбирает период для /expenses. Конец периода входит в него целиком
+func parseExpensePeriod(value string, now time.Time) (time.Time, time.Time, error) {
+	if value == "" {
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return from, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), nil
+	}
+	for _, layout := range []string{"2006-01", "01.2006"} {
+		month, err := time.ParseInLocation(layout, value, now.Location())
+		if err == nil {
+			return month, month.AddDate(0, 1, -1), nil
+		}
+	}
+	begin, end, found := strings.Cut(value, "-")
+	if !found {
+		return time.Time{}, time.Time{}, dbmodels.ErrorIncorrectData
+	}
+	from, err := time.ParseInLocation("02.01.2006", begin, now.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, dbmodels.ErrorIncorrectData
+	}
+	to, err := time.ParseInLocation("02.01.2006", end, now.Location())
+	if err != nil || to.Before(from) {
+		return time.Time{}, time.Time{}, dbmodels.ErrorIncorrectData
+	}
+	return from, to, nil
+}
+
+func (a *App) expensesReport(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for expenses report", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+
+	period, format, toSheet := "", "csv", false
+	for _, arg := range commandArgs(update.Message.Text) {
+		switch strings.ToLower(arg) {
+		case "csv", "xlsx":
+			format = strings.ToLower(arg)
+		case "sheet":
+			toSheet = true
+		default:
+			period = arg
+		}
+	}
+	from, to, err := parseExpensePeriod(period, time.Now())
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Не удалось разобрать период.\n" + expensesUsage,
+		})
+		return
+	}
+
+	clienObj := a.client
+	expenses, err := clienObj.Get_expenses(ctx, from, to, 0)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось получить расходы", "ERROR", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ Не удалось получить расходы: %v", err),
+		})
+		return
+	}
+	summary := api.SummarizeExpenses(expenses, from, to, a.folderNames(ctx, clienObj), a.expenseProjects(ctx, expenses))
+
+	var file bytes.Buffer
+	if format == "xlsx" {
+		err = summary.WriteXLSX(&file)
+	} else {
+		err = summary.WriteCSV(&file)
+	}
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "❌ Не удалось сформировать файл с отчётом",
+		})
+		return
+	}
+	_, err = b.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID: chatID,
+		Document: &models.InputFileUpload{
+			Filename: fmt.Sprintf("expenses_%s_%s.%s", from.Format("2006-01-02"), to.Format("2006-01-02"), format),
+			Data:     &file,
+		},
+		Caption: expensesCaption(summary),
+	})
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось отправить отчёт о расходах", "ERROR", err)
+	}
+
+	if toSheet {
+		text := "✅ Сводка записана на лист " + sheetExpenses
+		err = a.sheets.Writer(sheetExpenses, summary.Values())
+		if err != nil {
+			text = fmt.Sprintf("❌ Не удалось записать сводку на лист %s: %v", sheetExpenses, err)
+		}
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   text,
+		})
+	}
+}
+
+// folderNames сопоставляет ID папки и её название. Если список папок не получен, в отчёте останутся только ID
+func (a *App) folderNames(ctx context.Context, clienObj api.UNUAPI) map[string]string {
+	names := make(map[string]string)
+	folders, err := clienObj.Get_folders(ctx)
+	if err != nil {
+		a.logger.WarnContext(ctx, "Не удалось получить названия папок для отчёта о расходах", "ERROR", err)
+	}
+	for _, folder := range folders {
+		names[folder.ID.String()] = folder.Name
+	}
+	return names
+}
+
+// expenseProjects находит проект для каждой задачи по строке таблицы, из которой она создавалась.
+// Проекты хранятся без срока, для задач, созданных до этого, проект берётся из строки задачи, пока она не истекла
+func (a *App) expenseProjects(ctx context.Context, expenses []api.Expense) map[string]string {
+	projects := make(map[string]string)
+	var taskIds []int
+	for _, expense := range expenses {
+		id := expense.TaskId.String()
+		if _, ok := projects[id]; ok {
+			continue
+		}
+		projects[id] = ""
+		task_id, err := expense.TaskId.Int64()
+		if err == nil {
+			taskIds = append(taskIds, int(task_id))
+		}
+	}
+	saved, err := a.store.GetTaskProjects(ctx, taskIds)
+	if err != nil {
+		a.logger.WarnContext(ctx, "Не удалось загрузить проекты задач", "ERROR", err)
+	}
+	for _, task_id := range taskIds {
+		id := strconv.Itoa(task_id)
+		if project, ok := saved[task_id]; ok {
+			projects[id] = project
+			continue
+		}
+		row, err := a.store.GetTaskRow(ctx, task_id)
+		if err == nil {
+			projects[id] = row.Object.Project
+		}
+	}
+	return projects
+}
+
+// expensesCaption краткая сводка в подписи к файлу: итог и самые затратные папки и проекты
+func expensesCaption(summary *api.ExpenseSummary) string {
+	text := fmt.Sprintf("Расходы с %s по %s: %s ₽",
+		summary.From.Format("02.01.2006"), summary.To.Format("02.01.2006"), formatPrice(summary.Total))
+	top := func(title string, lines []api.ExpenseLine) {
+		if len(lines) == 0 {
+			return
+		}
+		text += "\n\n" + title
+		for _, line := range lines[:min(len(lines), expensesTopLines)] {
+			text += fmt.Sprintf("\n• %s — %s ₽", line.Key, formatPrice(line.Amount))
+		}
+	}
+	top("Папки:", summary.ByFolder)
+	top("Проекты:", summary.ByProject)
+	return text
+}
@@ -0,0 +1,225 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/utils"
+)
+
+const failedUsage = `/retry 7, 9-12 — поставить строки в очередь заново
+/drop 7 — убрать строки из списка, задачи по ним создаваться не будут
+/fix 7 — перечитать строку из таблицы после исправления и, если ошибок нет, создать задачу
+force после номеров строк в /retry и /fix — создать задачу, даже если такой текст уже отправлялся`
+
+// forceArg слово, которым оператор разрешает создать задачу с повторяющимся текстом
+const forceArg = "force"
+
+// splitForce убирает force из аргументов команды и сообщает, был ли он
+func splitForce(args []string) ([]string, bool) {
+	if len(args) > 0 && strings.EqualFold(args[len(args)-1], forceArg) {
+		return args[:len(args)-1], true
+	}
+	return args, false
+}
+
+// failedJobs отвечает на /failed списком строк, задачи по которым не удалось создать
+func (a *App) failedJobs(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for failed rows", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+	jobs, err := a.db.ListDeadJobs(ctx, a.rdb)
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   a.storageErrorText(ctx, err),
+		})
+		return
+	}
+	if len(jobs) == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "✅ Строк с ошибками нет",
+		})
+		return
+	}
+	text := fmt.Sprintf("Строк с ошибками: %d", len(jobs))
+	for _, dead := range jobs {
+		text += fmt.Sprintf("\n\n❌ Строка %d — попыток: %d, %s\n%s",
+			dead.Job.Row, dead.Job.Attempts, dead.Time.Format("02.01.2006 15:04"), dead.Reason)
+	}
+	sendLongMessage(ctx, b, chatID, text+"\n\n"+failedUsage)
+}
+
+// failedRowsArg разбирает номера строк после команды и force. Если строк нет или они некорректны, отправляет подсказку
+func failedRowsArg(ctx context.Context, b *bot.Bot, update *models.Update) ([]int, bool, bool) {
+	args, force := splitForce(commandArgs(update.Message.Text))
+	rows, err := utils.ParseNumberRanges(strings.Join(args, " "))
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "Укажите номера строк из /failed.\n" + failedUsage,
+		})
+		return nil, false, false
+	}
+	return rows, force, true
+}
+
+// retryJobs отвечает на /retry: ставит строки из списка ошибок в очередь заново с обнулёнными попытками
+func (a *App) retryJobs(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for retry failed rows", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+	rows, force, ok := failedRowsArg(ctx, b, update)
+	if !ok {
+		return
+	}
+	jobs, skipped := []dbmodels.TaskJob{}, ""
+	for _, row := range rows {
+		dead, err := a.db.GetDeadJob(ctx, a.rdb, row)
+		if err != nil {
+			skipped += fmt.Sprintf("\n⏭ Строки %d нет в списке ошибок", row)
+			continue
+		}
+		jobs = append(jobs, dbmodels.TaskJob{
+			Row:            row,
+			FolderId:       dead.Job.FolderId,
+			UserId:         update.Message.From.ID,
+			Owner:          lockOwnerName(update.Message.From),
+			AllowDuplicate: force,
+		})
+	}
+	a.enqueueFailed(ctx, b, chatID, jobs, skipped)
+}
+
+// enqueueFailed ставит строки в очередь и убирает их из списка ошибок
+func (a *App) enqueueFailed(ctx context.Context, b *bot.Bot, chatID int64, jobs []dbmodels.TaskJob, text string) {
+	if len(jobs) == 0 {
+		sendLongMessage(ctx, b, chatID, "Нечего ставить в очередь:"+text)
+		return
+	}
+	_, err := a.db.EnqueueJobs(ctx, a.rdb, chatID, jobs)
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   a.storageErrorText(ctx, err),
+		})
+		return
+	}
+	for _, job := range jobs {
+		a.db.RemoveDeadJob(ctx, a.rdb, job.Row)
+		a.store.SetRowStatus(ctx, strconv.Itoa(job.Row), dbmodels.RowEvent{Status: dbmodels.RowQueued})
+	}
+	sendLongMessage(ctx, b, chatID, fmt.Sprintf("Поставил в очередь строк: %d. Пришлю отчёт, когда все задачи будут созданы.%s", len(jobs), text))
+}
+
+// dropJobs отвечает на /drop: строки убираются из списка ошибок и из необработанных строк
+func (a *App) dropJobs(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for drop failed rows", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+	rows, _, ok := failedRowsArg(ctx, b, update)
+	if !ok {
+		return
+	}
+	dropped, text := 0, ""
+	for _, row := range rows {
+		removed, err := a.db.RemoveDeadJob(ctx, a.rdb, row)
+		if err != nil {
+			text += fmt.Sprintf("\n❌ Строка %d: %s", row, a.storageErrorText(ctx, err))
+			continue
+		}
+		if !removed {
+			text += fmt.Sprintf("\n⏭ Строки %d нет в списке ошибок", row)
+			continue
+		}
+		rowNumber := strconv.Itoa(row)
+		a.store.DelRow(ctx, rowNumber)
+		a.store.SetRowStatus(ctx, rowNumber, dbmodels.RowEvent{
+			Status: dbmodels.RowSkipped,
+			Reason: "убрана из списка ошибок пользователем " + lockOwnerName(update.Message.From),
+		})
+		dropped++
+	}
+	sendLongMessage(ctx, b, chatID, fmt.Sprintf("Убрано строк: %d%s", dropped, text))
+}
+
+// fixJob отвечает на /fix: перечитывает исправленную строку из таблицы и проверяет её.
+// Если строка по-прежнему с ошибкой, сообщает об этом сразу, не тратя попытки очереди
+func (a *App) fixJob(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for fix failed row", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+	args, force := splitForce(commandArgs(update.Message.Text))
+	row := 0
+	if len(args) == 1 {
+		row, _ = strconv.Atoi(args[0])
+	}
+	if row < 2 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Укажите одну строку из /failed. Пример: /fix 7",
+		})
+		return
+	}
+	dead, err := a.db.GetDeadJob(ctx, a.rdb, row)
+	if errors.Is(err, dbmodels.ErrorNotFound) {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("Строки %d нет в списке ошибок. Список: /failed", row),
+		})
+		return
+	}
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   a.storageErrorText(ctx, err),
+		})
+		return
+	}
+
+	resp, err := a.sheets.Reader(api.SheetBot, strconv.Itoa(row))
+	if err == nil {
+		var rowObject *dbmodels.RowObject
+		rowObject, err = api.CheckRow(api.RowValidator(ctx, a.store, force), int(update.Message.From.ID), strconv.Itoa(row), resp)
+		if err == nil && !force {
+			err = api.CheckDuplicateText(ctx, database.NewTextHistory(a.rdb), rowObject.Object.TextDescription)
+		}
+	}
+	if err == nil {
+		var params *api.TaskParams
+		params, err = api.BuildTask(resp, a.settings, dead.Job.FolderId)
+		if err == nil {
+			tariffs, tariffsErr := api.CachedTariffs(ctx, a.db, a.rdb, a.client)
+			if tariffsErr == nil {
+				err = api.ValidateTariff(tariffs, params)
+			}
+		}
+	}
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ Строка %d всё ещё с ошибкой: %v\nИсправьте её в таблице и повторите /fix %d", row, err, row) + forceHint(err, row),
+		})
+		return
+	}
+	a.enqueueFailed(ctx, b, chatID, []dbmodels.TaskJob{{
+		Row:            row,
+		FolderId:       dead.Job.FolderId,
+		UserId:         update.Message.From.ID,
+		Owner:          lockOwnerName(update.Message.From),
+		AllowDuplicate: force,
+	}}, fmt.Sprintf("\n✅ Строка %d исправлена", row))
+}
+
+// forceHint подсказка, как создать задачу, если единственная проблема строки — повтор текста
+func forceHint(err error, row int) string {
+	if !errors.Is(err, dbmodels.ErrorDuplicateText) {
+		return ""
+	}
+	return fmt.Sprintf("\nЕсли повтор допустим: /fix %d force", row)
+}
@@ -0,0 +1,584 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/utils"
+)
+
+type UserState struct {
+	State     string
+	Data      map[string]interface{}
+	CreatedAt time.Time
+	Command   string
+	// UserId кто начал или продолжил действие. В группе ввод других участников не продолжает его
+	UserId int64
+}
+
+const (
+	STATE_WAIT_FOLDER_NAME = "wait_folder_name"
+	STATE_WAIT_INPUT_ROWS  = "wait_input_rows"
+	STATE_WAIT_FOLDER_PICK = "wait_folder_pick"
+	STATE_WAIT_CONFIRM     = "wait_confirm"
+	STATE_IDLE             = "idle"
+)
+
+// setState запоминает состояние чата. Его владельцем становится отправитель обновления из ctx
+func (a *App) setState(ctx context.Context, chatID int64, state *UserState) {
+	a.statesMu.Lock()
+	defer a.statesMu.Unlock()
+	state.CreatedAt = time.Now()
+	if userID, ok := senderID(ctx); ok {
+		state.UserId = userID
+	}
+	a.states[chatID] = state
+}
+
+func (a *App) getState(chatID int64) (*UserState, bool) {
+	a.statesMu.RLock()
+	defer a.statesMu.RUnlock()
+	state, exists := a.states[chatID]
+	return state, exists
+}
+
+func (a *App) clearState(chatID int64) {
+	a.statesMu.Lock()
+	defer a.statesMu.Unlock()
+	delete(a.states, chatID)
+}
+
+func (a *App) welcomeMessage(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for will start work", update.Message.Chat.Username, update.Message.Text))
+	// В группе ID чата не совпадает с ID пользователя, а доступ выдаётся по ID пользователя
+	userID := update.Message.Chat.ID
+	if update.Message.From != nil {
+		userID = update.Message.From.ID
+	}
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   fmt.Sprintf("Привет!\nЧтобы посмотреть список доступных команд, введи /help\nТвой ID: %d", userID),
+	})
+}
+func (a *App) helpMessage(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for get help information", update.Message.Chat.Username, update.Message.Text))
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text: `Список доступных команд:
+/help - помощь по командам
+/balance - посмотреть баланс
+/tariffs - тарифы UNU, минимальные цены и поддержка таргетинга
+/row 42 - что произошло со строкой таблицы; /row failed - строки на этапе (queued, reading, validated, sent, created, failed, skipped)
+/expenses - отчёт о расходах за месяц в CSV или XLSX: /expenses 09.2025 xlsx
+/get_folders_id - посмотреть существующие папки
+/create_folder - создать папку с названием
+/delete_folder - удалить папку
+/create_folder - Создание новой папки (В разработке)
+/create_task - создать задачу; /create_task --preview - сначала показать, какие задачи уйдут в UNU, и их стоимость; /create_task --force - создать заново по строкам, где задача уже есть
+/delete_task - удалить задачу или задачи
+/pause_task - остановить задачи
+/play_task - запустить задачи
+/move_task - переместить задачи в другую папку
+/edit_task - изменить название, описание, ссылку или цену задач
+/add_limit - увеличить лимит выполнений задач
+ID задач можно передать сразу после команды: /pause_task 1234, 1240-1245
+/failed - строки, задачи по которым не удалось создать; /retry 7, 9-12 - повторить, /drop 7 - убрать, /fix 7 - перечитать исправленную строку; force после номеров - создать задачу, даже если текст уже отправлялся
+/reports - проверить отчёты исполнителей по папке (или по задаче: /reports 1234)
+/users - пользователи с доступом к боту (для администраторов)
+/grant <ID> <admin|operator|viewer> - выдать роль (для администраторов)
+/revoke <ID> - отозвать доступ (для администраторов)
+Остальные команды в разработке 🙂
+Связаться с разработчиком: @tatarkazawarka`,
+	})
+}
+
+func (a *App) checkBalance(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for check balance wallet", update.Message.Chat.Username, update.Message.Text))
+	firstObj := a.client
+	balance, err := firstObj.Get_balance(ctx)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Ошибка получения баланса:", "ERROR:", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   fmt.Sprintf("❌ Не удалось получить баланс: %v", err),
+		})
+		return
+	}
+	result_text := fmt.Sprintf("Баланс вашего кошелька: %s ₽\nЗаморожено: %s ₽\nДоступно: %s ₽",
+		formatPrice(balance.Balance), formatPrice(balance.Freeze), formatPrice(balance.Available))
+	if forecast, err := a.spendForecast(ctx); err == nil && forecast.pendingRows > 0 {
+		result_text += fmt.Sprintf("\n\nВ очереди %d необработанных строк на ~%s ₽", forecast.pendingRows, formatPrice(forecast.cost))
+		if forecast.cost > balance.Available {
+			result_text += "\n⚠️ Доступных средств не хватит на всю очередь"
+		}
+	}
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   result_text,
+	})
+}
+func (a *App) getFoldersId(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%v' for get folder list id", update.Message.Chat.Username, update.Message.Text))
+	firstObj := a.client
+	folder_list, err := firstObj.Get_folders(ctx)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось получить список папок", "ERROR", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   fmt.Sprintf("❌ Не удалось получить список папок: %v", err),
+		})
+		return
+	}
+	result_text := "Список папок:"
+	for _, value := range folder_list {
+		result_text += fmt.Sprintf("\nID: %s. Название: %s", value.ID.String(), value.Name)
+	}
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   fmt.Sprintf("Ваши папки: %s", result_text),
+	})
+
+}
+
+func (a *App) handler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil {
+		return
+	}
+
+	chatID := update.Message.Chat.ID
+	state, exists := a.getState(chatID)
+
+	if !exists {
+		// Обычное сообщение, не связанное с состоянием
+		return
+	}
+	// Ввод ждём только от того, кто начал действие: иначе в группе его мог бы закончить любой участник
+	if state.UserId != 0 && (update.Message.From == nil || update.Message.From.ID != state.UserId) {
+		return
+	}
+
+	// Проверяем время жизни состояния (максимум 5 минут, для проверки отчётов дольше)
+	timeout := 5 * time.Minute
+	if state.Command == ACTION_REVIEW_REPORTS {
+		timeout = reviewSessionTimeout
+	}
+	if time.Since(state.CreatedAt) > timeout {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Время сессии истекло. Начните заново.",
+		})
+		a.clearState(chatID)
+		return
+	}
+
+	switch state.State {
+	case STATE_WAIT_FOLDER_NAME:
+		a.handleFolderNameInput(ctx, b, update, state)
+	case STATE_WAIT_FOLDER_PICK:
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Пожалуйста, выберите папку кнопками выше.",
+		})
+	case STATE_WAIT_INPUT_ROWS:
+		a.handleTaskRowInput(ctx, b, update, state)
+	case STATE_WAIT_TASK_IDS:
+		a.handleTaskIdsInput(ctx, b, chatID, strings.TrimSpace(update.Message.Text), state)
+	case STATE_WAIT_LIMIT:
+		a.handleLimitInput(ctx, b, update, state)
+	case STATE_WAIT_EDIT_VALUE:
+		a.handleEditValueInput(ctx, b, update, state)
+	case STATE_WAIT_EDIT_FIELD:
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Пожалуйста, выберите поле кнопками выше.",
+		})
+	case STATE_WAIT_REJECT_REASON:
+		a.handleRejectReasonInput(ctx, b, update, state)
+	case STATE_REVIEW_REPORTS:
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Пожалуйста, используйте кнопки под отчётом.",
+		})
+	case STATE_WAIT_CONFIRM:
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Пожалуйста, подтвердите или отмените действие кнопками выше.",
+		})
+	default:
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Неизвестное состояние.",
+		})
+		a.clearState(chatID)
+	}
+
+}
+func (a *App) handleFolderNameInput(ctx context.Context, b *bot.Bot, update *models.Update, state *UserState) {
+	chatID := update.Message.Chat.ID
+	folderName := strings.TrimSpace(update.Message.Text)
+
+	if len(folderName) == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Имя папки не может быть пустым. Введите имя еще раз:",
+		})
+		return
+	}
+
+	// Показываем что начали обработку
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   fmt.Sprintf("Создаю папку '%s'...", folderName),
+	})
+
+	// Создаем папку
+	clienObj := a.client
+	folder_id, err := clienObj.Create_folder(ctx, folderName)
+
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Ошибка создания папки:", "ERROR:", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ Ошибка при создании папки: %v", err),
+		})
+	} else {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("✅ Папка '%s' успешно создана!\nID: %d", folderName, folder_id),
+		})
+	}
+
+	a.clearState(chatID)
+}
+func (a *App) createFolder(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for create folder", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+
+	a.setState(ctx, chatID, &UserState{
+		State:   STATE_WAIT_FOLDER_NAME,
+		Data:    make(map[string]interface{}),
+		Command: "create_folder",
+	})
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   "Пожалуйста, введите имя для папки которую хотим создать:",
+	})
+
+}
+func (a *App) deleteFolder(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for delete folder", update.Message.Chat.Username, update.Message.Text))
+	a.askFolder(ctx, b, update.Message.Chat.ID, ACTION_DELETE_FOLDER, make(map[string]interface{}),
+		"Пожалуйста, выберите папку которую хотим удалить:")
+}
+
+func (a *App) confirmDeleteFolder(ctx context.Context, b *bot.Bot, chatID int64, state *UserState, folder api.Folder) {
+	folderIdInt, err := strconv.Atoi(folder.ID.String())
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось преобразовать folderId в тип integer", "ERROR:", err)
+		a.clearState(chatID)
+		return
+	}
+
+	clienObj := a.client
+	tasksCount := "неизвестным количеством"
+	tasks, err := clienObj.Get_tasks(ctx, folderIdInt)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось получить задачи папки", "FOLDER_ID", folderIdInt, "ERROR", err)
+	} else {
+		tasksCount = strconv.Itoa(len(tasks))
+	}
+
+	a.askConfirmation(ctx, b, chatID, ACTION_DELETE_FOLDER,
+		map[string]interface{}{"folder_id": folderIdInt, "folder_name": folder.Name},
+		fmt.Sprintf("Удалить папку '%s' (ID %d) с %s задач(ами)?", folder.Name, folderIdInt, tasksCount),
+		"🗑 Да, удалить", "Нет")
+}
+
+func (a *App) runDeleteFolder(ctx context.Context, b *bot.Bot, chatID int64, state *UserState) {
+	folderIdInt := state.Data["folder_id"].(int)
+	folderName := fmt.Sprint(state.Data["folder_name"])
+
+	// Показываем что начали обработку
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   fmt.Sprintf("Удаляю папку '%s'...", folderName),
+	})
+
+	clienObj := a.client
+	ok, err := clienObj.Delete_folder(ctx, folderIdInt)
+	if err != nil || !ok {
+		a.logger.ErrorContext(ctx, "Ошибка удаления папки:", "ERROR:", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ Ошибка при удалении папки: %v", err),
+		})
+		return
+	}
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   fmt.Sprintf("✅ Папка '%s' успешно удалена!\n", folderName),
+	})
+}
+
+func (a *App) createTask(ctx context.Context, b *bot.Bot, update *models.Update) {
+	ctxWT, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for create folder", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+	// TODO: Сделать здесь логику, чтобы при входе в данную функцию, сначала проверялась очередь.
+	// Есть ли незавершенные задачи? Если есть, нужно ли обработать их в первую очередь или оставить на потом?
+	stringUnfullfilled, err := a.store.ListPending(ctxWT)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось получить необработанные строки", "ERROR", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   a.storageErrorText(ctx, err),
+		})
+		return
+	}
+	if len(stringUnfullfilled) > 0 {
+		rowObjects, err := a.store.GetRows(ctxWT, stringUnfullfilled)
+		if err != nil {
+			a.logger.ErrorContext(ctx, "Не удалось загрузить необработанные строки", "ERROR", err)
+		}
+		sendLongMessage(ctx, b, chatID, "Дело в том, что перед тем как создать новые задачи, давайте разберёмся со старыми. "+
+			"Я сходил в базу данных и нашёл строки, которые по каким-то либо причинам не были обработаны:\n"+
+			formatPendingRows(stringUnfullfilled, rowObjects))
+	}
+	// TODO: Сейчас надо здесь прописать логику, что есть необработанные строки, и сейчас мы запустим их в работу
+
+	// С --preview сначала показываем, какие задачи уйдут в UNU, и только потом спрашиваем подтверждение.
+	// С --force задачи создаются и по строкам, где такая задача уже есть, и с повторами текста
+	args := commandArgs(update.Message.Text)
+	data := map[string]interface{}{"preview": slices.Contains(args, "--preview"), "force": slices.Contains(args, "--force")}
+	// Проверили что задач нет, спрашиваем у клиента папку для задач
+	a.askFolder(ctx, b, chatID, ACTION_CREATE_TASKS, data,
+		"Пожалуйста, выберите папку, в которую сохраним задачи:")
+}
+
+// formatPendingRows список необработанных строк с проектами. Строки, которые не удалось загрузить, выводятся одним номером
+func formatPendingRows(rows []string, rowObjects map[string]*dbmodels.RowObject) string {
+	var text strings.Builder
+	for _, row := range rows {
+		rowObject, ok := rowObjects[row]
+		if !ok {
+			fmt.Fprintf(&text, "\n• строка %s", row)
+			continue
+		}
+		fmt.Fprintf(&text, "\n• строка %s — %s (%s)", row, rowObject.Object.Project, rowObject.Object.Link)
+	}
+	return text.String()
+}
+
+func (a *App) askTaskRows(ctx context.Context, b *bot.Bot, chatID int64, state *UserState, folder api.Folder) {
+	folderIdInt, err := strconv.Atoi(folder.ID.String())
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось преобразовать folderId в тип integer", "ERROR:", err)
+		a.clearState(chatID)
+		return
+	}
+	// Запрашиваем у клиента номера строк для выполнения
+	a.setState(ctx, chatID, &UserState{
+		State:   STATE_WAIT_INPUT_ROWS,
+		Data:    map[string]interface{}{"folder_id": folderIdInt, "folder_name": folder.Name, "preview": state.Data["preview"], "force": state.Data["force"]},
+		Command: "create_task",
+	})
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   "Пожалуйста, введи номера строк для начала работы: Пример: 2-15(Не забывайте, что строка с номером 1, сервисная, на ней находятся названия колонок)",
+	})
+}
+func (a *App) handleTaskRowInput(ctx context.Context, b *bot.Bot, update *models.Update, state *UserState) {
+	chatID := update.Message.Chat.ID
+	input := strings.TrimSpace(update.Message.Text)
+
+	if len(input) == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Строки не могут быть пустым сообщением...",
+		})
+		return
+	}
+	rows, err := utils.ParseNumberRanges(input)
+	if err != nil || rows[0] < 2 {
+		a.logger.WarnContext(ctx, fmt.Sprintf("Пользователь %s ввёл некорректные строки: %s", update.Message.Chat.Username, update.Message.Text))
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("Простите, вы ввели некорректное значение. Пожалуйста, ориентируйтесь на пример: 2-15 или 3, 5, 7-9 (не больше %d строк, начиная со 2-й)", utils.MaxRangeSize),
+		})
+		return
+	}
+	settings := a.settings
+	tariffs, err := api.CachedTariffs(ctx, a.db, a.rdb, a.client)
+	if err != nil {
+		a.logger.WarnContext(ctx, "Не удалось проверить тариф перед созданием задач", "ERROR", err)
+	} else if err = settings.Validate(tariffs); err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ UNU не примет задачи с текущими настройками: %v\nСписок тарифов: /tariffs", err),
+		})
+		a.clearState(chatID)
+		return
+	}
+	if preview, _ := state.Data["preview"].(bool); preview {
+		jobs := make([]dbmodels.TaskJob, len(rows))
+		for i, row := range rows {
+			jobs[i] = dbmodels.TaskJob{Row: row, FolderId: state.Data["folder_id"].(int), UserId: update.Message.From.ID}
+		}
+		result := api.PreviewTasks(ctx, a.store, settings, tariffs, database.NewTextHistory(a.rdb), jobs)
+		sendLongMessage(ctx, b, chatID, formatPreview(result))
+		rows = rows[:0]
+		for _, task := range result.Tasks {
+			if task.Task != nil {
+				rows = append(rows, task.Row)
+			}
+		}
+		if len(rows) == 0 {
+			a.clearState(chatID)
+			return
+		}
+		input = utils.FormatNumberRanges(rows)
+	}
+
+	a.askConfirmation(ctx, b, chatID, ACTION_CREATE_TASKS,
+		map[string]interface{}{"rows": rows, "user_id": update.Message.From.ID, "owner": lockOwnerName(update.Message.From), "folder_id": state.Data["folder_id"], "force": state.Data["force"]},
+		fmt.Sprintf("Создать %d задач(и) по строкам %s в папке '%s' стоимостью ~%s ₽ (%s ₽ за задачу)?",
+			len(rows), input, state.Data["folder_name"], formatPrice(settings.RowCost()*float64(len(rows))), formatPrice(settings.RowCost())),
+		"✅ Подтвердить", "Отмена")
+}
+
+// formatPreview описывает задачи пробного прогона так, как они уйдут в add_task, и их общую стоимость
+func formatPreview(preview *api.Preview) string {
+	var text strings.Builder
+	text.WriteString("🔎 Предпросмотр задач, в UNU ничего не отправлено:")
+	for _, task := range preview.Tasks {
+		if task.Task == nil {
+			fmt.Fprintf(&text, "\n\n❌ Строка %d: %s", task.Row, task.Error)
+			continue
+		}
+		params := task.Task
+		country := "любая"
+		if params.TargetingGeoCountryId > 0 {
+			country = strconv.Itoa(params.TargetingGeoCountryId)
+		}
+		fmt.Fprintf(&text, "\n\n✅ Строка %d\nНазвание: %s\nСсылка: %s\nЦена: %s ₽ × %d = %s ₽, тариф %d, папка %d\nТаргетинг: пол %s, страна %s\nОписание:\n%s",
+			task.Row, params.Name, params.Link, formatPrice(params.Price), task.Limit, formatPrice(task.Cost),
+			params.TarifId, params.FolderId, genderName(params.TargetingGender), country, params.Descr)
+	}
+	fmt.Fprintf(&text, "\n\nГотово к созданию: %d из %d, стоимость ~%s ₽", preview.Ready, len(preview.Tasks), formatPrice(preview.TotalCost))
+	return text.String()
+}
+
+// genderName название пола по коду targeting_gender
+func genderName(gender int) string {
+	switch gender {
+	case 1:
+		return "женский"
+	case 2:
+		return "мужской"
+	}
+	return "любой"
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', 2, 64)
+}
+
+func (a *App) runCreateTasks(ctx context.Context, b *bot.Bot, chatID int64, state *UserState) {
+	rows := state.Data["rows"].([]int)
+	userId := state.Data["user_id"].(int64)
+	folderId := state.Data["folder_id"].(int)
+	owner, _ := state.Data["owner"].(string)
+	force, _ := state.Data["force"].(bool)
+
+	// Строки с уже созданной задачей обработчик пропустит, если их содержимое не менялось
+	jobs := make([]dbmodels.TaskJob, 0, len(rows))
+	for _, row := range rows {
+		jobs = append(jobs, dbmodels.TaskJob{Row: row, FolderId: folderId, UserId: userId, Owner: owner, AllowDuplicate: force})
+	}
+
+	// Задачи создают обработчики очереди, итог придёт в этот чат
+	_, err := a.db.EnqueueJobs(ctx, a.rdb, chatID, jobs)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось поставить строки в очередь", "ERROR", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   a.storageErrorText(ctx, err),
+		})
+		return
+	}
+	for _, job := range jobs {
+		a.store.SetRowStatus(ctx, strconv.Itoa(job.Row), dbmodels.RowEvent{Status: dbmodels.RowQueued})
+	}
+	sendLongMessage(ctx, b, chatID, fmt.Sprintf("Поставил в очередь строк: %d. Пришлю отчёт, когда все задачи будут созданы.", len(jobs)))
+}
+
+// createLockedTask создаёт задачу по строке под блокировкой, чтобы два оператора
+// или два экземпляра бота не создали задачу по одной строке дважды. Если задачу по строке
+// с тем же содержимым уже создали, пока мы ждали блокировку, вернётся models.ErrorTaskCreated
+func (a *App) createLockedTask(ctx context.Context, settings *api.TaskSettings, tariffs []api.Tariff, validators *api.RowValidators, job dbmodels.TaskJob) (int, error) {
+	rowNumber := strconv.Itoa(job.Row)
+	lock, err := a.db.LockRow(ctx, a.rdb, rowNumber, job.Owner, database.RowLockTTL)
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Release(context.WithoutCancel(ctx))
+	// Если блокировку перехватят, lockCtx отменится и запрос к UNU прервётся
+	lockCtx, stop := lock.KeepAlive(ctx)
+	defer stop()
+
+	ctxRow, cancel := context.WithTimeout(lockCtx, time.Second*30)
+	defer cancel()
+	task_id, err := api.CreateTaskFromRow(ctxRow, a.client, a.store, settings, tariffs, database.NewTextHistory(a.rdb),
+		validators.Get(ctxRow, job.AllowDuplicate), int(job.UserId), rowNumber, job.FolderId, job.AllowDuplicate)
+	if err != nil && errors.Is(context.Cause(lockCtx), dbmodels.ErrorLockLost) {
+		return task_id, fmt.Errorf("%w: %v", dbmodels.ErrorLockLost, err)
+	}
+	return task_id, err
+}
+
+// lockOwnerName имя пользователя, которое увидят другие операторы, если строка занята
+func lockOwnerName(user *models.User) string {
+	if user == nil {
+		return ""
+	}
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return fmt.Sprintf("id %d", user.ID)
+}
+
+// sendLongMessage отправляет текст несколькими сообщениями, если он не помещается в лимит Telegram
+func sendLongMessage(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	const limit = 4000
+	chunk := ""
+	for _, line := range strings.Split(text, "\n") {
+		if len(chunk)+len(line)+1 > limit && chunk != "" {
+			b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: chatID,
+				Text:   chunk,
+			})
+			chunk = ""
+		}
+		if chunk != "" {
+			chunk += "\n"
+		}
+		chunk += line
+	}
+	if chunk != "" {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   chunk,
+		})
+	}
+}
@@ -0,0 +1,526 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/config"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/logger"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/sheets/v4"
+)
+
+const (
+	testAdminID    = 100
+	testOperatorID = 200
+	testStrangerID = 300
+)
+
+// fakeUNU отвечает вместо UNU API и запоминает созданные папки
+type fakeUNU struct {
+	balance api.Balance
+	folders []api.Folder
+	created []string
+	// ID принятых отчётов
+	approved []int
+	// Ошибка get_tariffs: без каталога тарифы не проверяются
+	tariffsErr error
+	// Ошибка get_folders
+	foldersErr error
+}
+
+func (f *fakeUNU) Get_balance(ctx context.Context) (*api.Balance, error) { return &f.balance, nil }
+func (f *fakeUNU) Get_folders(ctx context.Context) ([]api.Folder, error) {
+	return f.folders, f.foldersErr
+}
+func (f *fakeUNU) Create_folder(ctx context.Context, folder_name string) (int64, error) {
+	f.created = append(f.created, folder_name)
+	return 77, nil
+}
+func (f *fakeUNU) Delete_folder(ctx context.Context, folder_id int) (bool, error)   { return true, nil }
+func (f *fakeUNU) Move_task(ctx context.Context, task_id, folder_id int) error      { return nil }
+func (f *fakeUNU) Get_tasks(ctx context.Context, folder_id int) ([]api.Task, error) { return nil, nil }
+func (f *fakeUNU) Get_reports(ctx context.Context, task_id, folder_id int) ([]api.Report, error) {
+	return nil, nil
+}
+func (f *fakeUNU) Approve_report(ctx context.Context, report_id int) error {
+	f.approved = append(f.approved, report_id)
+	return nil
+}
+func (f *fakeUNU) Reject_report(ctx context.Context, report_id int, comment string) error { return nil }
+func (f *fakeUNU) Get_expenses(ctx context.Context, date_from, date_to time.Time, folder_id int) ([]api.Expense, error) {
+	return nil, nil
+}
+func (f *fakeUNU) Add_task(ctx context.Context, params *api.TaskParams) (int, error)      { return 1, nil }
+func (f *fakeUNU) Del_task(ctx context.Context, task_id int) error                        { return nil }
+func (f *fakeUNU) Task_limit_add(ctx context.Context, task_id, add_to_limit int) error    { return nil }
+func (f *fakeUNU) Edit_task(ctx context.Context, task_id int, params *api.TaskEdit) error { return nil }
+func (f *fakeUNU) Get_tariffs(ctx context.Context) ([]api.Tariff, error)                  { return nil, f.tariffsErr }
+func (f *fakeUNU) Task_pause(ctx context.Context, task_id int) error                      { return nil }
+func (f *fakeUNU) Task_play(ctx context.Context, task_id int) error                       { return nil }
+
+// fakeSheets таблица в памяти со строками листа BOT
+type fakeSheets struct {
+	rows map[string][]interface{}
+}
+
+func (s *fakeSheets) Reader(spreadsheetName, rowNumber string) (*sheets.ValueRange, error) {
+	row, ok := s.rows[rowNumber]
+	if !ok {
+		return nil, dbmodels.ErrorZeroValue
+	}
+	return &sheets.ValueRange{Values: [][]interface{}{row}}, nil
+}
+
+func (s *fakeSheets) ReaderFromCell(spreadsheetName, cell string) (*sheets.ValueRange, error) {
+	return &sheets.ValueRange{Values: [][]interface{}{{"ОПУБЛИКОВАТЬ ГОТОВЫЙ"}}}, nil
+}
+
+func (s *fakeSheets) Writer(spreadsheetName string, values [][]interface{}) error { return nil }
+
+// fakeTelegram сервер Bot API, который запоминает тексты отправленных сообщений
+type fakeTelegram struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+		io.Copy(io.Discard, r.Body)
+	}
+	if method == "sendMessage" {
+		f.mu.Lock()
+		f.messages = append(f.messages, r.FormValue("text"))
+		f.mu.Unlock()
+	}
+	result := `{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}`
+	if method == "answerCallbackQuery" {
+		result = "true"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"ok":true,"result":`+result+`}`)
+}
+
+// sent возвращает отправленные сообщения и очищает список
+func (f *fakeTelegram) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	messages := f.messages
+	f.messages = nil
+	return messages
+}
+
+type testBot struct {
+	app      *App
+	bot      *bot.Bot
+	unu      *fakeUNU
+	sheets   *fakeSheets
+	telegram *fakeTelegram
+}
+
+// newTestBot собирает App с подделками вместо UNU, Telegram и Redis
+func newTestBot(t *testing.T) *testBot {
+	t.Helper()
+	server := miniredis.RunT(t)
+	db := database.NewDB(server.Addr(), "", 0).ForSheet("test-sheet", api.SheetBot)
+	rdb := db.Connect()
+	t.Cleanup(func() { rdb.Close() })
+	store, err := database.NewStore(database.BackendMemory, db, rdb, "")
+	require.NoError(t, err)
+	require.NoError(t, db.SetRole(context.Background(), rdb, testOperatorID, dbmodels.RoleOperator))
+
+	cfg := config.Default()
+	cfg.Telegram.Token = "123:test"
+	cfg.Telegram.AdminIDs = []int64{testAdminID}
+	cfg.UNU.TaskPrice = 15
+	cfg.UNU.TarifId = 4
+	cfg.Sheet.SpreadsheetId = "test-sheet"
+
+	unu := &fakeUNU{
+		balance: api.Balance{Balance: 1000, Freeze: 200, Available: 800},
+		folders: []api.Folder{{ID: "5", Name: "Отзывы"}},
+	}
+	sheet := &fakeSheets{rows: map[string][]interface{}{
+		"2": {"Кофейня", "https://yandex.ru/maps/org/123", "ж", "Отличный кофе и быстрое обслуживание", "", "12.05.2025"},
+	}}
+	a := New(cfg, Deps{
+		Client: unu,
+		Sheets: sheet,
+		Store:  store,
+		DB:     db,
+		Redis:  rdb,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	telegram := &fakeTelegram{}
+	tgServer := httptest.NewServer(telegram)
+	t.Cleanup(tgServer.Close)
+	b, err := a.NewBot(bot.WithServerURL(tgServer.URL), bot.WithSkipGetMe(), bot.WithNotAsyncHandlers())
+	require.NoError(t, err)
+	return &testBot{app: a, bot: b, unu: unu, sheets: sheet, telegram: telegram}
+}
+
+// send передаёт боту сообщение от пользователя userID
+func (tb *testBot) send(userID int64, text string) []string {
+	tb.bot.ProcessUpdate(context.Background(), &models.Update{
+		Message: &models.Message{
+			ID:   1,
+			From: &models.User{ID: userID, Username: "tester"},
+			Chat: models.Chat{ID: userID, Username: "tester"},
+			Text: text,
+		},
+	})
+	return tb.telegram.sent()
+}
+
+// press передаёт боту нажатие кнопки с callback data
+func (tb *testBot) press(userID int64, data string) []string {
+	tb.bot.ProcessUpdate(context.Background(), &models.Update{
+		CallbackQuery: &models.CallbackQuery{
+			ID:   "1",
+			From: models.User{ID: userID, Username: "tester"},
+			Message: models.MaybeInaccessibleMessage{
+				Type:    models.MaybeInaccessibleMessageTypeMessage,
+				Message: &models.Message{ID: 1, Chat: models.Chat{ID: userID}},
+			},
+			Data: data,
+		},
+	})
+	return tb.telegram.sent()
+}
+
+func TestBalance(t *testing.T) {
+	tb := newTestBot(t)
+
+	messages := tb.send(testAdminID, "/balance")
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "Баланс вашего кошелька: 1000.00 ₽")
+	assert.Contains(t, messages[0], "Доступно: 800.00 ₽")
+	assert.NotContains(t, messages[0], "В очереди")
+
+	// Строки из очереди заданий входят в прогноз вместе с необработанными строками базы
+	ctx := context.Background()
+	require.NoError(t, tb.app.store.AddRow(ctx, "3", dbmodels.NewRowObject(testOperatorID, "Кофейня", "https://yandex.ru/maps/org/1", 1, "Текст", "12.05.2025")))
+	require.NoError(t, tb.app.db.EnsureJobGroup(ctx, tb.app.rdb))
+	_, err := tb.app.db.EnqueueJobs(ctx, tb.app.rdb, testOperatorID, []dbmodels.TaskJob{{Row: 3}, {Row: 4}})
+	require.NoError(t, err)
+	messages = tb.send(testAdminID, "/balance")
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], fmt.Sprintf("В очереди 2 необработанных строк на ~%s ₽", formatPrice(2*tb.app.settings.RowCost())))
+}
+
+// В группе бот называет ID пользователя, а не ID чата
+func TestWelcomeMessageInGroup(t *testing.T) {
+	tb := newTestBot(t)
+	tb.bot.ProcessUpdate(context.Background(), &models.Update{
+		Message: &models.Message{
+			ID:   1,
+			From: &models.User{ID: testAdminID, Username: "tester"},
+			Chat: models.Chat{ID: -5000, Type: models.ChatTypeGroup},
+			Text: "/start",
+		},
+	})
+	messages := tb.telegram.sent()
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], fmt.Sprintf("Твой ID: %d", testAdminID))
+}
+
+// Записи лога по нажатию кнопки помечаются чатом, в котором нажали кнопку
+func TestWithUpdateContext(t *testing.T) {
+	update := &models.Update{
+		ID: 7,
+		CallbackQuery: &models.CallbackQuery{
+			From: models.User{ID: testAdminID},
+			Message: models.MaybeInaccessibleMessage{
+				Type:    models.MaybeInaccessibleMessageTypeMessage,
+				Message: &models.Message{ID: 1, Chat: models.Chat{ID: -5000}},
+			},
+		},
+	}
+	var attrs []slog.Attr
+	handler := withUpdateContext(func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		attrs = logger.Attrs(ctx)
+	})
+	handler(context.Background(), nil, update)
+	require.Len(t, attrs, 2)
+	assert.Equal(t, int64(-5000), attrs[1].Value.Int64())
+
+	// Сообщение с кнопкой недоступно: остаётся только пользователь
+	update.CallbackQuery.Message = models.MaybeInaccessibleMessage{}
+	handler(context.Background(), nil, update)
+	assert.Equal(t, int64(testAdminID), attrs[1].Value.Int64())
+}
+
+// В группе начатое оператором действие не может закончить другой участник
+func TestStateInputFromOtherUser(t *testing.T) {
+	tb := newTestBot(t)
+	require.NoError(t, tb.app.db.SetRole(context.Background(), tb.app.rdb, testStrangerID, dbmodels.RoleViewer))
+	const groupID = -5000
+	sendToGroup := func(userID int64, text string) []string {
+		tb.bot.ProcessUpdate(context.Background(), &models.Update{
+			Message: &models.Message{
+				ID:   1,
+				From: &models.User{ID: userID, Username: "tester"},
+				Chat: models.Chat{ID: groupID, Type: models.ChatTypeGroup},
+				Text: text,
+			},
+		})
+		return tb.telegram.sent()
+	}
+
+	sendToGroup(testOperatorID, "/create_folder")
+	state, ok := tb.app.getState(groupID)
+	require.True(t, ok)
+	assert.Equal(t, int64(testOperatorID), state.UserId)
+
+	assert.Empty(t, sendToGroup(testStrangerID, "Чужая папка"))
+	assert.Empty(t, tb.unu.created)
+
+	messages := sendToGroup(testOperatorID, "Отзывы")
+	assert.Equal(t, []string{"Отзывы"}, tb.unu.created)
+	require.NotEmpty(t, messages)
+	assert.Contains(t, messages[len(messages)-1], "успешно создана")
+}
+
+func TestAccessDenied(t *testing.T) {
+	tb := newTestBot(t)
+
+	messages := tb.send(testStrangerID, "/balance")
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "нет доступа")
+
+	// Роль viewer не позволяет создавать папки
+	require.NoError(t, tb.app.db.SetRole(context.Background(), tb.app.rdb, testStrangerID, dbmodels.RoleViewer))
+	messages = tb.send(testStrangerID, "/create_folder")
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "нужна роль 'operator'")
+	assert.Empty(t, tb.unu.created)
+}
+
+func TestCreateFolder(t *testing.T) {
+	tb := newTestBot(t)
+
+	messages := tb.send(testOperatorID, "/create_folder")
+	require.Len(t, messages, 1)
+	state, ok := tb.app.getState(testOperatorID)
+	require.True(t, ok)
+	assert.Equal(t, STATE_WAIT_FOLDER_NAME, state.State)
+
+	messages = tb.send(testOperatorID, "  Новая папка ")
+	assert.Equal(t, []string{"Новая папка"}, tb.unu.created)
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[1], "ID: 77")
+	_, ok = tb.app.getState(testOperatorID)
+	assert.False(t, ok)
+}
+
+func TestGetFoldersId(t *testing.T) {
+	tb := newTestBot(t)
+
+	messages := tb.send(testOperatorID, "/get_folders_id")
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "ID: 5. Название: Отзывы")
+}
+
+func TestFoldersError(t *testing.T) {
+	tb := newTestBot(t)
+	tb.unu.foldersErr = fmt.Errorf("%w: wrong api key", dbmodels.ErrorUNUAPI)
+
+	messages := tb.send(testOperatorID, "/get_folders_id")
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "Не удалось получить список папок")
+
+	messages = tb.send(testOperatorID, "/delete_folder")
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "wrong api key")
+	assert.NotContains(t, messages[0], "Папок пока нет")
+	_, ok := tb.app.getState(testOperatorID)
+	assert.False(t, ok)
+}
+
+func TestRowStatus(t *testing.T) {
+	tb := newTestBot(t)
+	ctx := context.Background()
+	require.NoError(t, tb.app.store.SetRowStatus(ctx, "7", dbmodels.RowEvent{Status: dbmodels.RowCreated, TaskId: 555}))
+
+	messages := tb.send(testOperatorID, "/row 7")
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "555")
+}
+
+// Бот не должен отвечать на сообщения без команды, если диалог не начат
+func TestPlainMessageIgnored(t *testing.T) {
+	tb := newTestBot(t)
+
+	assert.Empty(t, tb.send(testOperatorID, "привет"))
+}
+
+func TestCreateTaskPreview(t *testing.T) {
+	tb := newTestBot(t)
+	tb.unu.tariffsErr = dbmodels.ErrorUNUAPI
+
+	tb.send(testOperatorID, "/create_task --preview")
+	tb.press(testOperatorID, CALLBACK_FOLDER+"pick:5")
+	messages := tb.send(testOperatorID, "2-3")
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[0], "12.05.2025 ОПУБЛИКОВАТЬ ГОТОВЫЙ женский отзыв")
+	assert.Contains(t, messages[0], "Цена: 15.00 ₽ × 1 = 15.00 ₽, тариф 4, папка 5")
+	assert.Contains(t, messages[0], "❌ Строка 3")
+	assert.Contains(t, messages[0], "Готово к созданию: 1 из 2, стоимость ~15.00 ₽")
+	// Подтверждение спрашивается только по строкам, которые прошли проверку
+	assert.Contains(t, messages[1], "Создать 1 задач(и) по строкам 2 в папке 'Отзывы'")
+
+	state, ok := tb.app.getState(testOperatorID)
+	require.True(t, ok)
+	assert.Equal(t, []int{2}, state.Data["rows"])
+	pending, err := tb.app.store.ListPending(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestApproveAllConfirmedReports(t *testing.T) {
+	tb := newTestBot(t)
+	reports := []api.Report{{ID: "11", TaskId: "1"}, {ID: "12", TaskId: "1"}, {ID: "13", TaskId: "1"}}
+	tb.app.setState(context.Background(), testOperatorID, &UserState{
+		State: STATE_REVIEW_REPORTS,
+		Data: map[string]interface{}{
+			"reports":   reports,
+			"verdicts":  map[string]*api.Verdict{"12": {Checked: true, Reasons: []string{"нет скриншота"}}},
+			"index":     0,
+			"task_id":   0,
+			"folder_id": 5,
+			"scope":     "папка 5",
+		},
+		Command: ACTION_REVIEW_REPORTS,
+	})
+
+	messages := tb.press(testOperatorID, CALLBACK_REPORT+"all")
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "Будут приняты 2: 11, 13")
+	state, ok := tb.app.getState(testOperatorID)
+	require.True(t, ok)
+	token := state.Data["token"].(string)
+
+	messages = tb.press(testOperatorID, CALLBACK_CONFIRM+token+":yes")
+	// Принимаются только показанные отчёты, отчёт с замечаниями остаётся на проверке
+	assert.Equal(t, []int{11, 13}, tb.unu.approved)
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "Принято отчётов: 2 из 2")
+	state, ok = tb.app.getState(testOperatorID)
+	require.True(t, ok)
+	assert.Equal(t, STATE_REVIEW_REPORTS, state.State)
+	assert.Equal(t, []api.Report{reports[1]}, state.Data["reports"])
+
+	// Повторное нажатие устаревшей кнопки ничего не принимает
+	tb.press(testOperatorID, CALLBACK_CONFIRM+token+":yes")
+	assert.Len(t, tb.unu.approved, 2)
+}
+
+// Двойное нажатие "✅ Принять" принимает отчёт один раз и не роняет бота на последнем отчёте
+func TestApproveReportTwice(t *testing.T) {
+	tb := newTestBot(t)
+	review := func(reports ...api.Report) {
+		tb.app.setState(context.Background(), testOperatorID, &UserState{
+			State: STATE_REVIEW_REPORTS,
+			Data: map[string]interface{}{
+				"reports":   reports,
+				"verdicts":  map[string]*api.Verdict{},
+				"index":     len(reports) - 1,
+				"task_id":   1,
+				"folder_id": 0,
+				"scope":     "задача 1",
+			},
+			Command: ACTION_REVIEW_REPORTS,
+		})
+	}
+
+	review(api.Report{ID: "11", TaskId: "1"}, api.Report{ID: "12", TaskId: "1"})
+	tb.press(testOperatorID, CALLBACK_REPORT+"approve:12")
+	tb.press(testOperatorID, CALLBACK_REPORT+"approve:12")
+	assert.Equal(t, []int{12}, tb.unu.approved)
+	state, ok := tb.app.getState(testOperatorID)
+	require.True(t, ok)
+	assert.Equal(t, []api.Report{{ID: "11", TaskId: "1"}}, state.Data["reports"])
+	assert.Equal(t, 0, state.Data["index"])
+
+	// Оба нажатия на последнем отчёте приходят одновременно
+	review(api.Report{ID: "11", TaskId: "1"})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tb.press(testOperatorID, CALLBACK_REPORT+"approve:11")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []int{12, 11}, tb.unu.approved)
+	_, ok = tb.app.getState(testOperatorID)
+	assert.False(t, ok)
+}
+
+// Из нескольких одновременных нажатий на "✅" подтверждение получает только одно
+func TestTakeConfirmStateOnce(t *testing.T) {
+	tb := newTestBot(t)
+	tb.app.setState(context.Background(), testOperatorID, &UserState{
+		State: STATE_WAIT_CONFIRM,
+		Data:  map[string]interface{}{"action": ACTION_DELETE_FOLDER, "token": "abc"},
+	})
+	assert.Nil(t, tb.app.takeConfirmState(testOperatorID, "other"))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	taken := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tb.app.takeConfirmState(testOperatorID, "abc") != nil {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, taken)
+	_, ok := tb.app.getState(testOperatorID)
+	assert.False(t, ok)
+}
+
+// Задание, на котором обработчики падали снова и снова, уходит в поток ошибок, а не выдаётся бесконечно
+func TestProcessJobTooManyDeliveries(t *testing.T) {
+	tb := newTestBot(t)
+	ctx := context.Background()
+	require.NoError(t, tb.app.db.EnsureJobGroup(ctx, tb.app.rdb))
+	_, err := tb.app.db.EnqueueJobs(ctx, tb.app.rdb, testOperatorID, []dbmodels.TaskJob{{Row: 9, FolderId: 5, UserId: testOperatorID}})
+	require.NoError(t, err)
+	jobs, err := tb.app.db.ReadJobs(ctx, tb.app.rdb, "worker-1", 1, 0)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	jobs[0].Deliveries = jobMaxAttempts + 1
+	tb.app.processJob(ctx, tb.bot, api.NewRowValidators(tb.app.store, rowValidatorMaxAge), jobs[0])
+	dead, err := tb.app.db.GetDeadJob(ctx, tb.app.rdb, 9)
+	require.NoError(t, err)
+	assert.Contains(t, dead.Reason, "прерывалась 5 раз")
+	messages := tb.telegram.sent()
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "❌ Строка 9")
+}
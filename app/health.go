@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-telegram/bot"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// checkRedisHealth проверяет связь с Redis и сообщает администраторам, когда она пропала и когда вернулась
+func (a *App) checkRedisHealth(ctx context.Context, b *bot.Bot) bool {
+	err := a.db.Ping(ctx, a.rdb)
+	if err != nil {
+		if !a.redisDown.Swap(true) {
+			a.notifyRole(ctx, b, dbmodels.RoleAdmin, fmt.Sprintf("⚠️ Нет связи с Redis (%s): %v\nБот не может сохранять строки и проверять роли пользователей.", a.db.String(), err))
+		}
+		return false
+	}
+	if a.redisDown.Swap(false) {
+		a.logger.InfoContext(ctx, "Связь с Redis восстановлена")
+		a.notifyRole(ctx, b, dbmodels.RoleAdmin, "✅ Связь с Redis восстановлена")
+	}
+	return true
+}
+
+// storageErrorText объясняет пользователю ошибку хранилища: отдельно сообщает, если Redis недоступен
+func (a *App) storageErrorText(ctx context.Context, err error) string {
+	if errors.Is(err, dbmodels.ErrorRedisUnavailable) || a.db.Ping(ctx, a.rdb) != nil {
+		return fmt.Sprintf("❌ Нет связи с базой данных Redis (%s). Строки сейчас нельзя прочитать или сохранить — сообщите администратору.", a.db.String())
+	}
+	return fmt.Sprintf("❌ Ошибка базы данных: %v", err)
+}
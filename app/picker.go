@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+)
+
+const (
+	// Префикс callback data у кнопок выбора папки: folder:page:<n>, folder:pick:<id>, folder:cancel
+	CALLBACK_FOLDER = "folder:"
+
+	folderPageSize = 8
+)
+
+// folderPicked продолжает команду после того, как пользователь выбрал папку
+type folderPicked func(a *App, ctx context.Context, b *bot.Bot, chatID int64, state *UserState, folder api.Folder)
+
+var folderPickedHandlers = map[string]folderPicked{
+	ACTION_DELETE_FOLDER:  (*App).confirmDeleteFolder,
+	ACTION_CREATE_TASKS:   (*App).askTaskRows,
+	ACTION_MOVE_TASKS:     (*App).confirmMoveTasks,
+	ACTION_REVIEW_REPORTS: (*App).startReview,
+}
+
+// askFolder запрашивает список папок и показывает первую страницу клавиатуры для выбора.
+// Данные из data сохраняются в состоянии и будут доступны после выбора папки
+func (a *App) askFolder(ctx context.Context, b *bot.Bot, chatID int64, purpose string, data map[string]interface{}, question string) {
+	clienObj := a.client
+	folders, err := clienObj.Get_folders(ctx)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось получить список папок", "ERROR", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ Не удалось получить список папок: %v", err),
+		})
+		a.clearState(chatID)
+		return
+	}
+	if len(folders) == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Папок пока нет. Создайте папку командой /create_folder",
+		})
+		a.clearState(chatID)
+		return
+	}
+	data["purpose"] = purpose
+	data["folders"] = folders
+	data["question"] = question
+	a.setState(ctx, chatID, &UserState{
+		State:   STATE_WAIT_FOLDER_PICK,
+		Data:    data,
+		Command: purpose,
+	})
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        folderPageText(question, folders, 0),
+		ReplyMarkup: folderKeyboard(folders, 0),
+	})
+}
+
+func folderPages(folders []api.Folder) int {
+	return (len(folders) + folderPageSize - 1) / folderPageSize
+}
+
+func folderPageText(question string, folders []api.Folder, page int) string {
+	return fmt.Sprintf("%s\nСтраница %d из %d", question, page+1, folderPages(folders))
+}
+
+// folderKeyboard строит клавиатуру для одной страницы списка папок
+func folderKeyboard(folders []api.Folder, page int) *models.InlineKeyboardMarkup {
+	keyboard := [][]models.InlineKeyboardButton{}
+	begin := page * folderPageSize
+	end := min(begin+folderPageSize, len(folders))
+	for _, folder := range folders[begin:end] {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: fmt.Sprintf("📁 %s (ID %s)", folder.Name, folder.ID.String()), CallbackData: CALLBACK_FOLDER + "pick:" + folder.ID.String()},
+		})
+	}
+	navigation := []models.InlineKeyboardButton{}
+	if page > 0 {
+		navigation = append(navigation, models.InlineKeyboardButton{Text: "◀️ Назад", CallbackData: fmt.Sprintf("%spage:%d", CALLBACK_FOLDER, page-1)})
+	}
+	if page < folderPages(folders)-1 {
+		navigation = append(navigation, models.InlineKeyboardButton{Text: "Вперёд ▶️", CallbackData: fmt.Sprintf("%spage:%d", CALLBACK_FOLDER, page+1)})
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: "Отмена", CallbackData: CALLBACK_FOLDER + "cancel"},
+	})
+	return &models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+func (a *App) handleFolderCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	message := callbackMessage(update)
+	if message == nil {
+		return
+	}
+	chatID := message.Chat.ID
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' pressed '%s'", update.CallbackQuery.From.Username, update.CallbackQuery.Data))
+
+	state, exists := a.getState(chatID)
+	if !exists || state.State != STATE_WAIT_FOLDER_PICK || time.Since(state.CreatedAt) > 5*time.Minute {
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      "⚠️ Этот список папок устарел. Начните заново.",
+		})
+		return
+	}
+	folders := state.Data["folders"].([]api.Folder)
+	question := fmt.Sprint(state.Data["question"])
+
+	command, value, _ := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, CALLBACK_FOLDER), ":")
+	switch command {
+	case "page":
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 0 || page >= folderPages(folders) {
+			return
+		}
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:      chatID,
+			MessageID:   message.ID,
+			Text:        folderPageText(question, folders, page),
+			ReplyMarkup: folderKeyboard(folders, page),
+		})
+	case "pick":
+		for _, folder := range folders {
+			if folder.ID.String() != value {
+				continue
+			}
+			b.EditMessageText(ctx, &bot.EditMessageTextParams{
+				ChatID:    chatID,
+				MessageID: message.ID,
+				Text:      fmt.Sprintf("%s\nВыбрана папка: %s (ID %s)", question, folder.Name, folder.ID.String()),
+			})
+			picked, ok := folderPickedHandlers[fmt.Sprint(state.Data["purpose"])]
+			if !ok {
+				a.logger.ErrorContext(ctx, "Неизвестное назначение выбора папки", "PURPOSE", state.Data["purpose"])
+				a.clearState(chatID)
+				return
+			}
+			picked(a, ctx, b, chatID, state, folder)
+			return
+		}
+		a.logger.WarnContext(ctx, "Выбрана папка, которой нет в списке", "FOLDER_ID", value)
+	default:
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      question + "\n\n❎ Отменено.",
+		})
+		a.clearState(chatID)
+	}
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+const (
+	// Сколько хранить отметки об отправленных уведомлениях
+	notifyTTL = 14 * 24 * time.Hour
+
+	NOTIFY_NEW_REPORT = "report"
+	NOTIFY_DEADLINE   = "deadline"
+	NOTIFY_OUT_LIMIT  = "limit"
+)
+
+// startPoller запускает фоновую проверку связи с Redis, новых отчётов, сроков проверки, лимитов задач и баланса.
+// Интервал задаётся в POLL_INTERVAL (например 10m, off — отключить), порог срока проверки в DEADLINE_WARN_HOURS.
+// Опрос останавливается вместе с ctx, дождаться завершения можно через возвращаемый WaitGroup
+func (a *App) startPoller(ctx context.Context, b *bot.Bot) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	if !a.cfg.Poll.Enabled {
+		a.logger.InfoContext(ctx, "Фоновая проверка отключена (POLL_INTERVAL=off)")
+		return &wg
+	}
+	interval, deadlineHours := a.cfg.Poll.Interval, a.cfg.Poll.DeadlineWarnHours
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.logger.InfoContext(ctx, "Фоновая проверка запущена", "INTERVAL", interval.String(), "DEADLINE_HOURS", deadlineHours)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			a.pollOnce(ctx, b, time.Duration(deadlineHours)*time.Hour)
+			select {
+			case <-ctx.Done():
+				a.logger.InfoContext(ctx, "Фоновая проверка остановлена")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return &wg
+}
+
+// pollOnce собирает все новые события за один проход и рассылает их одним сообщением
+func (a *App) pollOnce(ctx context.Context, b *bot.Bot, deadlineWarn time.Duration) {
+	if !a.checkRedisHealth(ctx, b) {
+		// Без Redis не получится отметить отправленные уведомления, пропускаем проход
+		return
+	}
+	clienObj := a.client
+
+	text := ""
+	reports, err := clienObj.Get_reports(ctx, 0, 0)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Фоновая проверка: не удалось получить отчёты", "ERROR", err)
+	} else {
+		text += a.reportNotifications(ctx, reports, deadlineWarn)
+	}
+	tasks, err := clienObj.Get_tasks(ctx, 0)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Фоновая проверка: не удалось получить задачи", "ERROR", err)
+	} else {
+		text += a.limitNotifications(ctx, tasks)
+	}
+	a.checkBalanceAlerts(ctx, b)
+	if text == "" {
+		return
+	}
+	a.notifyRole(ctx, b, dbmodels.RoleOperator, "🔔 Новости по задачам:"+text)
+}
+
+func (a *App) reportNotifications(ctx context.Context, reports []api.Report, deadlineWarn time.Duration) string {
+	newByTask := make(map[string]int)
+	deadlines := []string{}
+	for _, report := range reports {
+		id := report.ID.String()
+		isNew, err := a.db.MarkNotified(ctx, a.rdb, NOTIFY_NEW_REPORT, id, notifyTTL)
+		if err == nil && isNew {
+			newByTask[report.TaskId.String()]++
+		}
+
+		deadline, err := report.Deadline()
+		if err != nil {
+			continue
+		}
+		left := time.Until(deadline)
+		if left > deadlineWarn {
+			continue
+		}
+		isNew, err = a.db.MarkNotified(ctx, a.rdb, NOTIFY_DEADLINE, id, notifyTTL)
+		if err == nil && isNew {
+			deadlines = append(deadlines, fmt.Sprintf("\n⏰ Отчёт %s (задача %s): проверить до %s, осталось %s",
+				id, report.TaskId.String(), report.DateEnd, formatLeft(left)))
+		}
+	}
+
+	text := ""
+	if len(newByTask) > 0 {
+		taskIds := make([]string, 0, len(newByTask))
+		for taskId := range newByTask {
+			taskIds = append(taskIds, taskId)
+		}
+		sort.Strings(taskIds)
+		text += "\n\nНовые отчёты на проверке:"
+		for _, taskId := range taskIds {
+			text += fmt.Sprintf("\n📝 Задача %s: %d шт. — /reports %s", taskId, newByTask[taskId], taskId)
+		}
+	}
+	if len(deadlines) > 0 {
+		text += "\n\nСкоро истекает срок проверки:"
+		for _, line := range deadlines {
+			text += line
+		}
+	}
+	return text
+}
+
+func (a *App) limitNotifications(ctx context.Context, tasks []api.Task) string {
+	text := ""
+	for _, task := range tasks {
+		id := task.ID.String()
+		if !task.OutOfLimit() {
+			// Лимит увеличили — при следующем исчерпании снова предупредим
+			a.db.ClearNotified(ctx, a.rdb, NOTIFY_OUT_LIMIT, id)
+			continue
+		}
+		isNew, err := a.db.MarkNotified(ctx, a.rdb, NOTIFY_OUT_LIMIT, id, notifyTTL)
+		if err == nil && isNew {
+			text += fmt.Sprintf("\n🛑 Задача %s '%s': выполнено %s из %s — /add_limit %s",
+				id, task.Name, task.CountDone.String(), task.LimitTotal.String(), id)
+		}
+	}
+	if text == "" {
+		return ""
+	}
+	return "\n\nЗакончился лимит выполнений:" + text
+}
+
+func formatLeft(left time.Duration) string {
+	if left <= 0 {
+		return "срок истёк"
+	}
+	hours := int(left.Hours())
+	minutes := int(left.Minutes()) % 60
+	return fmt.Sprintf("%d ч %d мин", hours, minutes)
+}
+
+// notifyRole отправляет сообщение всем пользователям с ролью не ниже required
+func (a *App) notifyRole(ctx context.Context, b *bot.Bot, required string, text string) {
+	roles, err := a.db.ListRoles(ctx, a.rdb)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось получить список операторов для уведомления", "ERROR", err)
+		roles = make(map[int64]string)
+	}
+	for userId := range a.admins {
+		roles[userId] = dbmodels.RoleAdmin
+	}
+	for userId, role := range roles {
+		if dbmodels.RoleLevel(role) < dbmodels.RoleLevel(required) {
+			continue
+		}
+		sendLongMessage(ctx, b, userId, text)
+	}
+}
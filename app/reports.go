@@ -0,0 +1,560 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+const (
+	ACTION_REVIEW_REPORTS = "review_reports"
+	ACTION_APPROVE_ALL    = "approve_all"
+
+	STATE_REVIEW_REPORTS      = "review_reports"
+	STATE_WAIT_REJECT_REASON  = "wait_reject_reason"
+	reviewSessionTimeout      = 30 * time.Minute
+	reportMessagePreviewLimit = 3000
+
+	// Префикс callback data у кнопок проверки отчётов:
+	// report:approve:<id>, report:reject:<id>, report:reason:<id>:<n>, report:custom:<id>, report:auto:<id>,
+	// report:page:<n>, report:all, report:close
+	CALLBACK_REPORT = "report:"
+)
+
+// Частые причины отклонения, чтобы не набирать их каждый раз
+var rejectReasons = []string{
+	"Нет скриншота опубликованного отзыва",
+	"Текст отзыва не совпадает с заданием",
+	"Отзыв опубликован не по той ссылке",
+	"Отзыв не найден на площадке",
+}
+
+func (a *App) reviewReports(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for review reports", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+
+	args := commandArgs(update.Message.Text)
+	if len(args) == 0 {
+		a.askFolder(ctx, b, chatID, ACTION_REVIEW_REPORTS, make(map[string]interface{}),
+			"Выберите папку, отчёты по которой хотим проверить (или передайте ID задачи: /reports 1234):")
+		return
+	}
+	task_id, err := strconv.Atoi(args[0])
+	if err != nil || task_id <= 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "ID задачи должен быть положительным числом. Пример: /reports 1234",
+		})
+		return
+	}
+	a.loadReports(ctx, b, chatID, task_id, 0, fmt.Sprintf("задача %d", task_id))
+}
+
+func (a *App) startReview(ctx context.Context, b *bot.Bot, chatID int64, state *UserState, folder api.Folder) {
+	folderIdInt, err := strconv.Atoi(folder.ID.String())
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось преобразовать folderId в тип integer", "ERROR:", err)
+		a.clearState(chatID)
+		return
+	}
+	a.loadReports(ctx, b, chatID, 0, folderIdInt, fmt.Sprintf("папка '%s'", folder.Name))
+}
+
+// loadReports получает отчёты на проверке и начинает сессию проверки
+func (a *App) loadReports(ctx context.Context, b *bot.Bot, chatID int64, task_id, folder_id int, scope string) {
+	clienObj := a.client
+	reports, err := clienObj.Get_reports(ctx, task_id, folder_id)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Ошибка получения отчётов:", "ERROR:", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ Не удалось получить отчёты: %v", err),
+		})
+		a.clearState(chatID)
+		return
+	}
+	if len(reports) == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("Отчётов на проверке нет (%s) 🎉", scope),
+		})
+		a.clearState(chatID)
+		return
+	}
+	state := &UserState{
+		State: STATE_REVIEW_REPORTS,
+		Data: map[string]interface{}{
+			"reports":   reports,
+			"verdicts":  a.verifyReports(ctx, reports),
+			"index":     0,
+			"task_id":   task_id,
+			"folder_id": folder_id,
+			"scope":     scope,
+		},
+		Command: ACTION_REVIEW_REPORTS,
+	}
+	a.setState(ctx, chatID, state)
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        reportCardText(state, ""),
+		ReplyMarkup: reportKeyboard(state),
+	})
+}
+
+// verifyReports проверяет отчёты по строкам таблицы, из которых были созданы задачи
+func (a *App) verifyReports(ctx context.Context, reports []api.Report) map[string]*api.Verdict {
+	rows := make(map[string]*dbmodels.RowObject)
+	verdicts := make(map[string]*api.Verdict, len(reports))
+	for _, report := range reports {
+		taskId := report.TaskId.String()
+		row, ok := rows[taskId]
+		if !ok {
+			task_id, _ := strconv.Atoi(taskId)
+			var err error
+			row, err = a.store.GetTaskRow(ctx, task_id)
+			if err != nil && !errors.Is(err, dbmodels.ErrorNotFound) {
+				a.logger.ErrorContext(ctx, "Не удалось получить строку задачи для автопроверки", "TASK_ID", taskId, "ERROR", err)
+			}
+			rows[taskId] = row
+		}
+		verdicts[report.ID.String()] = api.VerifyReport(report, row)
+	}
+	return verdicts
+}
+
+func verdictText(verdict *api.Verdict) string {
+	if verdict == nil {
+		return ""
+	}
+	text := "\n\n🤖 Автопроверка: "
+	switch {
+	case !verdict.Checked:
+		text += "нет рекомендации"
+	case verdict.Approve:
+		text += fmt.Sprintf("рекомендую принять (совпадение текста %.0f%%)", verdict.Score*100)
+	default:
+		text += "рекомендую отклонить"
+	}
+	for _, reason := range verdict.Reasons {
+		text += "\n❌ " + reason
+	}
+	for _, warning := range verdict.Warnings {
+		text += "\n⚠️ " + warning
+	}
+	return text
+}
+
+// currentReport отчёт, который сейчас показан. ok == false, если отчётов в сессии не осталось
+func currentReport(state *UserState) (report api.Report, index int, ok bool) {
+	reports := state.Data["reports"].([]api.Report)
+	index = state.Data["index"].(int)
+	if index < 0 || index >= len(reports) {
+		return api.Report{}, index, false
+	}
+	return reports[index], index, true
+}
+
+// hasReport отчёт id ещё ждёт проверки в этой сессии
+func hasReport(state *UserState, id string) bool {
+	for _, report := range state.Data["reports"].([]api.Report) {
+		if report.ID.String() == id {
+			return true
+		}
+	}
+	return false
+}
+
+func currentVerdict(state *UserState) *api.Verdict {
+	report, _, ok := currentReport(state)
+	if !ok {
+		return nil
+	}
+	verdicts, _ := state.Data["verdicts"].(map[string]*api.Verdict)
+	return verdicts[report.ID.String()]
+}
+
+func reportCardText(state *UserState, header string) string {
+	reports := state.Data["reports"].([]api.Report)
+	report, index, ok := currentReport(state)
+	if !ok {
+		return strings.TrimSpace(header + "\n\nВсе отчёты проверены 🎉")
+	}
+
+	text := ""
+	if header != "" {
+		text = header + "\n\n"
+	}
+	text += fmt.Sprintf("Отчёт %d из %d (%s)\nID отчёта: %s\nЗадача: %s\nИсполнитель: %s",
+		index+1, len(reports), state.Data["scope"], report.ID.String(), report.TaskId.String(), report.WorkerId.String())
+	if report.DateAdd != "" {
+		text += fmt.Sprintf("\nОтправлен: %s", report.DateAdd)
+	}
+	if report.DateEnd != "" {
+		text += fmt.Sprintf("\n⏰ Проверить до: %s", report.DateEnd)
+	}
+	message := strings.TrimSpace(report.Message)
+	if len([]rune(message)) > reportMessagePreviewLimit {
+		message = string([]rune(message)[:reportMessagePreviewLimit]) + "…"
+	}
+	if message == "" {
+		message = "(исполнитель не оставил текст)"
+	}
+	text += fmt.Sprintf("\n\nТекст отчёта:\n%s", message)
+	if len(report.Files) == 0 {
+		text += "\n\n⚠️ Скриншоты не приложены"
+	} else {
+		text += "\n\nСкриншоты:"
+		for _, file := range report.Files {
+			text += "\n" + file
+		}
+	}
+	return text + verdictText(currentVerdict(state))
+}
+
+func reportKeyboard(state *UserState) models.ReplyMarkup {
+	reports := state.Data["reports"].([]api.Report)
+	report, index, ok := currentReport(state)
+	if !ok {
+		return nil
+	}
+	id := report.ID.String()
+
+	approveText := "✅ Принять"
+	keyboard := [][]models.InlineKeyboardButton{}
+	if verdict := currentVerdict(state); verdict != nil && verdict.Checked {
+		if verdict.Approve {
+			approveText = "✅ Принять 🤖"
+		} else {
+			keyboard = append(keyboard, []models.InlineKeyboardButton{
+				{Text: "🤖 Отклонить по рекомендации", CallbackData: CALLBACK_REPORT + "auto:" + id},
+			})
+		}
+	}
+	keyboard = append([][]models.InlineKeyboardButton{
+		{
+			{Text: approveText, CallbackData: CALLBACK_REPORT + "approve:" + id},
+			{Text: "❌ Отклонить", CallbackData: CALLBACK_REPORT + "reject:" + id},
+		},
+	}, keyboard...)
+	navigation := []models.InlineKeyboardButton{}
+	if index > 0 {
+		navigation = append(navigation, models.InlineKeyboardButton{Text: "◀️", CallbackData: fmt.Sprintf("%spage:%d", CALLBACK_REPORT, index-1)})
+	}
+	if index < len(reports)-1 {
+		navigation = append(navigation, models.InlineKeyboardButton{Text: "▶️", CallbackData: fmt.Sprintf("%spage:%d", CALLBACK_REPORT, index+1)})
+	}
+	if len(navigation) > 0 {
+		keyboard = append(keyboard, navigation)
+	}
+	if state.Data["folder_id"].(int) != 0 {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: fmt.Sprintf("✅ Принять все в папке (%d)", len(reports)), CallbackData: CALLBACK_REPORT + "all"},
+		})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: "Закончить проверку", CallbackData: CALLBACK_REPORT + "close"},
+	})
+	return &models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+func rejectKeyboard(id string) *models.InlineKeyboardMarkup {
+	keyboard := [][]models.InlineKeyboardButton{}
+	for idx, reason := range rejectReasons {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: reason, CallbackData: fmt.Sprintf("%sreason:%s:%d", CALLBACK_REPORT, id, idx)},
+		})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: "✍️ Другая причина", CallbackData: CALLBACK_REPORT + "custom:" + id},
+	})
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: "◀️ Назад к отчёту", CallbackData: CALLBACK_REPORT + "page:current"},
+	})
+	return &models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+// approvableReports отчёты сессии, которые можно принять пачкой: все, кроме тех, что автопроверка рекомендует отклонить
+func approvableReports(state *UserState) (ids []string, flagged int) {
+	verdicts, _ := state.Data["verdicts"].(map[string]*api.Verdict)
+	for _, report := range state.Data["reports"].([]api.Report) {
+		if verdict := verdicts[report.ID.String()]; verdict != nil && len(verdict.Reasons) > 0 {
+			flagged++
+			continue
+		}
+		ids = append(ids, report.ID.String())
+	}
+	return ids, flagged
+}
+
+// reviewState копия сессии проверки на этапе name с изменёнными значениями values.
+// Data сессии не меняется на месте: её может читать обработчик другого нажатия или подтверждения
+func reviewState(state *UserState, name string, values map[string]interface{}) *UserState {
+	data := make(map[string]interface{}, len(state.Data)+len(values))
+	maps.Copy(data, state.Data)
+	maps.Copy(data, values)
+	return &UserState{State: name, Data: data, Command: state.Command}
+}
+
+// removeReport возвращает сессию без проверенного отчёта и false, если отчётов больше не осталось
+func removeReport(state *UserState, id string) (*UserState, bool) {
+	reports := state.Data["reports"].([]api.Report)
+	left := make([]api.Report, 0, len(reports))
+	for _, report := range reports {
+		if report.ID.String() != id {
+			left = append(left, report)
+		}
+	}
+	next := reviewState(state, STATE_REVIEW_REPORTS, map[string]interface{}{
+		"reports": left,
+		"index":   max(0, min(state.Data["index"].(int), len(left)-1)),
+	})
+	return next, len(left) > 0
+}
+
+// lockChat не даёт обрабатывать нажатия кнопок проверки одного чата одновременно:
+// go-telegram/bot запускает каждое обновление в своей горутине, а двойное нажатие приходит двумя обновлениями
+func (a *App) lockChat(chatID int64) func() {
+	a.statesMu.Lock()
+	mu, ok := a.chatLocks[chatID]
+	if !ok {
+		mu = &sync.Mutex{}
+		a.chatLocks[chatID] = mu
+	}
+	a.statesMu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (a *App) handleReportCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	message := callbackMessage(update)
+	if message == nil {
+		return
+	}
+	chatID := message.Chat.ID
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' pressed '%s'", update.CallbackQuery.From.Username, update.CallbackQuery.Data))
+	unlock := a.lockChat(chatID)
+	defer unlock()
+
+	state, exists := a.getState(chatID)
+	if !exists || state.State != STATE_REVIEW_REPORTS || time.Since(state.CreatedAt) > reviewSessionTimeout {
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      "⚠️ Сессия проверки устарела. Начните заново командой /reports",
+		})
+		return
+	}
+	// Продлеваем сессию при каждом действии
+	state = reviewState(state, STATE_REVIEW_REPORTS, nil)
+	a.setState(ctx, chatID, state)
+
+	parts := strings.Split(strings.TrimPrefix(update.CallbackQuery.Data, CALLBACK_REPORT), ":")
+	// Кнопка отчёта, который уже проверен, например при двойном нажатии, ничего не делает
+	switch parts[0] {
+	case "approve", "reject", "reason", "auto", "custom":
+		if len(parts) < 2 || !hasReport(state, parts[1]) {
+			return
+		}
+	}
+	switch parts[0] {
+	case "page":
+		if len(parts) == 2 && parts[1] != "current" {
+			index, err := strconv.Atoi(parts[1])
+			if err != nil || index < 0 || index >= len(state.Data["reports"].([]api.Report)) {
+				return
+			}
+			state = reviewState(state, STATE_REVIEW_REPORTS, map[string]interface{}{"index": index})
+			a.setState(ctx, chatID, state)
+		}
+		editReportCard(ctx, b, chatID, message.ID, state, "")
+	case "approve":
+		if len(parts) != 2 {
+			return
+		}
+		report_id, _ := strconv.Atoi(parts[1])
+		clienObj := a.client
+		if err := clienObj.Approve_report(ctx, report_id); err != nil {
+			a.logger.ErrorContext(ctx, "Ошибка принятия отчёта", "REPORT_ID", report_id, "ERROR", err)
+			editReportCard(ctx, b, chatID, message.ID, state, fmt.Sprintf("❌ Не удалось принять отчёт %d: %v", report_id, err))
+			return
+		}
+		a.finishReport(ctx, b, chatID, message.ID, state, parts[1], fmt.Sprintf("✅ Отчёт %d принят", report_id))
+	case "reject":
+		if len(parts) != 2 {
+			return
+		}
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:      chatID,
+			MessageID:   message.ID,
+			Text:        reportCardText(state, "Выберите причину отклонения:"),
+			ReplyMarkup: rejectKeyboard(parts[1]),
+		})
+	case "reason":
+		if len(parts) != 3 {
+			return
+		}
+		idx, err := strconv.Atoi(parts[2])
+		if err != nil || idx < 0 || idx >= len(rejectReasons) {
+			return
+		}
+		a.rejectReport(ctx, b, chatID, message.ID, state, parts[1], rejectReasons[idx])
+	case "auto":
+		if len(parts) != 2 {
+			return
+		}
+		verdicts, _ := state.Data["verdicts"].(map[string]*api.Verdict)
+		verdict := verdicts[parts[1]]
+		if verdict == nil || len(verdict.Reasons) == 0 {
+			return
+		}
+		a.rejectReport(ctx, b, chatID, message.ID, state, parts[1], strings.Join(verdict.Reasons, "; "))
+	case "custom":
+		if len(parts) != 2 {
+			return
+		}
+		state = reviewState(state, STATE_WAIT_REJECT_REASON, map[string]interface{}{"reject_id": parts[1]})
+		a.setState(ctx, chatID, state)
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      reportCardText(state, fmt.Sprintf("Напишите причину отклонения отчёта %s одним сообщением:", parts[1])),
+		})
+	case "all":
+		ids, flagged := approvableReports(state)
+		if len(ids) == 0 {
+			editReportCard(ctx, b, chatID, message.ID, state, "Все оставшиеся отчёты автопроверка рекомендует отклонить, проверьте их вручную.")
+			return
+		}
+		question := fmt.Sprintf("Принять отчёты на проверке (%s)? Будут приняты %d: %s.", state.Data["scope"], len(ids), strings.Join(ids, ", "))
+		if flagged > 0 {
+			question += fmt.Sprintf("\nОтчёты, которые автопроверка рекомендует отклонить (%d), останутся на ручную проверку.", flagged)
+		}
+		// После подтверждения или отмены проверка продолжится с того же места
+		a.askConfirmation(ctx, b, chatID, ACTION_APPROVE_ALL,
+			map[string]interface{}{"report_ids": ids, "scope": state.Data["scope"], "previous": state},
+			question, "✅ Принять все", "Отмена")
+	default:
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      "Проверка отчётов завершена.",
+		})
+		a.clearState(chatID)
+	}
+}
+
+func editReportCard(ctx context.Context, b *bot.Bot, chatID int64, messageID int, state *UserState, header string) {
+	b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      chatID,
+		MessageID:   messageID,
+		Text:        reportCardText(state, header),
+		ReplyMarkup: reportKeyboard(state),
+	})
+}
+
+// finishReport убирает проверенный отчёт и показывает следующий. Если messageID равен 0, карточка отправляется новым сообщением
+func (a *App) finishReport(ctx context.Context, b *bot.Bot, chatID int64, messageID int, state *UserState, id, header string) {
+	state, left := removeReport(state, id)
+	if !left {
+		text := header + "\n\nВсе отчёты проверены 🎉"
+		if messageID == 0 {
+			b.SendMessage(ctx, &bot.SendMessageParams{ChatID: chatID, Text: text})
+		} else {
+			b.EditMessageText(ctx, &bot.EditMessageTextParams{ChatID: chatID, MessageID: messageID, Text: text})
+		}
+		a.clearState(chatID)
+		return
+	}
+	a.setState(ctx, chatID, state)
+	if messageID == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      chatID,
+			Text:        reportCardText(state, header),
+			ReplyMarkup: reportKeyboard(state),
+		})
+		return
+	}
+	editReportCard(ctx, b, chatID, messageID, state, header)
+}
+
+func (a *App) rejectReport(ctx context.Context, b *bot.Bot, chatID int64, messageID int, state *UserState, id, reason string) {
+	report_id, _ := strconv.Atoi(id)
+	clienObj := a.client
+	if err := clienObj.Reject_report(ctx, report_id, reason); err != nil {
+		a.logger.ErrorContext(ctx, "Ошибка отклонения отчёта", "REPORT_ID", report_id, "ERROR", err)
+		header := fmt.Sprintf("❌ Не удалось отклонить отчёт %d: %v", report_id, err)
+		state = reviewState(state, STATE_REVIEW_REPORTS, nil)
+		a.setState(ctx, chatID, state)
+		if messageID == 0 {
+			b.SendMessage(ctx, &bot.SendMessageParams{ChatID: chatID, Text: reportCardText(state, header), ReplyMarkup: reportKeyboard(state)})
+			return
+		}
+		editReportCard(ctx, b, chatID, messageID, state, header)
+		return
+	}
+	a.finishReport(ctx, b, chatID, messageID, state, id, fmt.Sprintf("❌ Отчёт %d отклонён: %s", report_id, reason))
+}
+
+func (a *App) handleRejectReasonInput(ctx context.Context, b *bot.Bot, update *models.Update, state *UserState) {
+	chatID := update.Message.Chat.ID
+	unlock := a.lockChat(chatID)
+	defer unlock()
+	// Пока ждали блокировку, сессию могли закончить кнопкой
+	if current, exists := a.getState(chatID); !exists || current != state {
+		return
+	}
+	reason := strings.TrimSpace(update.Message.Text)
+	if reason == "" {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Причина не может быть пустой. Напишите еще раз:",
+		})
+		return
+	}
+	a.rejectReport(ctx, b, chatID, 0, state, fmt.Sprint(state.Data["reject_id"]), reason)
+}
+
+// runApproveAll принимает только отчёты, показанные в подтверждении, и возвращает к проверке оставшихся
+func (a *App) runApproveAll(ctx context.Context, b *bot.Bot, chatID int64, state *UserState) {
+	ids := state.Data["report_ids"].([]string)
+	review, _ := state.Data["previous"].(*UserState)
+	clienObj := a.client
+	approved := 0
+	result_text := ""
+	for _, id := range ids {
+		report_id, _ := strconv.Atoi(id)
+		if err := clienObj.Approve_report(ctx, report_id); err != nil {
+			a.logger.ErrorContext(ctx, "Ошибка принятия отчёта", "REPORT_ID", report_id, "ERROR", err)
+			result_text += fmt.Sprintf("\n❌ %d: %v", report_id, err)
+			continue
+		}
+		approved++
+		if review != nil {
+			review, _ = removeReport(review, id)
+		}
+	}
+	header := fmt.Sprintf("Принято отчётов: %d из %d (%s)%s", approved, len(ids), state.Data["scope"], result_text)
+	if review == nil || len(review.Data["reports"].([]api.Report)) == 0 {
+		sendLongMessage(ctx, b, chatID, header)
+		return
+	}
+	a.setState(ctx, chatID, review)
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        reportCardText(review, header),
+		ReplyMarkup: reportKeyboard(review),
+	})
+}
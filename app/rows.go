@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/utils"
+)
+
+// Подписи этапов обработки строки для сообщений пользователю
+var rowStatusTitles = map[string]string{
+	dbmodels.RowQueued:    "🕓 в очереди",
+	dbmodels.RowReading:   "📖 читаем из таблицы",
+	dbmodels.RowValidated: "🔎 проверена",
+	dbmodels.RowSent:      "📤 отправлена в UNU",
+	dbmodels.RowCreated:   "✅ задача создана",
+	dbmodels.RowFailed:    "❌ ошибка",
+	dbmodels.RowSkipped:   "⏭ пропущена",
+}
+
+// rowStatus отвечает на /row 42 историей строки, а на /row failed — списком строк на этапе
+func (a *App) rowStatus(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for row status", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+	args := commandArgs(update.Message.Text)
+	if len(args) != 1 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Использование: /row 42 — история строки, /row failed — строки на этапе.\nЭтапы: " + strings.Join(dbmodels.RowStatuses, ", "),
+		})
+		return
+	}
+
+	status := strings.ToLower(args[0])
+	if slices.Contains(dbmodels.RowStatuses, status) {
+		rows, err := a.store.ListRowsByStatus(ctx, status)
+		if err != nil {
+			b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: chatID,
+				Text:   a.storageErrorText(ctx, err),
+			})
+			return
+		}
+		text := fmt.Sprintf("Строк на этапе «%s»: %d", rowStatusTitles[status], len(rows))
+		if len(rows) > 0 {
+			numbers := make([]int, 0, len(rows))
+			for _, row := range rows {
+				number, err := strconv.Atoi(row)
+				if err == nil {
+					numbers = append(numbers, number)
+				}
+			}
+			text += "\n" + utils.FormatNumberRanges(numbers)
+		}
+		sendLongMessage(ctx, b, chatID, text)
+		return
+	}
+
+	state, err := a.store.GetRowStatus(ctx, args[0])
+	if errors.Is(err, dbmodels.ErrorNotFound) {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("Строка %s ещё не обрабатывалась", args[0]),
+		})
+		return
+	}
+	if errors.Is(err, dbmodels.ErrorIncorrectData) {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Номер строки должен быть положительным числом или названием этапа. Пример: /row 42",
+		})
+		return
+	}
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   a.storageErrorText(ctx, err),
+		})
+		return
+	}
+	sendLongMessage(ctx, b, chatID, formatRowState(state))
+}
+
+func formatRowState(state *dbmodels.RowState) string {
+	text := fmt.Sprintf("Строка %s: %s", state.Row, rowStatusTitles[state.Status])
+	if state.TaskId != 0 {
+		text += fmt.Sprintf("\nЗадача: %d", state.TaskId)
+	}
+	if state.Reason != "" {
+		text += "\nПричина: " + state.Reason
+	}
+	if state.Attempts > 0 {
+		text += fmt.Sprintf("\nНеудачных попыток: %d", state.Attempts)
+	}
+	text += "\nОбновлено: " + state.UpdatedAt.Format("02.01.2006 15:04:05")
+	if len(state.History) > 0 {
+		text += "\n\nИстория:"
+		for _, event := range state.History {
+			text += fmt.Sprintf("\n%s — %s", event.Time.Format("02.01 15:04:05"), rowStatusTitles[event.Status])
+			if event.TaskId != 0 {
+				text += fmt.Sprintf(" (задача %d)", event.TaskId)
+			}
+			if event.Reason != "" {
+				text += ": " + event.Reason
+			}
+		}
+	}
+	return text
+}
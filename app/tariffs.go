@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+)
+
+func (a *App) listTariffs(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for get tariffs", update.Message.Chat.Username, update.Message.Text))
+	chatID := update.Message.Chat.ID
+
+	tariffs, err := api.CachedTariffs(ctx, a.db, a.rdb, a.client)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось получить тарифы", "ERROR", err)
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("❌ Не удалось получить тарифы: %v", err),
+		})
+		return
+	}
+	if len(tariffs) == 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "UNU не вернул ни одного тарифа",
+		})
+		return
+	}
+
+	current := strconv.Itoa(a.cfg.UNU.TarifId)
+	result_text := "Тарифы UNU:"
+	for _, tariff := range tariffs {
+		mark := ""
+		if tariff.ID.String() == current {
+			mark = " ⭐️ используется ботом"
+		}
+		result_text += fmt.Sprintf("\n\n%s — ID %s%s\nМинимальная цена: %s ₽\nТаргетинг по полу: %s, геотаргетинг: %s",
+			tariff.Name, tariff.ID.String(), mark, tariff.MinPrice.String(), yesNo(bool(tariff.TargetingGender)), yesNo(bool(tariff.TargetingGeo)))
+	}
+	if err = a.settings.Validate(tariffs); err != nil {
+		result_text += fmt.Sprintf("\n\n⚠️ Текущие настройки задач не подходят: %v", err)
+	}
+	sendLongMessage(ctx, b, chatID, result_text)
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "да"
+	}
+	return "нет"
+}
@@ -0,0 +1,365 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/utils"
+)
+
+const (
+	ACTION_DELETE_TASKS = "delete_tasks"
+	ACTION_PAUSE_TASKS  = "pause_tasks"
+	ACTION_PLAY_TASKS   = "play_tasks"
+	ACTION_MOVE_TASKS   = "move_tasks"
+	ACTION_EDIT_TASKS   = "edit_tasks"
+	ACTION_ADD_LIMIT    = "add_limit"
+
+	STATE_WAIT_TASK_IDS   = "wait_task_ids"
+	STATE_WAIT_LIMIT      = "wait_limit"
+	STATE_WAIT_EDIT_FIELD = "wait_edit_field"
+	STATE_WAIT_EDIT_VALUE = "wait_edit_value"
+
+	// Префикс callback data у кнопок выбора поля для редактирования: edit:<field>
+	CALLBACK_EDIT = "edit:"
+
+	// Сколько задач перечислять по названиям в вопросе о подтверждении
+	describeTasksLimit = 10
+)
+
+// taskCommand действие UNU, которое применяется к каждой задаче из списка
+type taskCommand struct {
+	question string // что спрашиваем в подтверждении: "Удалить задачи"
+	result   string // итог для сводки: "Удалено задач"
+	apply    func(ctx context.Context, client api.UNUAPI, task_id int, state *UserState) error
+}
+
+var taskCommands = map[string]*taskCommand{
+	ACTION_DELETE_TASKS: {
+		question: "🗑 Удалить задачи",
+		result:   "Удалено задач",
+		apply: func(ctx context.Context, client api.UNUAPI, task_id int, state *UserState) error {
+			return client.Del_task(ctx, task_id)
+		},
+	},
+	ACTION_PAUSE_TASKS: {
+		question: "⏸ Остановить задачи",
+		result:   "Остановлено задач",
+		apply: func(ctx context.Context, client api.UNUAPI, task_id int, state *UserState) error {
+			return client.Task_pause(ctx, task_id)
+		},
+	},
+	ACTION_PLAY_TASKS: {
+		question: "▶️ Запустить задачи",
+		result:   "Запущено задач",
+		apply: func(ctx context.Context, client api.UNUAPI, task_id int, state *UserState) error {
+			return client.Task_play(ctx, task_id)
+		},
+	},
+	ACTION_MOVE_TASKS: {
+		question: "📁 Переместить задачи",
+		result:   "Перемещено задач",
+		apply: func(ctx context.Context, client api.UNUAPI, task_id int, state *UserState) error {
+			return client.Move_task(ctx, task_id, state.Data["folder_id"].(int))
+		},
+	},
+	ACTION_ADD_LIMIT: {
+		question: "➕ Увеличить лимит задач",
+		result:   "Увеличен лимит задач",
+		apply: func(ctx context.Context, client api.UNUAPI, task_id int, state *UserState) error {
+			return client.Task_limit_add(ctx, task_id, state.Data["limit"].(int))
+		},
+	},
+	ACTION_EDIT_TASKS: {
+		question: "✏️ Изменить задачи",
+		result:   "Изменено задач",
+		apply: func(ctx context.Context, client api.UNUAPI, task_id int, state *UserState) error {
+			return client.Edit_task(ctx, task_id, state.Data["edit"].(*api.TaskEdit))
+		},
+	},
+}
+
+// Поля задачи, которые можно изменить через /edit_task
+var editFields = []struct {
+	field string
+	title string
+}{
+	{"name", "Название"},
+	{"descr", "Описание"},
+	{"link", "Ссылка"},
+	{"need_for_report", "Что нужно для отчёта"},
+	{"price", "Цена"},
+}
+
+func (a *App) deleteTask(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.startTaskCommand(ctx, b, update, ACTION_DELETE_TASKS)
+}
+
+func (a *App) pauseTask(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.startTaskCommand(ctx, b, update, ACTION_PAUSE_TASKS)
+}
+
+func (a *App) playTask(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.startTaskCommand(ctx, b, update, ACTION_PLAY_TASKS)
+}
+
+func (a *App) moveTask(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.startTaskCommand(ctx, b, update, ACTION_MOVE_TASKS)
+}
+
+func (a *App) editTask(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.startTaskCommand(ctx, b, update, ACTION_EDIT_TASKS)
+}
+
+func (a *App) addLimit(ctx context.Context, b *bot.Bot, update *models.Update) {
+	a.startTaskCommand(ctx, b, update, ACTION_ADD_LIMIT)
+}
+
+// startTaskCommand начинает работу с задачами. ID можно передать сразу после команды: /pause_task 10-15
+func (a *App) startTaskCommand(ctx context.Context, b *bot.Bot, update *models.Update, action string) {
+	a.logger.InfoContext(ctx, fmt.Sprintf("User '%s' wrote '%s' for %s", update.Message.Chat.Username, update.Message.Text, action))
+	chatID := update.Message.Chat.ID
+	state := &UserState{
+		State:   STATE_WAIT_TASK_IDS,
+		Data:    map[string]interface{}{"action": action},
+		Command: action,
+	}
+	a.setState(ctx, chatID, state)
+
+	if args := commandArgs(update.Message.Text); len(args) > 0 {
+		a.handleTaskIdsInput(ctx, b, chatID, strings.Join(args, " "), state)
+		return
+	}
+	b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   "Пожалуйста, введите ID задач. Пример: 1234, 1240-1245",
+	})
+}
+
+func (a *App) handleTaskIdsInput(ctx context.Context, b *bot.Bot, chatID int64, input string, state *UserState) {
+	ids, err := utils.ParseNumberRanges(input)
+	if err != nil {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("Простите, вы ввели некорректные ID. Пример: 1234, 1240-1245 (не больше %d задач). Введите ID еще раз:", utils.MaxRangeSize),
+		})
+		return
+	}
+	action := fmt.Sprint(state.Data["action"])
+	data := map[string]interface{}{"action": action, "ids": ids}
+
+	switch action {
+	case ACTION_MOVE_TASKS:
+		a.askFolder(ctx, b, chatID, ACTION_MOVE_TASKS, data,
+			fmt.Sprintf("Выберите папку, в которую переместить задачи %s:", utils.FormatNumberRanges(ids)))
+	case ACTION_ADD_LIMIT:
+		a.setState(ctx, chatID, &UserState{
+			State:   STATE_WAIT_LIMIT,
+			Data:    data,
+			Command: action,
+		})
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "На сколько выполнений увеличить лимит каждой задачи?",
+		})
+	case ACTION_EDIT_TASKS:
+		a.setState(ctx, chatID, &UserState{
+			State:   STATE_WAIT_EDIT_FIELD,
+			Data:    data,
+			Command: action,
+		})
+		keyboard := [][]models.InlineKeyboardButton{}
+		for _, value := range editFields {
+			keyboard = append(keyboard, []models.InlineKeyboardButton{
+				{Text: value.title, CallbackData: CALLBACK_EDIT + value.field},
+			})
+		}
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      chatID,
+			Text:        fmt.Sprintf("Что изменить в задачах %s?", utils.FormatNumberRanges(ids)),
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+		})
+	default:
+		a.confirmTaskCommand(ctx, b, chatID, data, "")
+	}
+}
+
+func (a *App) confirmMoveTasks(ctx context.Context, b *bot.Bot, chatID int64, state *UserState, folder api.Folder) {
+	folderIdInt, err := strconv.Atoi(folder.ID.String())
+	if err != nil {
+		a.logger.ErrorContext(ctx, "Не удалось преобразовать folderId в тип integer", "ERROR:", err)
+		a.clearState(chatID)
+		return
+	}
+	data := map[string]interface{}{
+		"action":    ACTION_MOVE_TASKS,
+		"ids":       state.Data["ids"],
+		"folder_id": folderIdInt,
+	}
+	a.confirmTaskCommand(ctx, b, chatID, data, fmt.Sprintf("в папку '%s'", folder.Name))
+}
+
+func (a *App) handleLimitInput(ctx context.Context, b *bot.Bot, update *models.Update, state *UserState) {
+	chatID := update.Message.Chat.ID
+	limit, err := strconv.Atoi(strings.TrimSpace(update.Message.Text))
+	if err != nil || limit <= 0 {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Лимит должен быть положительным числом. Введите еще раз:",
+		})
+		return
+	}
+	state.Data["limit"] = limit
+	a.confirmTaskCommand(ctx, b, chatID, state.Data, fmt.Sprintf("на %d выполнений", limit))
+}
+
+func (a *App) handleEditFieldCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	message := callbackMessage(update)
+	if message == nil {
+		return
+	}
+	chatID := message.Chat.ID
+	state, exists := a.getState(chatID)
+	if !exists || state.State != STATE_WAIT_EDIT_FIELD || time.Since(state.CreatedAt) > 5*time.Minute {
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      "⚠️ Этот выбор устарел. Начните заново.",
+		})
+		return
+	}
+	field := strings.TrimPrefix(update.CallbackQuery.Data, CALLBACK_EDIT)
+	for _, value := range editFields {
+		if value.field != field {
+			continue
+		}
+		b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    chatID,
+			MessageID: message.ID,
+			Text:      fmt.Sprintf("%s\nВыбрано: %s", message.Text, value.title),
+		})
+		state.Data["field"] = field
+		state.Data["field_title"] = value.title
+		a.setState(ctx, chatID, &UserState{
+			State:   STATE_WAIT_EDIT_VALUE,
+			Data:    state.Data,
+			Command: state.Command,
+		})
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   fmt.Sprintf("Введите новое значение для поля '%s':", value.title),
+		})
+		return
+	}
+}
+
+func (a *App) handleEditValueInput(ctx context.Context, b *bot.Bot, update *models.Update, state *UserState) {
+	chatID := update.Message.Chat.ID
+	value := strings.TrimSpace(update.Message.Text)
+	if value == "" {
+		b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   "Значение не может быть пустым. Введите еще раз:",
+		})
+		return
+	}
+	edit := &api.TaskEdit{}
+	switch state.Data["field"] {
+	case "name":
+		edit.Name = value
+	case "descr":
+		edit.Descr = value
+	case "need_for_report":
+		edit.NeedForReport = value
+	case "link":
+		parsed, err := url.ParseRequestURI(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: chatID,
+				Text:   "Ссылка должна начинаться с http:// или https://. Введите еще раз:",
+			})
+			return
+		}
+		edit.Link = value
+	case "price":
+		price, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+		if err != nil || price <= 0 {
+			b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: chatID,
+				Text:   "Цена должна быть положительным числом. Введите еще раз:",
+			})
+			return
+		}
+		edit.Price = price
+	}
+	state.Data["edit"] = edit
+	a.confirmTaskCommand(ctx, b, chatID, state.Data, fmt.Sprintf("\nПоле '%s' → %s", state.Data["field_title"], value))
+}
+
+// confirmTaskCommand спрашивает подтверждение, перечисляя задачи по названиям, чтобы было видно опечатку в ID
+func (a *App) confirmTaskCommand(ctx context.Context, b *bot.Bot, chatID int64, data map[string]interface{}, details string) {
+	action := fmt.Sprint(data["action"])
+	ids := data["ids"].([]int)
+	question := fmt.Sprintf("%s %s (%d шт.)", taskCommands[action].question, utils.FormatNumberRanges(ids), len(ids))
+	if details != "" {
+		question += " " + details
+	}
+	question += "?" + a.describeTasks(ctx, ids)
+	a.askConfirmation(ctx, b, chatID, action, data, question, "✅ Подтвердить", "Отмена")
+}
+
+func (a *App) describeTasks(ctx context.Context, ids []int) string {
+	clienObj := a.client
+	tasks, err := clienObj.Get_tasks(ctx, 0)
+	if err != nil {
+		a.logger.WarnContext(ctx, "Не удалось получить список задач для подтверждения", "ERROR", err)
+		return ""
+	}
+	names := make(map[string]string, len(tasks))
+	for _, task := range tasks {
+		names[task.ID.String()] = task.Name
+	}
+	result_text := ""
+	for idx, id := range ids {
+		if idx == describeTasksLimit {
+			result_text += fmt.Sprintf("\n… и ещё %d", len(ids)-describeTasksLimit)
+			break
+		}
+		name, ok := names[strconv.Itoa(id)]
+		if !ok {
+			name = "⚠️ задача не найдена"
+		}
+		result_text += fmt.Sprintf("\n• %d — %s", id, name)
+	}
+	return result_text
+}
+
+func (a *App) runTaskCommand(ctx context.Context, b *bot.Bot, chatID int64, state *UserState) {
+	command := taskCommands[fmt.Sprint(state.Data["action"])]
+	ids := state.Data["ids"].([]int)
+
+	clienObj := a.client
+	done := 0
+	result_text := ""
+	for _, task_id := range ids {
+		err := command.apply(ctx, clienObj, task_id, state)
+		if err != nil {
+			a.logger.ErrorContext(ctx, "Ошибка при работе с задачей", "ACTION", state.Data["action"], "TASK_ID", task_id, "ERROR", err)
+			result_text += fmt.Sprintf("\n❌ %d: %v", task_id, err)
+			continue
+		}
+		done++
+		result_text += fmt.Sprintf("\n✅ %d", task_id)
+	}
+	sendLongMessage(ctx, b, chatID, fmt.Sprintf("%s: %d из %d%s", command.result, done, len(ids), result_text))
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/shakirovformal/unu_project_api_realizer/api"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/database"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/logger"
+	dbmodels "github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+const (
+	// После стольких неудачных попыток задание уходит в поток ошибок
+	jobMaxAttempts = 5
+	// Первая пауза перед повтором, дальше она удваивается до jobMaxBackoff
+	jobBaseBackoff = 30 * time.Second
+	jobMaxBackoff  = 10 * time.Minute
+	// Сколько ждать новых заданий за одно чтение очереди
+	jobReadBlock = 5 * time.Second
+	// Задание, не подтверждённое столько времени, считается брошенным и его забирает другой обработчик
+	jobClaimIdle = 2 * database.RowLockTTL
+	// Как долго обработчик проверяет повторы текста по уже загруженным необработанным строкам
+	rowValidatorMaxAge = 30 * time.Second
+)
+
+// startWorkers запускает обработчики очереди заданий на создание задач. Их число задаётся в настройке workers (TASK_WORKERS).
+// Обработчики останавливаются вместе с ctx, незавершённые задания остаются в очереди до следующего запуска
+func (a *App) startWorkers(ctx context.Context, b *bot.Bot) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	if err := a.db.EnsureJobGroup(ctx, a.rdb); err != nil {
+		a.logger.ErrorContext(ctx, "Очередь заданий недоступна, задачи не будут создаваться", "ERROR", err)
+		return &wg
+	}
+	workers := a.cfg.Workers
+	hostname, _ := os.Hostname()
+	for i := 0; i < workers; i++ {
+		consumer := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.runWorker(ctx, b, consumer)
+		}()
+	}
+	a.logger.InfoContext(ctx, "Обработчики очереди заданий запущены", "WORKERS", workers)
+	return &wg
+}
+
+func (a *App) runWorker(ctx context.Context, b *bot.Bot, consumer string) {
+	validators := api.NewRowValidators(a.store, rowValidatorMaxAge)
+	for ctx.Err() == nil {
+		a.db.PromoteDueJobs(ctx, a.rdb, time.Now())
+		jobs, err := a.db.ClaimStaleJobs(ctx, a.rdb, consumer, jobClaimIdle, 1)
+		if err == nil && len(jobs) == 0 {
+			jobs, err = a.db.ReadJobs(ctx, a.rdb, consumer, 1, jobReadBlock)
+		}
+		if err != nil {
+			// Redis недоступен, не крутим цикл впустую
+			select {
+			case <-ctx.Done():
+			case <-time.After(jobReadBlock):
+			}
+			continue
+		}
+		for _, job := range jobs {
+			a.processJob(ctx, b, validators, job)
+		}
+	}
+}
+
+// processJob создаёт задачу по строке из задания. Успех, занятая или пустая строка подтверждают задание,
+// временная ошибка откладывает повтор, постоянная ошибка или исчерпанные попытки переносят задание в поток ошибок
+func (a *App) processJob(ctx context.Context, b *bot.Bot, validators *api.RowValidators, queued database.QueuedJob) {
+	job := queued.Job
+	created := false
+	ctx = logger.With(logger.WithRow(ctx, strconv.Itoa(job.Row)), "BATCH", job.Batch, "CHAT_ID", job.ChatID)
+
+	// Каждая прошлая выдача, на которой обработчик упал или завис, считается неудачной попыткой
+	if queued.Deliveries > 1 {
+		job.Attempts += int(queued.Deliveries - 1)
+		if job.Attempts >= jobMaxAttempts {
+			reason := fmt.Sprintf("обработка задания прерывалась %d раз(а)", queued.Deliveries-1)
+			a.logger.ErrorContext(ctx, "Задание перенесено в поток ошибок", "ATTEMPTS", job.Attempts, "ERROR", reason)
+			job.LastError = reason
+			a.db.DeadLetterJob(ctx, a.rdb, queued.ID, job, reason)
+			a.reportJob(ctx, b, job, fmt.Sprintf("❌ Строка %d: %s", job.Row, reason), false)
+			return
+		}
+	}
+
+	tariffs, tariffsErr := api.CachedTariffs(ctx, a.db, a.rdb, a.client)
+	if tariffsErr != nil {
+		a.logger.WarnContext(ctx, "Не удалось получить тарифы, задача будет создана без проверки тарифа", "ERROR", tariffsErr)
+	}
+	task_id, err := a.createLockedTask(ctx, a.settings, tariffs, validators, job)
+	if ctx.Err() != nil {
+		// Бот останавливается: задание не подтверждаем, его заберут после перезапуска
+		return
+	}
+
+	line, failed := taskResultLine(job.Row, task_id, err)
+	switch {
+	case err == nil:
+		created = true
+		a.db.RemoveDeadJob(ctx, a.rdb, job.Row)
+	case failed:
+		job.Attempts++
+		job.LastError = err.Error()
+		if !isPermanentJobError(err) && job.Attempts < jobMaxAttempts {
+			delay := jobBackoff(job.Attempts)
+			a.logger.WarnContext(ctx, "Задание отложено для повтора", "ATTEMPT", job.Attempts, "DELAY", delay.String(), "ERROR", err)
+			a.db.RetryJobLater(ctx, a.rdb, queued.ID, job, delay)
+			return
+		}
+		a.logger.ErrorContext(ctx, "Задание перенесено в поток ошибок", "ATTEMPTS", job.Attempts, "ERROR", err)
+		a.db.DeadLetterJob(ctx, a.rdb, queued.ID, job, err.Error())
+		if errors.Is(err, dbmodels.ErrorDuplicateText) {
+			line += fmt.Sprintf("\nСоздать всё равно: /retry %d force", job.Row)
+		}
+		a.reportJob(ctx, b, job, line, false)
+		return
+	}
+	a.db.AckJob(ctx, a.rdb, queued.ID)
+	a.reportJob(ctx, b, job, line, created)
+}
+
+// taskResultLine строка отчёта о создании задачи по строке row. failed — строку не удалось обработать:
+// занятая другим пользователем, уже созданная или пустая строка ошибкой не считаются
+func taskResultLine(row, task_id int, err error) (line string, failed bool) {
+	var locked *database.RowLockedError
+	switch {
+	case err == nil:
+		return fmt.Sprintf("✅ Строка %d: задача %d", row, task_id), false
+	case errors.As(err, &locked):
+		return fmt.Sprintf("🔒 Строка %d уже обрабатывается пользователем %s", row, locked.Owner), false
+	case errors.Is(err, dbmodels.ErrorTaskCreated):
+		return fmt.Sprintf("⏭ Строка %d: задача %d по ней уже создана, содержимое строки не менялось. Создать заново: --force", row, task_id), false
+	case errors.Is(err, dbmodels.ErrorZeroValue):
+		return fmt.Sprintf("⏭ Строка %d пустая или заполнена не полностью", row), false
+	}
+	return fmt.Sprintf("❌ Строка %d: %v", row, err), true
+}
+
+// reportJob сохраняет результат задания и, если это последнее задание пачки, отправляет итог в чат
+func (a *App) reportJob(ctx context.Context, b *bot.Bot, job dbmodels.TaskJob, line string, created bool) {
+	batch, err := a.db.FinishJob(ctx, a.rdb, job, line, created)
+	if err != nil || batch == nil {
+		return
+	}
+	text := fmt.Sprintf("Создано задач: %d из %d", batch.Created, batch.Total)
+	for _, line := range batch.Lines {
+		text += "\n" + line
+	}
+	sendLongMessage(ctx, b, batch.ChatID, text)
+}
+
+// isPermanentJobError ошибки, которые не исправятся сами: повтор только потратит попытки
+func isPermanentJobError(err error) bool {
+	for _, permanent := range []error{
+		dbmodels.ErrorIncorrectData,
+		dbmodels.ErrorMatchingSite,
+		dbmodels.ErrorUnknownTariff,
+		dbmodels.ErrorTariffPrice,
+		dbmodels.ErrorTariffTargeting,
+		dbmodels.ErrorDuplicateText,
+	} {
+		if errors.Is(err, permanent) {
+			return true
+		}
+	}
+	return false
+}
+
+// jobBackoff пауза перед попыткой attempt: 30s, 1m, 2m, ... но не больше jobMaxBackoff
+func jobBackoff(attempt int) time.Duration {
+	delay := jobBaseBackoff
+	for i := 1; i < attempt && delay < jobMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, jobMaxBackoff)
+}
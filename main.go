@@ -1,20 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"os"
+	"os/signal"
 
 	"github.com/joho/godotenv"
-	"github.com/shakirovformal/unu_project_api_realizer/bot"
+	"github.com/shakirovformal/unu_project_api_realizer/app"
 )
 
 func init() {
+	// Без .env настройки берутся из окружения и файла CONFIG_FILE
 	err := godotenv.Load(".env")
-	if err != nil {
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		log.Fatalf("Ошибка загрузки файла .env: %v", err)
 	}
 }
 
 func main() {
-
-	bot.Bot()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := app.Main(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	cancel()
+	os.Exit(code)
 }
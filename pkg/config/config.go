@@ -0,0 +1,349 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Config настройки бота. Загружается один раз при запуске через Load и передаётся явно
+type Config struct {
+	Telegram Telegram `yaml:"telegram"`
+	UNU      UNU      `yaml:"unu"`
+	Sheet    Sheet    `yaml:"sheet"`
+	Redis    Redis    `yaml:"redis"`
+	Store    Store    `yaml:"store"`
+	Poll     Poll     `yaml:"poll"`
+	Log      Log      `yaml:"log"`
+	// Сколько обработчиков очереди создают задачи параллельно
+	Workers int `yaml:"workers"`
+}
+
+type Telegram struct {
+	Token    string  `yaml:"token"`
+	AdminIDs []int64 `yaml:"admin_ids"` // администраторы имеют доступ всегда
+}
+
+type UNU struct {
+	URL          string  `yaml:"url"`
+	Token        string  `yaml:"token"`
+	TaskPrice    float64 `yaml:"task_price"`
+	TarifId      int     `yaml:"tarif_id"`
+	TaskLimit    int     `yaml:"task_limit"` // сколько выполнений нужно по одной строке таблицы
+	GeoCountryId int     `yaml:"geo_country_id"`
+	// Предупреждать администраторов, когда доступный баланс ниже порога. 0 — только если не хватит на очередь
+	BalanceAlertThreshold float64 `yaml:"balance_alert_threshold"`
+}
+
+type Sheet struct {
+	SpreadsheetId   string `yaml:"spreadsheet_id"`
+	CredentialsFile string `yaml:"credentials_file"` // ключ сервисного аккаунта Google
+}
+
+type Redis struct {
+	Host           string        `yaml:"host"`
+	Password       string        `yaml:"password"`
+	DB             int           `yaml:"db"`
+	PoolSize       int           `yaml:"pool_size"`
+	MinIdleConns   int           `yaml:"min_idle_conns"`
+	DialTimeout    time.Duration `yaml:"dial_timeout"`
+	ReadTimeout    time.Duration `yaml:"read_timeout"`
+	WriteTimeout   time.Duration `yaml:"write_timeout"`
+	TLS            bool          `yaml:"tls"`
+	SentinelMaster string        `yaml:"sentinel_master"`
+	SentinelAddrs  []string      `yaml:"sentinel_addrs"`
+}
+
+// Store где хранить строки таблицы. Выбор не отменяет Redis: роли, блокировки, очередь заданий,
+// история текстов и кэш тарифов всегда в Redis, поэтому DB_HOST обязателен и для sqlite, и для memory
+type Store struct {
+	Backend    string `yaml:"backend"` // redis, sqlite или memory
+	SQLitePath string `yaml:"sqlite_path"`
+}
+
+// Poll фоновая проверка отчётов, сроков, лимитов и баланса
+type Poll struct {
+	Enabled           bool          `yaml:"enabled"`
+	Interval          time.Duration `yaml:"interval"`
+	DeadlineWarnHours int           `yaml:"deadline_warn_hours"`
+}
+
+// Log формат и подробность логов
+type Log struct {
+	Level  string `yaml:"level"`  // debug, info, warn или error
+	Format string `yaml:"format"` // text или json
+}
+
+// Default значения, которые действуют, если параметр не задан ни в файле, ни в окружении
+func Default() *Config {
+	return &Config{
+		UNU: UNU{
+			TaskLimit: 1,
+		},
+		Sheet: Sheet{
+			CredentialsFile: "creds.json",
+		},
+		Store: Store{
+			Backend:    "redis",
+			SQLitePath: "unu.db",
+		},
+		Poll: Poll{
+			Enabled:           true,
+			Interval:          10 * time.Minute,
+			DeadlineWarnHours: 12,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+		Workers: 4,
+	}
+}
+
+// Load собирает настройки: значения по умолчанию, затем YAML-файл path (если указан),
+// затем переменные окружения из .env, которые важнее файла. Возвращает все ошибки разом.
+// Настройки Telegram нужны только боту и проверяются отдельно через CheckTelegram
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: не удалось прочитать файл %s: %v", models.ErrorConfig, path, err)
+		}
+		if err = yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("%w: ошибка в файле %s: %v", models.ErrorConfig, path, err)
+		}
+	}
+	problems := cfg.applyEnv(os.Getenv)
+	problems = append(problems, cfg.problems()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w:\n- %s", models.ErrorConfig, strings.Join(problems, "\n- "))
+	}
+	return cfg, nil
+}
+
+// Validate проверяет все настройки, включая Telegram, например собранные в тестах без Load
+func (c *Config) Validate() error {
+	if problems := append(c.problems(), c.telegramProblems()...); len(problems) > 0 {
+		return fmt.Errorf("%w:\n- %s", models.ErrorConfig, strings.Join(problems, "\n- "))
+	}
+	return nil
+}
+
+// envReader переносит заданные переменные окружения в настройки и запоминает, какие не удалось разобрать
+type envReader struct {
+	getenv   func(string) string
+	problems []string
+}
+
+func (r *envReader) value(name string) (string, bool) {
+	value := strings.TrimSpace(r.getenv(name))
+	return value, value != ""
+}
+
+func (r *envReader) fail(name, value, expected string) {
+	r.problems = append(r.problems, fmt.Sprintf("%s=%q: ожидается %s", name, value, expected))
+}
+
+func (r *envReader) string(name string, target *string) {
+	if value, ok := r.value(name); ok {
+		*target = value
+	}
+}
+
+func (r *envReader) int(name string, target *int) {
+	if value, ok := r.value(name); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			r.fail(name, value, "целое число")
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (r *envReader) float(name string, target *float64) {
+	if value, ok := r.value(name); ok {
+		parsed, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+		if err != nil {
+			r.fail(name, value, "число")
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (r *envReader) duration(name string, target *time.Duration) {
+	if value, ok := r.value(name); ok {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			r.fail(name, value, "длительность, например 5s или 10m")
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (r *envReader) bool(name string, target *bool) {
+	if value, ok := r.value(name); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			r.fail(name, value, "true или false")
+			return
+		}
+		*target = parsed
+	}
+}
+
+func (r *envReader) list(name string, target *[]string) {
+	if value, ok := r.value(name); ok {
+		*target = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*target = append(*target, item)
+			}
+		}
+	}
+}
+
+// applyEnv переносит переменные окружения в настройки. Имена переменных остались прежними, чтобы подходил старый .env
+func (c *Config) applyEnv(getenv func(string) string) []string {
+	r := &envReader{getenv: getenv}
+	r.string("TG_TOKEN", &c.Telegram.Token)
+	var admins []string
+	r.list("TG_ADMIN_IDS", &admins)
+	if admins != nil {
+		c.Telegram.AdminIDs = nil
+		for _, value := range admins {
+			userId, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				r.fail("TG_ADMIN_IDS", value, "ID пользователя Telegram")
+				continue
+			}
+			c.Telegram.AdminIDs = append(c.Telegram.AdminIDs, userId)
+		}
+	}
+
+	r.string("URL_UNU", &c.UNU.URL)
+	r.string("UNU_API_TOKEN", &c.UNU.Token)
+	r.float("UNU_TASK_PRICE", &c.UNU.TaskPrice)
+	r.int("UNU_TARIF_ID", &c.UNU.TarifId)
+	r.int("UNU_TASK_LIMIT", &c.UNU.TaskLimit)
+	r.int("UNU_GEO_COUNTRY_ID", &c.UNU.GeoCountryId)
+	r.float("BALANCE_ALERT_THRESHOLD", &c.UNU.BalanceAlertThreshold)
+
+	r.string("SPREADSHEETID", &c.Sheet.SpreadsheetId)
+	r.string("GOOGLE_CREDENTIALS_FILE", &c.Sheet.CredentialsFile)
+
+	r.string("DB_HOST", &c.Redis.Host)
+	r.string("DB_PASSWORD", &c.Redis.Password)
+	r.int("DB_DB", &c.Redis.DB)
+	r.int("REDIS_POOL_SIZE", &c.Redis.PoolSize)
+	r.int("REDIS_MIN_IDLE_CONNS", &c.Redis.MinIdleConns)
+	r.duration("REDIS_DIAL_TIMEOUT", &c.Redis.DialTimeout)
+	r.duration("REDIS_READ_TIMEOUT", &c.Redis.ReadTimeout)
+	r.duration("REDIS_WRITE_TIMEOUT", &c.Redis.WriteTimeout)
+	r.bool("REDIS_TLS", &c.Redis.TLS)
+	r.string("REDIS_SENTINEL_MASTER", &c.Redis.SentinelMaster)
+	r.list("REDIS_SENTINEL_ADDRS", &c.Redis.SentinelAddrs)
+
+	r.string("STORE_BACKEND", &c.Store.Backend)
+	r.string("SQLITE_PATH", &c.Store.SQLitePath)
+
+	if value, ok := r.value("POLL_INTERVAL"); ok && strings.EqualFold(value, "off") {
+		c.Poll.Enabled = false
+	} else {
+		r.duration("POLL_INTERVAL", &c.Poll.Interval)
+	}
+	r.int("DEADLINE_WARN_HOURS", &c.Poll.DeadlineWarnHours)
+	r.int("TASK_WORKERS", &c.Workers)
+	r.string("LOG_LEVEL", &c.Log.Level)
+	r.string("LOG_FORMAT", &c.Log.Format)
+	return r.problems
+}
+
+// CheckTelegram проверяет настройки, без которых не запустится бот. Командам CLI они не нужны
+func (c *Config) CheckTelegram() error {
+	if problems := c.telegramProblems(); len(problems) > 0 {
+		return fmt.Errorf("%w:\n- %s", models.ErrorConfig, strings.Join(problems, "\n- "))
+	}
+	return nil
+}
+
+// problemChecker собирает ошибки проверки. В сообщении указаны и переменная окружения, и ключ YAML
+func problemChecker(problems *[]string) func(ok bool, name, key, message string) {
+	return func(ok bool, name, key, message string) {
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s (%s): %s", name, key, message))
+		}
+	}
+}
+
+func (c *Config) telegramProblems() []string {
+	var problems []string
+	check := problemChecker(&problems)
+	check(c.Telegram.Token != "", "TG_TOKEN", "telegram.token", "не задан токен бота")
+	return problems
+}
+
+// problems проверяет собранные настройки, кроме настроек Telegram
+func (c *Config) problems() []string {
+	var problems []string
+	check := problemChecker(&problems)
+	check(validURL(c.UNU.URL), "URL_UNU", "unu.url", "нужен адрес API вида https://unu.im/api")
+	check(c.UNU.Token != "", "UNU_API_TOKEN", "unu.token", "не задан ключ API UNU")
+	check(c.UNU.TaskPrice > 0, "UNU_TASK_PRICE", "unu.task_price", "стоимость задачи должна быть больше нуля")
+	check(c.UNU.TarifId > 0, "UNU_TARIF_ID", "unu.tarif_id", "не задан тариф задач")
+	check(c.UNU.TaskLimit > 0, "UNU_TASK_LIMIT", "unu.task_limit", "лимит выполнений должен быть больше нуля")
+	check(c.UNU.GeoCountryId >= 0, "UNU_GEO_COUNTRY_ID", "unu.geo_country_id", "не может быть отрицательным")
+	check(c.UNU.BalanceAlertThreshold >= 0, "BALANCE_ALERT_THRESHOLD", "unu.balance_alert_threshold", "не может быть отрицательным")
+	check(c.Sheet.SpreadsheetId != "", "SPREADSHEETID", "sheet.spreadsheet_id", "не задан ID Google таблицы")
+	check(c.Sheet.CredentialsFile != "", "GOOGLE_CREDENTIALS_FILE", "sheet.credentials_file", "не задан файл ключа Google")
+	check(c.Redis.Host != "", "DB_HOST", "redis.host", "не задан адрес Redis")
+	check(c.Redis.DB >= 0, "DB_DB", "redis.db", "номер базы не может быть отрицательным")
+	check(c.Redis.PoolSize >= 0 && c.Redis.MinIdleConns >= 0, "REDIS_POOL_SIZE", "redis.pool_size", "размер пула не может быть отрицательным")
+	check(c.Redis.DialTimeout >= 0 && c.Redis.ReadTimeout >= 0 && c.Redis.WriteTimeout >= 0,
+		"REDIS_*_TIMEOUT", "redis.*_timeout", "таймаут не может быть отрицательным")
+	check(c.Redis.SentinelMaster == "" || len(c.Redis.SentinelAddrs) > 0,
+		"REDIS_SENTINEL_ADDRS", "redis.sentinel_addrs", "для Sentinel нужны адреса")
+	check(c.Store.Backend == "redis" || c.Store.Backend == "sqlite" || c.Store.Backend == "memory",
+		"STORE_BACKEND", "store.backend", "хранилище строк redis, sqlite или memory")
+	check(c.Store.Backend != "sqlite" || c.Store.SQLitePath != "", "SQLITE_PATH", "store.sqlite_path", "не задан файл базы SQLite")
+	check(!c.Poll.Enabled || c.Poll.Interval >= time.Minute, "POLL_INTERVAL", "poll.interval", "интервал не меньше 1m или off")
+	check(c.Poll.DeadlineWarnHours > 0, "DEADLINE_WARN_HOURS", "poll.deadline_warn_hours", "должно быть больше нуля")
+	check(c.Workers > 0, "TASK_WORKERS", "workers", "нужен хотя бы один обработчик очереди")
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "LOG_LEVEL", "log.level", "уровень debug, info, warn или error")
+	check(c.Log.Format == "text" || c.Log.Format == "json", "LOG_FORMAT", "log.format", "формат text или json")
+	return problems
+}
+
+func validURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// Secrets токены и пароли из настроек, которые нельзя писать в логи
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for _, secret := range []string{c.Telegram.Token, c.UNU.Token, c.Redis.Password} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// String настройки для логов без токенов и паролей
+func (c *Config) String() string {
+	return fmt.Sprintf("UNU=%s tarif=%d price=%v limit=%d sheet=%s redis=%s/%d store=%s workers=%d poll=%v/%s log=%s/%s",
+		c.UNU.URL, c.UNU.TarifId, c.UNU.TaskPrice, c.UNU.TaskLimit, c.Sheet.SpreadsheetId,
+		c.Redis.Host, c.Redis.DB, c.Store.Backend, c.Workers, c.Poll.Enabled, c.Poll.Interval, c.Log.Level, c.Log.Format)
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setRequiredEnv задаёт обязательные параметры, без которых Load возвращает ошибку
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("TG_TOKEN", "123:abc")
+	t.Setenv("URL_UNU", "https://unu.im/api")
+	t.Setenv("UNU_API_TOKEN", "secret")
+	t.Setenv("UNU_TASK_PRICE", "15")
+	t.Setenv("UNU_TARIF_ID", "4")
+	t.Setenv("SPREADSHEETID", "sheet-id")
+	t.Setenv("DB_HOST", "localhost:6379")
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("TG_ADMIN_IDS", "1, 2")
+	t.Setenv("UNU_TASK_PRICE", "15,5")
+	t.Setenv("REDIS_SENTINEL_ADDRS", "a:26379, b:26379")
+	t.Setenv("POLL_INTERVAL", "off")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, "sheet-id", cfg.Sheet.SpreadsheetId)
+	assert.Equal(t, []int64{1, 2}, cfg.Telegram.AdminIDs)
+	assert.Equal(t, 15.5, cfg.UNU.TaskPrice)
+	assert.Equal(t, []string{"a:26379", "b:26379"}, cfg.Redis.SentinelAddrs)
+	assert.False(t, cfg.Poll.Enabled)
+	// Значения по умолчанию
+	assert.Equal(t, 1, cfg.UNU.TaskLimit)
+	assert.Equal(t, "redis", cfg.Store.Backend)
+	assert.Equal(t, "creds.json", cfg.Sheet.CredentialsFile)
+	assert.Equal(t, 4, cfg.Workers)
+	assert.Equal(t, "info", cfg.Log.Level)
+	assert.NotContains(t, cfg.String(), "secret")
+}
+
+func TestLoadFromFile(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("TASK_WORKERS", "8")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+unu:
+  task_limit: 3
+store:
+  backend: sqlite
+  sqlite_path: rows.db
+poll:
+  interval: 15m
+workers: 2
+`), 0o600))
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.UNU.TaskLimit)
+	assert.Equal(t, "sqlite", cfg.Store.Backend)
+	assert.Equal(t, "rows.db", cfg.Store.SQLitePath)
+	assert.Equal(t, 15*time.Minute, cfg.Poll.Interval)
+	// Переменная окружения важнее файла
+	assert.Equal(t, 8, cfg.Workers)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorIs(t, err, models.ErrorConfig)
+}
+
+// Без токена бота настройки загружаются для команд CLI, но бот не запустится
+func TestLoadWithoutTelegram(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("TG_TOKEN", "")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	err = cfg.CheckTelegram()
+	require.ErrorIs(t, err, models.ErrorConfig)
+	assert.Contains(t, err.Error(), "TG_TOKEN (telegram.token)")
+	require.ErrorContains(t, cfg.Validate(), "TG_TOKEN")
+
+	cfg.Telegram.Token = "123:abc"
+	require.NoError(t, cfg.CheckTelegram())
+	require.NoError(t, cfg.Validate())
+}
+
+func TestLoadErrors(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("SPREADSHEETID", "")
+	t.Setenv("UNU_TARIF_ID", "четыре")
+	t.Setenv("STORE_BACKEND", "mongo")
+	t.Setenv("POLL_INTERVAL", "10s")
+	t.Setenv("LOG_LEVEL", "verbose")
+
+	_, err := Load("")
+	require.ErrorIs(t, err, models.ErrorConfig)
+	for _, name := range []string{"SPREADSHEETID (sheet.spreadsheet_id)", "UNU_TARIF_ID=", "STORE_BACKEND (store.backend)", "POLL_INTERVAL (poll.interval)", "LOG_LEVEL (log.level)"} {
+		assert.Contains(t, err.Error(), name)
+	}
+}
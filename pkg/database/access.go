@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+const (
+	rolesKey   = "unu:acl:roles"
+	auditKey   = "unu:acl:audit"
+	auditLimit = 1000
+)
+
+// AuditEntry запись журнала о попытке доступа без необходимых прав
+type AuditEntry struct {
+	UserId   int64     `json:"userId"`
+	Username string    `json:"username"`
+	Text     string    `json:"text"`
+	Role     string    `json:"role"`
+	Required string    `json:"required"`
+	Time     time.Time `json:"time"`
+}
+
+func (db *Db) SetRole(ctx context.Context, rdb *redis.Client, userId int64, role string) error {
+	if userId <= 0 {
+		return models.ErrorIncorrectData
+	}
+	if models.RoleLevel(role) == 0 {
+		return models.ErrorUnknownRole
+	}
+	err := rdb.HSet(ctx, rolesKey, strconv.FormatInt(userId, 10), role).Err()
+	if err != nil {
+		slog.Error("Ошибка сохранения роли пользователя", "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+// GetRole возвращает роль пользователя. Если пользователю роль не выдана, возвращается пустая строка
+func (db *Db) GetRole(ctx context.Context, rdb *redis.Client, userId int64) (string, error) {
+	role, err := rdb.HGet(ctx, rolesKey, strconv.FormatInt(userId, 10)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		slog.Error("Ошибка получения роли пользователя", "ERROR", err)
+		return "", models.ErrorDatabase
+	}
+	return role, nil
+}
+
+func (db *Db) DelRole(ctx context.Context, rdb *redis.Client, userId int64) (int64, error) {
+	res, err := rdb.HDel(ctx, rolesKey, strconv.FormatInt(userId, 10)).Result()
+	if err != nil {
+		return 0, models.ErrorDatabase
+	}
+	return res, nil
+}
+
+func (db *Db) ListRoles(ctx context.Context, rdb *redis.Client) (map[int64]string, error) {
+	res, err := rdb.HGetAll(ctx, rolesKey).Result()
+	if err != nil {
+		return nil, models.ErrorDatabase
+	}
+	roles := make(map[int64]string, len(res))
+	for key, role := range res {
+		userId, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			slog.Warn("В списке ролей найден некорректный ID пользователя", "KEY", key)
+			continue
+		}
+		roles[userId] = role
+	}
+	return roles, nil
+}
+
+// AddAuditEntry сохраняет запись о несанкционированной попытке доступа. Хранятся только последние auditLimit записей
+func (db *Db) AddAuditEntry(ctx context.Context, rdb *redis.Client, entry *AuditEntry) error {
+	dbObjPrepared, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("Ошибка маршаллинга записи журнала доступа", "ERROR", err)
+		return err
+	}
+	pipe := rdb.TxPipeline()
+	pipe.LPush(ctx, auditKey, string(dbObjPrepared))
+	pipe.LTrim(ctx, auditKey, 0, auditLimit-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка записи в журнал доступа", "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
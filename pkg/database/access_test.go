@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoles(t *testing.T) {
+	db, rdb := testRedis(t)
+	ctx := context.TODO()
+
+	require.NoError(t, db.SetRole(ctx, rdb, 1001, models.RoleOperator))
+	role, err := db.GetRole(ctx, rdb, 1001)
+	require.NoError(t, err)
+	require.Equal(t, models.RoleOperator, role)
+
+	roles, err := db.ListRoles(ctx, rdb)
+	require.NoError(t, err)
+	require.Equal(t, models.RoleOperator, roles[1001])
+
+	require.ErrorIs(t, db.SetRole(ctx, rdb, 1001, "superuser"), models.ErrorUnknownRole)
+	require.ErrorIs(t, db.SetRole(ctx, rdb, 0, models.RoleViewer), models.ErrorIncorrectData)
+
+	deleted, err := db.DelRole(ctx, rdb, 1001)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	role, err = db.GetRole(ctx, rdb, 1001)
+	require.NoError(t, err)
+	require.Empty(t, role)
+}
+
+func TestAddAuditEntry(t *testing.T) {
+	db, rdb := testRedis(t)
+	err := db.AddAuditEntry(context.TODO(), rdb, &AuditEntry{
+		UserId:   42,
+		Username: "stranger",
+		Text:     "/balance",
+		Required: models.RoleViewer,
+		Time:     time.Now(),
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// Сколько ждать ответа на PING при подключении и проверке здоровья
+const pingTimeout = 5 * time.Second
+
+// ConnOptions настройки пула соединений с Redis. Нулевые значения — значения go-redis по умолчанию.
+// Если задан SentinelMaster, подключение идёт через Sentinel по адресам SentinelAddrs, а Addr не используется
+type ConnOptions struct {
+	PoolSize       int
+	MinIdleConns   int
+	DialTimeout    time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	TLS            bool
+	SentinelMaster string
+	SentinelAddrs  []string
+}
+
+// Connect создаёт клиент с пулом соединений. Клиент один на всё приложение, закрывать его нужно при остановке
+func (db *Db) Connect() *redis.Client {
+	var tlsConfig *tls.Config
+	if db.Conn.TLS {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if db.Conn.SentinelMaster != "" {
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    db.Conn.SentinelMaster,
+			SentinelAddrs: db.Conn.SentinelAddrs,
+			Password:      db.Password,
+			DB:            db.DB,
+			PoolSize:      db.Conn.PoolSize,
+			MinIdleConns:  db.Conn.MinIdleConns,
+			DialTimeout:   db.Conn.DialTimeout,
+			ReadTimeout:   db.Conn.ReadTimeout,
+			WriteTimeout:  db.Conn.WriteTimeout,
+			TLSConfig:     tlsConfig,
+		})
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:         db.Addr,
+		Password:     db.Password,
+		DB:           db.DB,
+		PoolSize:     db.Conn.PoolSize,
+		MinIdleConns: db.Conn.MinIdleConns,
+		DialTimeout:  db.Conn.DialTimeout,
+		ReadTimeout:  db.Conn.ReadTimeout,
+		WriteTimeout: db.Conn.WriteTimeout,
+		TLSConfig:    tlsConfig,
+	})
+}
+
+// Open создаёт клиент и проверяет, что Redis отвечает. Если нет, клиент закрывается
+// и возвращается models.ErrorRedisUnavailable с адресом и причиной
+func (db *Db) Open(ctx context.Context) (*redis.Client, error) {
+	rdb := db.Connect()
+	err := db.Ping(ctx, rdb)
+	if err != nil {
+		rdb.Close()
+		return nil, err
+	}
+	slog.Info("Подключение к Redis установлено", "REDIS", db.String(), "POOL_SIZE", rdb.Options().PoolSize)
+	return rdb, nil
+}
+
+// Ping проверяет связь с Redis
+func (db *Db) Ping(ctx context.Context, rdb *redis.Client) error {
+	ctxPing, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	err := rdb.Ping(ctxPing).Err()
+	if err != nil {
+		slog.Error("Redis не отвечает", "REDIS", db.String(), "ERROR", err)
+		return fmt.Errorf("%w: %s: %v", models.ErrorRedisUnavailable, db.String(), err)
+	}
+	return nil
+}
+
+// String описывает подключение для логов и сообщений без пароля
+func (db *Db) String() string {
+	if db.Conn.SentinelMaster != "" {
+		return fmt.Sprintf("sentinel %s@%s db %d", db.Conn.SentinelMaster, strings.Join(db.Conn.SentinelAddrs, ","), db.DB)
+	}
+	return fmt.Sprintf("%s db %d", db.Addr, db.DB)
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	server := miniredis.RunT(t)
+	db := NewDB(server.Addr(), "", 0)
+	db.Conn = ConnOptions{PoolSize: 3, DialTimeout: time.Second}
+
+	rdb, err := db.Open(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, 3, rdb.Options().PoolSize)
+	require.NoError(t, db.Ping(context.TODO(), rdb))
+
+	server.Close()
+	require.ErrorIs(t, db.Ping(context.TODO(), rdb), models.ErrorRedisUnavailable)
+	require.NoError(t, rdb.Close())
+}
+
+func TestOpenUnavailable(t *testing.T) {
+	db := NewDB("127.0.0.1:1", "secret", 0)
+	db.Conn = ConnOptions{DialTimeout: 100 * time.Millisecond}
+
+	_, err := db.Open(context.TODO())
+	require.ErrorIs(t, err, models.ErrorRedisUnavailable)
+	require.Contains(t, err.Error(), "127.0.0.1:1")
+	require.NotContains(t, err.Error(), "secret")
+}
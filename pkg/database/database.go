@@ -7,9 +7,11 @@ import (
 	"log/slog"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/validation"
 )
 
 var ctx = context.Background()
@@ -18,6 +20,10 @@ type Db struct {
 	Addr     string
 	Password string
 	DB       int
+	// Таблица и лист, строки которых хранятся в базе. Строки разных таблиц не пересекаются по ключам
+	SpreadsheetId string
+	Sheet         string
+	Conn          ConnOptions
 }
 
 func NewDB(addr, password string, db int) *Db {
@@ -28,13 +34,34 @@ func NewDB(addr, password string, db int) *Db {
 	}
 }
 
-func (db *Db) Connect(database *Db) *redis.Client {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     database.Addr,
-		Password: database.Password,
-		DB:       database.DB,
-	})
-	return rdb
+// ForSheet возвращает копию Db, которая хранит строки листа sheet таблицы spreadsheetId
+func (db *Db) ForSheet(spreadsheetId, sheet string) *Db {
+	copyDb := *db
+	copyDb.SpreadsheetId = spreadsheetId
+	copyDb.Sheet = sheet
+	return &copyDb
+}
+
+// rowPrefix возвращает префикс ключей строк: unu:{spreadsheetId}:{sheet}
+func (db *Db) rowPrefix() string {
+	spreadsheetId, sheet := db.SpreadsheetId, db.Sheet
+	if spreadsheetId == "" {
+		spreadsheetId = "default"
+	}
+	if sheet == "" {
+		sheet = "default"
+	}
+	return fmt.Sprintf("unu:%s:%s", spreadsheetId, sheet)
+}
+
+// rowKey ключ строки таблицы: unu:{spreadsheetId}:{sheet}:row:{n}
+func (db *Db) rowKey(rowNumber string) string {
+	return db.rowPrefix() + ":row:" + rowNumber
+}
+
+// pendingKey множество номеров строк, которые ждут обработки
+func (db *Db) pendingKey() string {
+	return db.rowPrefix() + ":pending"
 }
 
 func (db *Db) AddRow(ctx context.Context, rdb *redis.Client, rowNumber string, rowObject *models.RowObject) error {
@@ -49,7 +76,10 @@ func (db *Db) AddRow(ctx context.Context, rdb *redis.Client, rowNumber string, r
 		slog.Error("Ошибка маршаллинга структуры для сохранения в БД в формате JSON", "ERROR", err)
 		return err
 	}
-	err = rdb.Set(ctx, rowNumber, string(dbObjPrepared), 0).Err()
+	pipe := rdb.TxPipeline()
+	pipe.Set(ctx, db.rowKey(rowNumber), string(dbObjPrepared), 0)
+	pipe.SAdd(ctx, db.pendingKey(), rowNumber)
+	_, err = pipe.Exec(ctx)
 	if err != nil {
 		slog.Error("Ошибка создания ключа в базе данных", "ERROR", err)
 
@@ -58,25 +88,62 @@ func (db *Db) AddRow(ctx context.Context, rdb *redis.Client, rowNumber string, r
 	return nil
 }
 
-func (db *Db) GetRow(ctx context.Context, rdb *redis.Client, rowNumber string) error {
+// GetRow возвращает сохранённую строку таблицы. Если строки нет, возвращается models.ErrorNotFound
+func (db *Db) GetRow(ctx context.Context, rdb *redis.Client, rowNumber string) (*models.RowObject, error) {
 	err := validateRowNumber(rowNumber)
 	if err != nil {
-		return models.ErrorIncorrectData
+		return nil, models.ErrorIncorrectData
+	}
+	gettingRes, err := rdb.Get(ctx, db.rowKey(rowNumber)).Result()
+	if err == redis.Nil {
+		return nil, models.ErrorNotFound
 	}
-	gettingRes, err := rdb.Get(ctx, rowNumber).Result()
 	if err != nil {
 		slog.Error(fmt.Sprintf("Ошибка получения значения с ключом %s", rowNumber), "ERROR", err)
-		return models.ErrorDatabase
+		return nil, models.ErrorDatabase
 	}
 	var unmarshalStruct models.RowObject
 
 	err = json.Unmarshal([]byte(gettingRes), &unmarshalStruct)
 	if err != nil {
 		slog.Error("Проблема размаршалливания JSON в структуру", "ERROR", err)
-		return models.ErrorUnmarshallJSON
+		return nil, models.ErrorUnmarshallJSON
 	}
-	fmt.Println("Struct:", unmarshalStruct.Object.Project)
-	return nil
+	return &unmarshalStruct, nil
+}
+
+// GetRows загружает несколько строк одним запросом MGET. Строк, которых нет в базе, не будет в результате
+func (db *Db) GetRows(ctx context.Context, rdb *redis.Client, rowNumbers []string) (map[string]*models.RowObject, error) {
+	rows := make(map[string]*models.RowObject, len(rowNumbers))
+	if len(rowNumbers) == 0 {
+		return rows, nil
+	}
+	keys := make([]string, len(rowNumbers))
+	for i, rowNumber := range rowNumbers {
+		if validateRowNumber(rowNumber) != nil {
+			return nil, models.ErrorIncorrectData
+		}
+		keys[i] = db.rowKey(rowNumber)
+	}
+	values, err := rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		slog.Error("Ошибка получения строк из базы", "COUNT", len(keys), "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var rowObject models.RowObject
+		err = json.Unmarshal([]byte(data), &rowObject)
+		if err != nil {
+			slog.Error("Проблема размаршалливания JSON в структуру", "ROW", rowNumbers[i], "ERROR", err)
+			return nil, models.ErrorUnmarshallJSON
+		}
+		rows[rowNumbers[i]] = &rowObject
+	}
+	return rows, nil
 }
 
 func (db *Db) DelRow(ctx context.Context, rdb *redis.Client, rowNumber string) (int64, error) {
@@ -84,58 +151,157 @@ func (db *Db) DelRow(ctx context.Context, rdb *redis.Client, rowNumber string) (
 	if err != nil {
 		return 0, models.ErrorIncorrectData
 	}
-	res, err := rdb.Del(ctx, rowNumber).Result()
+	pipe := rdb.TxPipeline()
+	res := pipe.Del(ctx, db.rowKey(rowNumber))
+	pipe.SRem(ctx, db.pendingKey(), rowNumber)
+	_, err = pipe.Exec(ctx)
 	if err != nil {
 		return 0, models.ErrorDatabase
 	}
-	return res, nil
+	return res.Val(), nil
 }
 
-func (db *Db) CheckUnfullfilledRows(ctx context.Context, rdb *redis.Client) ([]string, error) {
-	sliceKeys, err := rdb.Keys(ctx, "*").Result()
-	if err != nil {
-		return nil, models.ErrorDatabase
-	}
-	fmt.Println(sliceKeys)
-	return sliceKeys, nil
+// Строка, по которой создана задача, хранится ещё taskRowTTL: её текст нужен для проверки отчётов
+const taskRowTTL = 30 * 24 * time.Hour
+
+func taskKey(taskId int) string {
+	return fmt.Sprintf("unu:task:%d", taskId)
 }
 
-func validateRowObject(rowNumber string, obj *models.RowObject) error {
+// taskProjectsKey проекты задач: ID задачи → проект. Хранится без срока, нужен для отчётов о расходах за прошлые месяцы
+const taskProjectsKey = "unu:task_projects"
 
-	if len(rowNumber) == 0 {
-		slog.Error("Не получен номер строки для добавления в базу данных")
+// SaveTaskRow сохраняет строку таблицы, по которой была создана задача taskId, и отдельно, без срока хранения, её проект
+func (db *Db) SaveTaskRow(ctx context.Context, rdb *redis.Client, taskId int, rowObject *models.RowObject) error {
+	if taskId <= 0 || rowObject == nil {
 		return models.ErrorIncorrectData
 	}
-	if obj == nil {
-		slog.Error("Не получен объект для добавления в базу данных")
-		return models.ErrorIncorrectData
+	dbObjPrepared, err := json.Marshal(rowObject)
+	if err != nil {
+		slog.Error("Ошибка маршаллинга структуры для сохранения в БД в формате JSON", "ERROR", err)
+		return err
 	}
-	if obj.UserId <= 0 {
-		slog.Error("User ID не может быть нулевым")
-		return models.ErrorIncorrectData
+	pipe := rdb.TxPipeline()
+	pipe.Set(ctx, taskKey(taskId), string(dbObjPrepared), taskRowTTL)
+	pipe.HSet(ctx, taskProjectsKey, strconv.Itoa(taskId), rowObject.Object.Project)
+	if _, err = pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка сохранения строки задачи в базе данных", "ERROR", err)
+		return models.ErrorDatabase
 	}
-	if obj.Object.Project == "" || len(strings.ReplaceAll(obj.Object.Project, " ", "")) == 0 {
-		slog.Error("Не получено название проекта для добавления в базу данных")
-		return models.ErrorIncorrectData
+	return nil
+}
+
+// GetTaskProjects возвращает проекты задач taskIds, сохранённые SaveTaskRow
+func (db *Db) GetTaskProjects(ctx context.Context, rdb *redis.Client, taskIds []int) (map[int]string, error) {
+	projects := make(map[int]string, len(taskIds))
+	if len(taskIds) == 0 {
+		return projects, nil
 	}
-	if obj.Object.Link == "" {
-		slog.Error("Не получена ссылка для добавления в базу данных")
-		return models.ErrorIncorrectData
+	fields := make([]string, len(taskIds))
+	for i, taskId := range taskIds {
+		fields[i] = strconv.Itoa(taskId)
 	}
-	if obj.Object.TextDescription == "" {
-		slog.Error("Не получено описание работы для добавления в базу данных")
-		return models.ErrorIncorrectData
+	values, err := rdb.HMGet(ctx, taskProjectsKey, fields...).Result()
+	if err != nil {
+		slog.Error("Ошибка получения проектов задач", "ERROR", err)
+		return nil, models.ErrorDatabase
 	}
-	if obj.Object.DateOfPublication == "" {
-		slog.Error("Не получена дата для публикации для добавления в базу данных")
-		return models.ErrorIncorrectData
+	for i, value := range values {
+		if project, ok := value.(string); ok && project != "" {
+			projects[taskIds[i]] = project
+		}
 	}
-	if obj.Object.Gender <= 0 || obj.Object.Gender > 2 {
-		slog.Error("Не валидное значение гендерного пола для добавления в базу данных")
-		return models.ErrorIncorrectData
+	return projects, nil
+}
+
+// GetTaskRow возвращает строку таблицы, по которой была создана задача taskId.
+// Если строки нет, возвращается models.ErrorNotFound
+func (db *Db) GetTaskRow(ctx context.Context, rdb *redis.Client, taskId int) (*models.RowObject, error) {
+	gettingRes, err := rdb.Get(ctx, taskKey(taskId)).Result()
+	if err == redis.Nil {
+		return nil, models.ErrorNotFound
 	}
-	return nil
+	if err != nil {
+		slog.Error(fmt.Sprintf("Ошибка получения строки задачи %d", taskId), "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	var rowObject models.RowObject
+	err = json.Unmarshal([]byte(gettingRes), &rowObject)
+	if err != nil {
+		slog.Error("Проблема размаршалливания JSON в структуру", "ERROR", err)
+		return nil, models.ErrorUnmarshallJSON
+	}
+	return &rowObject, nil
 }
+
+// CheckUnfullfilledRows возвращает номера строк, которые сохранены в базу, но задачи по ним ещё не созданы.
+// Номера берутся из индекса pending и отсортированы по возрастанию
+func (db *Db) CheckUnfullfilledRows(ctx context.Context, rdb *redis.Client) ([]string, error) {
+	members, err := rdb.SMembers(ctx, db.pendingKey()).Result()
+	if err != nil {
+		return nil, models.ErrorDatabase
+	}
+	return sortRowNumbers(members), nil
+}
+
+// migrateRowKeyScript переносит строку в новый ключ и добавляет её в индекс pending одной операцией,
+// чтобы строка не оказалась под новым ключом без индекса. KEYS: старый ключ, новый ключ, pending. ARGV: номер строки
+var migrateRowKeyScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 or redis.call("EXISTS", KEYS[2]) == 1 then
+	return 0
+end
+redis.call("RENAME", KEYS[1], KEYS[2])
+redis.call("SADD", KEYS[3], ARGV[1])
+return 1`)
+
+// MigrateBareRowKeys переносит строки, сохранённые старыми версиями под ключом-номером ("5"),
+// в ключи unu:{spreadsheetId}:{sheet}:row:{n} и добавляет их в индекс pending.
+// Нужен только хранилищу redis: остальные хранилища не читают ключи строк из Redis.
+// Ключи ищутся через SCAN, остальные данные в базе не трогаются. Возвращает число перенесённых строк
+func (db *Db) MigrateBareRowKeys(ctx context.Context, rdb *redis.Client) (int, error) {
+	migrated := 0
+	iter := rdb.Scan(ctx, 0, "[0-9]*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if validateRowNumber(key) != nil {
+			continue
+		}
+		value, err := rdb.Get(ctx, key).Result()
+		if err != nil {
+			continue
+		}
+		var rowObject models.RowObject
+		if json.Unmarshal([]byte(value), &rowObject) != nil {
+			continue
+		}
+		moved, err := migrateRowKeyScript.Run(ctx, rdb, []string{key, db.rowKey(key), db.pendingKey()}, key).Int()
+		if err != nil {
+			slog.Error("Не удалось перенести строку в новый ключ", "ROW", key, "ERROR", err)
+			return migrated, models.ErrorDatabase
+		}
+		if moved == 0 {
+			slog.Warn("Строка уже есть под новым ключом, старый ключ оставлен без изменений", "ROW", key)
+			continue
+		}
+		migrated++
+	}
+	if err := iter.Err(); err != nil {
+		slog.Error("Ошибка при поиске старых ключей строк", "ERROR", err)
+		return migrated, models.ErrorDatabase
+	}
+	return migrated, nil
+}
+
+// validateRowObject проверяет строку перед сохранением. Ошибка — validation.Errors со всеми полями,
+// которые нужно исправить; errors.Is(err, models.ErrorIncorrectData) для неё выполняется
+func validateRowObject(rowNumber string, obj *models.RowObject) error {
+	err := validation.ValidateRow(rowNumber, obj)
+	if err != nil {
+		slog.Error("Строка не прошла проверку перед сохранением в базу", "ROW", rowNumber, "ERROR", err)
+	}
+	return err
+}
+
 func validateRowNumber(rowNumber string) error {
 	rowNumberInt, err := strconv.Atoi(rowNumber)
 	if err != nil {
@@ -2,12 +2,13 @@ package database
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/validation"
 	"github.com/stretchr/testify/require"
 )
 
@@ -29,12 +30,6 @@ func TestAddRow(t *testing.T) {
 		models.NewRowObject(17, "项目名称", "site.com", 2, "中文描述", "01.01.2024"),
 		models.NewRowObject(18, "プロジェクト名", "site.com", 1, "日本語の説明", "01.01.2024"),
 
-		// NULL-эквиваленты (если ваша система их поддерживает)
-		models.NewRowObject(19, "NULL", "NULL", 1, "NULL", "NULL"),
-		// Невалидные URL
-		models.NewRowObject(5, "Проект", "not-a-valid-url", 1, "Описание", "30.02.2023"), // невалидная дата
-		models.NewRowObject(10, "Проект", "site.com", 1, "Описание", "invalid-date"),
-		models.NewRowObject(11, "Проект", "site.com", 2, "Описание", "2024-13-45"), // невалидная дата
 		// Юникод и эмодзи
 		models.NewRowObject(12, "Проект 🚀", "site.com", 1, "Описание с эмодзи 👍 и Unicode 测试", "01.01.2024"),
 		// Очень большой ID
@@ -62,22 +57,22 @@ func TestAddRow(t *testing.T) {
 
 		// Отрицательный ID
 		models.NewRowObject(-1, "Проект", "site.com", 2, "Описание", "01.01.2024"),
-	}
 
-	type Config struct {
-		Addr     string
-		Password string
-		DB       int
-	}
-	var dbConfig Config = Config{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
+		// NULL-эквиваленты: ни ссылка, ни дата
+		models.NewRowObject(19, "NULL", "NULL", 1, "NULL", "NULL"),
+		// Невалидные URL
+		models.NewRowObject(5, "Проект", "not-a-valid-url", 1, "Описание", "01.01.2023"),
+		models.NewRowObject(5, "Проект", "ftp://site.com", 1, "Описание", "01.01.2023"),
+		// Невалидные даты
+		models.NewRowObject(5, "Проект", "site.com", 1, "Описание", "30.02.2023"),
+		models.NewRowObject(10, "Проект", "site.com", 1, "Описание", "invalid-date"),
+		models.NewRowObject(11, "Проект", "site.com", 2, "Описание", "2024-13-45"),
+		// Слишком длинный текст
+		models.NewRowObject(12, "Проект", "site.com", 2, strings.Repeat("а", 2301), "01.01.2024"),
 	}
+
 	var err error
-	expErr := fmt.Sprint(models.ErrorIncorrectData)
-	db := NewDB(dbConfig.Addr, dbConfig.Password, dbConfig.DB)
-	rdb := db.Connect(db)
+	db, rdb := testRedis(t)
 	for idx, value := range testNormalData {
 		err := db.AddRow(context.TODO(), rdb, strconv.Itoa(idx), value)
 		require.NoError(t, err, models.ErrorIncorrectData)
@@ -85,55 +80,42 @@ func TestAddRow(t *testing.T) {
 	for idx, value := range testDataWithError {
 		err = db.AddRow(context.TODO(), rdb, strconv.Itoa(idx), value)
 		slog.Info("ERROR:", "ERROR:", err)
-		require.EqualError(t, err, expErr, "Expected a specific error message")
+		require.ErrorIs(t, err, models.ErrorIncorrectData, "Expected a specific error")
 	}
 
+	// В ошибке перечислены все поля, которые нужно исправить
+	err = db.AddRow(context.TODO(), rdb, "20", models.NewRowObject(1, "", "not-a-valid-url", 1, "Описание", "2024-13-45"))
+	var fieldErrors validation.Errors
+	require.ErrorAs(t, err, &fieldErrors)
+	require.Equal(t, []string{validation.FieldProject, validation.FieldLink, validation.FieldPublication}, fieldErrors.Fields())
 }
 
 func TestGetRow(t *testing.T) {
-	type Config struct {
-		Addr     string
-		Password string
-		DB       int
-	}
-	var dbConfig Config = Config{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
-	}
 	var err error
 	rowNumbersPositive := []string{"1", "2", "3"}
 	rowNumbersNegative := []string{"0", "-1", "", "true"}
-	db := NewDB(dbConfig.Addr, dbConfig.Password, dbConfig.DB)
-	rdb := db.Connect(db)
+	db, rdb := testRedis(t)
 	for _, v := range rowNumbersPositive {
-		err = db.GetRow(context.TODO(), rdb, v)
-
+		err = db.AddRow(context.TODO(), rdb, v, models.NewRowObject(1, "Проект", "site.com", 1, "Описание", "01.01.2024"))
 		require.NoError(t, err)
 	}
+	for _, v := range rowNumbersPositive {
+		row, err := db.GetRow(context.TODO(), rdb, v)
+		require.NoError(t, err)
+		require.Equal(t, "Проект", row.Object.Project)
+	}
 	for _, v := range rowNumbersNegative {
-		err = db.GetRow(context.TODO(), rdb, v)
+		_, err = db.GetRow(context.TODO(), rdb, v)
 		require.Error(t, err)
 	}
 
 }
 
 func TestDelRow(t *testing.T) {
-	type Config struct {
-		Addr     string
-		Password string
-		DB       int
-	}
-	var dbConfig Config = Config{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
-	}
 	var err error
 	rowNumbersPositive := []string{"1", "2", "3"}
 	rowNumbersNegative := []string{"0", "-1", "", "true"}
-	db := NewDB(dbConfig.Addr, dbConfig.Password, dbConfig.DB)
-	rdb := db.Connect(db)
+	db, rdb := testRedis(t)
 	for _, v := range rowNumbersPositive {
 		_, err = db.DelRow(context.TODO(), rdb, v)
 		require.NoError(t, err)
@@ -146,18 +128,77 @@ func TestDelRow(t *testing.T) {
 }
 
 func TestGetAllKeys(t *testing.T) {
-	type Config struct {
-		Addr     string
-		Password string
-		DB       int
-	}
-	var dbConfig Config = Config{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
-	}
-	db := NewDB(dbConfig.Addr, dbConfig.Password, dbConfig.DB)
-	rdb := db.Connect(db)
+	db, rdb := testRedis(t)
 	_, err := db.CheckUnfullfilledRows(ctx, rdb)
 	require.NoError(t, err)
 }
+
+func TestPendingRowsIndex(t *testing.T) {
+	db, rdb := testRedis(t)
+	db = db.ForSheet("test-sheet-id", "BOT")
+	other := db.ForSheet("other-sheet-id", "BOT")
+	ctx := context.TODO()
+
+	row := models.NewRowObject(1, "Проект", "site.com", 1, "Описание", "01.01.2024")
+	for _, rowNumber := range []string{"12", "3", "7"} {
+		require.NoError(t, db.AddRow(ctx, rdb, rowNumber, row))
+	}
+	require.NoError(t, other.AddRow(ctx, rdb, "5", row))
+	// Посторонние ключи не должны попадать в список строк
+	require.NoError(t, rdb.Set(ctx, "42", "state", 0).Err())
+
+	rows, err := db.CheckUnfullfilledRows(ctx, rdb)
+	require.NoError(t, err)
+	require.Equal(t, []string{"3", "7", "12"}, rows)
+	require.Equal(t, "unu:test-sheet-id:BOT:row:7", db.rowKey("7"))
+
+	_, err = db.DelRow(ctx, rdb, "7")
+	require.NoError(t, err)
+	rows, err = db.CheckUnfullfilledRows(ctx, rdb)
+	require.NoError(t, err)
+	require.Equal(t, []string{"3", "12"}, rows)
+
+	rows, err = other.CheckUnfullfilledRows(ctx, rdb)
+	require.NoError(t, err)
+	require.Equal(t, []string{"5"}, rows)
+}
+
+func TestMigrateBareRowKeys(t *testing.T) {
+	db, rdb := testRedis(t)
+	db = db.ForSheet("migrate-sheet-id", "BOT")
+	ctx := context.TODO()
+
+	require.NoError(t, rdb.Set(ctx, "901", `{"userId":1,"object":{"project":"Проект"}}`, 0).Err())
+	require.NoError(t, rdb.Set(ctx, "902", "not a row", 0).Err())
+
+	migrated, err := db.MigrateBareRowKeys(ctx, rdb)
+	require.NoError(t, err)
+	require.Equal(t, 1, migrated)
+
+	exists, err := rdb.Exists(ctx, "901").Result()
+	require.NoError(t, err)
+	require.Zero(t, exists)
+	row, err := db.GetRow(ctx, rdb, "901")
+	require.NoError(t, err)
+	require.Equal(t, "Проект", row.Object.Project)
+	rows, err := db.CheckUnfullfilledRows(ctx, rdb)
+	require.NoError(t, err)
+	require.Contains(t, rows, "901")
+
+	value, err := rdb.Get(ctx, "902").Result()
+	require.NoError(t, err)
+	require.Equal(t, "not a row", value)
+
+	// Строка уже есть под новым ключом: старый ключ не трогаем и в pending не добавляем
+	require.NoError(t, rdb.Set(ctx, db.rowKey("903"), `{"userId":1,"object":{"project":"Новый"}}`, 0).Err())
+	require.NoError(t, rdb.Set(ctx, "903", `{"userId":1,"object":{"project":"Старый"}}`, 0).Err())
+	migrated, err = db.MigrateBareRowKeys(ctx, rdb)
+	require.NoError(t, err)
+	require.Zero(t, migrated)
+	exists, err = rdb.Exists(ctx, "903").Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), exists)
+	rows, err = db.CheckUnfullfilledRows(ctx, rdb)
+	require.NoError(t, err)
+	require.NotContains(t, rows, "903")
+}
@@ -0,0 +1,383 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+const (
+	// JobGroup группа потребителей очереди заданий, общая для всех экземпляров бота
+	JobGroup = "workers"
+
+	// Сколько хранить счётчики пачки, если отчёт по ней так и не был отправлен
+	jobBatchTTL = 7 * 24 * time.Hour
+	// Сколько отложенных заданий переносить в очередь за один раз
+	promoteLimit = 100
+)
+
+// Переносим наступившие повторы из отложенных заданий в очередь одной атомарной операцией,
+// чтобы два экземпляра бота не запустили один повтор дважды
+var promoteJobsScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, tonumber(ARGV[2]))
+for _, job in ipairs(due) do
+	redis.call("ZREM", KEYS[1], job)
+	redis.call("XADD", KEYS[2], "*", "job", job)
+end
+return #due`)
+
+// QueuedJob задание, прочитанное из очереди, вместе с его ID в потоке
+type QueuedJob struct {
+	ID  string
+	Job models.TaskJob
+	// Сколько раз задание выдавалось обработчикам. Больше 1 — прежний обработчик упал или завис на нём
+	Deliveries int64
+}
+
+// jobsKey поток заданий на создание задач
+func (db *Db) jobsKey() string {
+	return db.rowPrefix() + ":jobs"
+}
+
+// delayedJobsKey отложенные повторы, score — время, когда задание можно брать снова
+func (db *Db) delayedJobsKey() string {
+	return db.rowPrefix() + ":jobs:delayed"
+}
+
+// deadJobsKey поток заданий, которые не удалось выполнить
+func (db *Db) deadJobsKey() string {
+	return db.rowPrefix() + ":jobs:dead"
+}
+
+// failedJobsKey последнее неудачное задание по каждой строке: номер строки → models.DeadJob
+func (db *Db) failedJobsKey() string {
+	return db.rowPrefix() + ":jobs:failed"
+}
+
+func (db *Db) batchKey(batch string) string {
+	return db.rowPrefix() + ":batch:" + batch
+}
+
+func (db *Db) batchLinesKey(batch string) string {
+	return db.batchKey(batch) + ":lines"
+}
+
+// EnsureJobGroup создаёт поток и группу потребителей, если их ещё нет
+func (db *Db) EnsureJobGroup(ctx context.Context, rdb *redis.Client) error {
+	err := rdb.XGroupCreateMkStream(ctx, db.jobsKey(), JobGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		slog.Error("Ошибка создания группы потребителей очереди", "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+// EnqueueJobs ставит задания в очередь одной пачкой. Когда все задания пачки будут обработаны,
+// FinishJob вернёт итог для отправки в chatID
+func (db *Db) EnqueueJobs(ctx context.Context, rdb *redis.Client, chatID int64, jobs []models.TaskJob) (string, error) {
+	if len(jobs) == 0 {
+		return "", models.ErrorIncorrectData
+	}
+	batch := strconv.FormatInt(time.Now().UnixNano(), 36)
+	pipe := rdb.TxPipeline()
+	pipe.HSet(ctx, db.batchKey(batch), "chat_id", chatID, "total", len(jobs), "done", 0, "created", 0)
+	pipe.Expire(ctx, db.batchKey(batch), jobBatchTTL)
+	for _, job := range jobs {
+		job.Batch = batch
+		job.ChatID = chatID
+		data, err := json.Marshal(job)
+		if err != nil {
+			return "", models.ErrorIncorrectData
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: db.jobsKey(), Values: map[string]interface{}{"job": data}})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка постановки заданий в очередь", "COUNT", len(jobs), "ERROR", err)
+		return "", models.ErrorDatabase
+	}
+	return batch, nil
+}
+
+// ReadJobs читает новые задания для потребителя consumer, ожидая их не дольше block.
+// Задания, которые не удаётся разобрать, сразу уходят в поток ошибок
+func (db *Db) ReadJobs(ctx context.Context, rdb *redis.Client, consumer string, count int, block time.Duration) ([]QueuedJob, error) {
+	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    JobGroup,
+		Consumer: consumer,
+		Streams:  []string{db.jobsKey(), ">"},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Ошибка чтения очереди заданий", "ERROR", err)
+		}
+		return nil, models.ErrorDatabase
+	}
+	var messages []redis.XMessage
+	for _, stream := range streams {
+		messages = append(messages, stream.Messages...)
+	}
+	return db.decodeJobs(ctx, rdb, messages), nil
+}
+
+// ClaimStaleJobs забирает задания, которые другой потребитель прочитал, но не подтвердил за minIdle,
+// например потому что бот перезапустили посреди обработки. Deliveries берётся из XPENDING
+func (db *Db) ClaimStaleJobs(ctx context.Context, rdb *redis.Client, consumer string, minIdle time.Duration, count int) ([]QueuedJob, error) {
+	messages, _, err := rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   db.jobsKey(),
+		Group:    JobGroup,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    "0-0",
+		Count:    int64(count),
+	}).Result()
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Ошибка получения зависших заданий", "ERROR", err)
+		}
+		return nil, models.ErrorDatabase
+	}
+	jobs := db.decodeJobs(ctx, rdb, messages)
+	for i := range jobs {
+		jobs[i].Deliveries = 1
+		pending, err := rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: db.jobsKey(),
+			Group:  JobGroup,
+			Start:  jobs[i].ID,
+			End:    jobs[i].ID,
+			Count:  1,
+		}).Result()
+		if err != nil {
+			slog.Warn("Не удалось узнать число выдач задания", "ID", jobs[i].ID, "ERROR", err)
+			continue
+		}
+		if len(pending) == 1 {
+			jobs[i].Deliveries = pending[0].RetryCount
+		}
+	}
+	return jobs, nil
+}
+
+func (db *Db) decodeJobs(ctx context.Context, rdb *redis.Client, messages []redis.XMessage) []QueuedJob {
+	jobs := make([]QueuedJob, 0, len(messages))
+	for _, message := range messages {
+		data, _ := message.Values["job"].(string)
+		var job models.TaskJob
+		if err := json.Unmarshal([]byte(data), &job); err != nil || job.Row <= 0 {
+			slog.Error("Задание в очереди повреждено и перенесено в поток ошибок", "ID", message.ID, "DATA", data)
+			pipe := rdb.TxPipeline()
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: db.deadJobsKey(), Values: map[string]interface{}{
+				"job": data, "reason": "не удалось разобрать задание", "time": time.Now().Unix(),
+			}})
+			pipe.XAck(ctx, db.jobsKey(), JobGroup, message.ID)
+			pipe.XDel(ctx, db.jobsKey(), message.ID)
+			pipe.Exec(ctx)
+			continue
+		}
+		jobs = append(jobs, QueuedJob{ID: message.ID, Job: job})
+	}
+	return jobs
+}
+
+// AckJob подтверждает выполнение задания и удаляет его из очереди
+func (db *Db) AckJob(ctx context.Context, rdb *redis.Client, id string) error {
+	pipe := rdb.TxPipeline()
+	pipe.XAck(ctx, db.jobsKey(), JobGroup, id)
+	pipe.XDel(ctx, db.jobsKey(), id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка подтверждения задания", "ID", id, "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+// RetryJobLater убирает задание из очереди и откладывает его повтор на delay
+func (db *Db) RetryJobLater(ctx context.Context, rdb *redis.Client, id string, job models.TaskJob, delay time.Duration) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return models.ErrorIncorrectData
+	}
+	pipe := rdb.TxPipeline()
+	pipe.ZAdd(ctx, db.delayedJobsKey(), redis.Z{Score: float64(time.Now().Add(delay).Unix()), Member: data})
+	pipe.XAck(ctx, db.jobsKey(), JobGroup, id)
+	pipe.XDel(ctx, db.jobsKey(), id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка откладывания задания", "ID", id, "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+// PromoteDueJobs возвращает в очередь отложенные задания, время повтора которых наступило
+func (db *Db) PromoteDueJobs(ctx context.Context, rdb *redis.Client, now time.Time) (int, error) {
+	moved, err := promoteJobsScript.Run(ctx, rdb, []string{db.delayedJobsKey(), db.jobsKey()}, now.Unix(), promoteLimit).Int()
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Ошибка возврата отложенных заданий в очередь", "ERROR", err)
+		}
+		return 0, models.ErrorDatabase
+	}
+	return moved, nil
+}
+
+// QueuedRows номера строк из заданий, которые ещё не выполнены: ждут в очереди,
+// обрабатываются или отложены для повтора. Строка из нескольких заданий возвращается один раз
+func (db *Db) QueuedRows(ctx context.Context, rdb *redis.Client) ([]int, error) {
+	messages, err := rdb.XRange(ctx, db.jobsKey(), "-", "+").Result()
+	if err != nil {
+		slog.Error("Ошибка чтения очереди заданий", "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	delayed, err := rdb.ZRange(ctx, db.delayedJobsKey(), 0, -1).Result()
+	if err != nil {
+		slog.Error("Ошибка чтения отложенных заданий", "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	data := make([]string, 0, len(messages)+len(delayed))
+	for _, message := range messages {
+		value, _ := message.Values["job"].(string)
+		data = append(data, value)
+	}
+	data = append(data, delayed...)
+
+	seen := make(map[int]bool, len(data))
+	rows := make([]int, 0, len(data))
+	for _, value := range data {
+		var job models.TaskJob
+		if err := json.Unmarshal([]byte(value), &job); err != nil || job.Row <= 0 || seen[job.Row] {
+			continue
+		}
+		seen[job.Row] = true
+		rows = append(rows, job.Row)
+	}
+	slices.Sort(rows)
+	return rows, nil
+}
+
+// DeadLetterJob переносит задание, которое не удалось выполнить, в поток ошибок
+// и запоминает его как последнюю ошибку строки для /failed
+func (db *Db) DeadLetterJob(ctx context.Context, rdb *redis.Client, id string, job models.TaskJob, reason string) error {
+	now := time.Now()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return models.ErrorIncorrectData
+	}
+	dead, err := json.Marshal(models.DeadJob{Job: job, Reason: reason, Time: now.Truncate(time.Second)})
+	if err != nil {
+		return models.ErrorIncorrectData
+	}
+	pipe := rdb.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{Stream: db.deadJobsKey(), Values: map[string]interface{}{
+		"job": data, "reason": reason, "time": now.Unix(),
+	}})
+	pipe.HSet(ctx, db.failedJobsKey(), strconv.Itoa(job.Row), dead)
+	pipe.XAck(ctx, db.jobsKey(), JobGroup, id)
+	pipe.XDel(ctx, db.jobsKey(), id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка переноса задания в поток ошибок", "ID", id, "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+// FinishJob записывает результат задания в его пачку. Когда обработано последнее задание пачки,
+// возвращает итог для отправки в чат, иначе nil
+func (db *Db) FinishJob(ctx context.Context, rdb *redis.Client, job models.TaskJob, line string, created bool) (*models.JobBatch, error) {
+	if job.Batch == "" {
+		return nil, nil
+	}
+	key := db.batchKey(job.Batch)
+	pipe := rdb.TxPipeline()
+	pipe.RPush(ctx, db.batchLinesKey(job.Batch), line)
+	pipe.Expire(ctx, db.batchLinesKey(job.Batch), jobBatchTTL)
+	if created {
+		pipe.HIncrBy(ctx, key, "created", 1)
+	}
+	done := pipe.HIncrBy(ctx, key, "done", 1)
+	total := pipe.HGet(ctx, key, "total")
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка сохранения результата задания", "BATCH", job.Batch, "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	totalInt, _ := strconv.ParseInt(total.Val(), 10, 64)
+	if done.Val() != totalInt {
+		return nil, nil
+	}
+
+	pipe = rdb.TxPipeline()
+	fields := pipe.HGetAll(ctx, key)
+	lines := pipe.LRange(ctx, db.batchLinesKey(job.Batch), 0, -1)
+	pipe.Del(ctx, key, db.batchLinesKey(job.Batch))
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка получения итога пачки заданий", "BATCH", job.Batch, "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	batch := &models.JobBatch{
+		ID:    job.Batch,
+		Total: int(totalInt),
+		Lines: lines.Val(),
+	}
+	batch.ChatID, _ = strconv.ParseInt(fields.Val()["chat_id"], 10, 64)
+	batch.Created, _ = strconv.Atoi(fields.Val()["created"])
+	return batch, nil
+}
+
+// ListDeadJobs возвращает неудачные задания по строкам в порядке номеров строк
+func (db *Db) ListDeadJobs(ctx context.Context, rdb *redis.Client) ([]models.DeadJob, error) {
+	values, err := rdb.HGetAll(ctx, db.failedJobsKey()).Result()
+	if err != nil {
+		slog.Error("Ошибка получения неудачных заданий", "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	jobs := make([]models.DeadJob, 0, len(values))
+	for row, data := range values {
+		var dead models.DeadJob
+		if err := json.Unmarshal([]byte(data), &dead); err != nil {
+			slog.Error("Проблема размаршалливания JSON в структуру", "ROW", row, "ERROR", err)
+			continue
+		}
+		jobs = append(jobs, dead)
+	}
+	slices.SortFunc(jobs, func(a, b models.DeadJob) int {
+		return a.Job.Row - b.Job.Row
+	})
+	return jobs, nil
+}
+
+// GetDeadJob возвращает последнее неудачное задание строки или models.ErrorNotFound
+func (db *Db) GetDeadJob(ctx context.Context, rdb *redis.Client, row int) (*models.DeadJob, error) {
+	data, err := rdb.HGet(ctx, db.failedJobsKey(), strconv.Itoa(row)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, models.ErrorNotFound
+	}
+	if err != nil {
+		return nil, models.ErrorDatabase
+	}
+	var dead models.DeadJob
+	if err := json.Unmarshal([]byte(data), &dead); err != nil {
+		return nil, models.ErrorUnmarshallJSON
+	}
+	return &dead, nil
+}
+
+// RemoveDeadJob убирает строку из списка неудачных. Возвращает false, если её там не было
+func (db *Db) RemoveDeadJob(ctx context.Context, rdb *redis.Client, row int) (bool, error) {
+	removed, err := rdb.HDel(ctx, db.failedJobsKey(), strconv.Itoa(row)).Result()
+	if err != nil {
+		slog.Error("Ошибка удаления неудачного задания", "ROW", row, "ERROR", err)
+		return false, models.ErrorDatabase
+	}
+	return removed > 0, nil
+}
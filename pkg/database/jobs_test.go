@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobQueue(t *testing.T) {
+	ctx := context.TODO()
+	db, rdb := testRedis(t)
+	require.NoError(t, db.EnsureJobGroup(ctx, rdb))
+	// Повторное создание группы не ошибка
+	require.NoError(t, db.EnsureJobGroup(ctx, rdb))
+
+	batch, err := db.EnqueueJobs(ctx, rdb, 100, []models.TaskJob{{Row: 2, FolderId: 1}, {Row: 3, FolderId: 1}})
+	require.NoError(t, err)
+	require.NotEmpty(t, batch)
+
+	jobs, err := db.ReadJobs(ctx, rdb, "worker-1", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	require.Equal(t, 2, jobs[0].Job.Row)
+	require.Equal(t, int64(100), jobs[0].Job.ChatID)
+	require.Equal(t, batch, jobs[0].Job.Batch)
+
+	// Новых заданий нет
+	jobs2, err := db.ReadJobs(ctx, rdb, "worker-2", 10, time.Millisecond)
+	require.NoError(t, err)
+	require.Empty(t, jobs2)
+
+	result, err := db.FinishJob(ctx, rdb, jobs[0].Job, "строка 2 готова", true)
+	require.NoError(t, err)
+	require.Nil(t, result)
+	require.NoError(t, db.AckJob(ctx, rdb, jobs[0].ID))
+
+	result, err = db.FinishJob(ctx, rdb, jobs[1].Job, "строка 3 с ошибкой", false)
+	require.NoError(t, err)
+	require.Equal(t, &models.JobBatch{
+		ID:      batch,
+		ChatID:  100,
+		Total:   2,
+		Created: 1,
+		Lines:   []string{"строка 2 готова", "строка 3 с ошибкой"},
+	}, result)
+	require.NoError(t, db.DeadLetterJob(ctx, rdb, jobs[1].ID, jobs[1].Job, "таймаут"))
+
+	length, err := rdb.XLen(ctx, db.jobsKey()).Result()
+	require.NoError(t, err)
+	require.Zero(t, length)
+	length, err = rdb.XLen(ctx, db.deadJobsKey()).Result()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, length)
+
+	_, err = db.EnqueueJobs(ctx, rdb, 100, nil)
+	require.ErrorIs(t, err, models.ErrorIncorrectData)
+}
+
+func TestJobRetry(t *testing.T) {
+	ctx := context.TODO()
+	db, rdb := testRedis(t)
+	require.NoError(t, db.EnsureJobGroup(ctx, rdb))
+	_, err := db.EnqueueJobs(ctx, rdb, 1, []models.TaskJob{{Row: 5}})
+	require.NoError(t, err)
+
+	jobs, err := db.ReadJobs(ctx, rdb, "worker-1", 1, 0)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	job := jobs[0].Job
+	job.Attempts++
+	require.NoError(t, db.RetryJobLater(ctx, rdb, jobs[0].ID, job, time.Minute))
+
+	moved, err := db.PromoteDueJobs(ctx, rdb, time.Now())
+	require.NoError(t, err)
+	require.Zero(t, moved)
+	moved, err = db.PromoteDueJobs(ctx, rdb, time.Now().Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, moved)
+
+	jobs, err = db.ReadJobs(ctx, rdb, "worker-1", 1, 0)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, 1, jobs[0].Job.Attempts)
+}
+
+func TestQueuedRows(t *testing.T) {
+	ctx := context.TODO()
+	db, rdb := testRedis(t)
+	require.NoError(t, db.EnsureJobGroup(ctx, rdb))
+	rows, err := db.QueuedRows(ctx, rdb)
+	require.NoError(t, err)
+	require.Empty(t, rows)
+
+	_, err = db.EnqueueJobs(ctx, rdb, 1, []models.TaskJob{{Row: 7}, {Row: 5}, {Row: 9}})
+	require.NoError(t, err)
+	_, err = db.EnqueueJobs(ctx, rdb, 1, []models.TaskJob{{Row: 7, AllowDuplicate: true}})
+	require.NoError(t, err)
+	jobs, err := db.ReadJobs(ctx, rdb, "worker-1", 2, 0)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	// Строка 7 отложена для повтора, строка 5 выполнена, строка 9 и второе задание по 7 ждут в очереди
+	require.NoError(t, db.RetryJobLater(ctx, rdb, jobs[0].ID, jobs[0].Job, time.Minute))
+	require.NoError(t, db.AckJob(ctx, rdb, jobs[1].ID))
+
+	rows, err = db.QueuedRows(ctx, rdb)
+	require.NoError(t, err)
+	require.Equal(t, []int{7, 9}, rows)
+}
+
+func TestClaimStaleJobs(t *testing.T) {
+	ctx := context.TODO()
+	db, rdb := testRedis(t)
+	require.NoError(t, db.EnsureJobGroup(ctx, rdb))
+	_, err := db.EnqueueJobs(ctx, rdb, 1, []models.TaskJob{{Row: 8}})
+	require.NoError(t, err)
+	require.NoError(t, rdb.XAdd(ctx, &redis.XAddArgs{Stream: db.jobsKey(), Values: map[string]interface{}{"job": "{"}}).Err())
+
+	// Первый потребитель прочитал задание и «упал», не подтвердив его. Повреждённое задание ушло в поток ошибок
+	jobs, err := db.ReadJobs(ctx, rdb, "worker-1", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	length, err := rdb.XLen(ctx, db.deadJobsKey()).Result()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, length)
+
+	claimed, err := db.ClaimStaleJobs(ctx, rdb, "worker-2", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, 8, claimed[0].Job.Row)
+	require.EqualValues(t, 2, claimed[0].Deliveries)
+
+	// Второй потребитель тоже не подтвердил задание
+	claimed, err = db.ClaimStaleJobs(ctx, rdb, "worker-3", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.EqualValues(t, 3, claimed[0].Deliveries)
+}
+
+func TestDeadJobs(t *testing.T) {
+	ctx := context.TODO()
+	db, rdb := testRedis(t)
+	require.NoError(t, db.EnsureJobGroup(ctx, rdb))
+	_, err := db.EnqueueJobs(ctx, rdb, 1, []models.TaskJob{{Row: 12, FolderId: 3}, {Row: 4, FolderId: 3}})
+	require.NoError(t, err)
+	jobs, err := db.ReadJobs(ctx, rdb, "worker-1", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+
+	_, err = db.GetDeadJob(ctx, rdb, 12)
+	require.ErrorIs(t, err, models.ErrorNotFound)
+
+	for _, queued := range jobs {
+		queued.Job.Attempts = 5
+		require.NoError(t, db.DeadLetterJob(ctx, rdb, queued.ID, queued.Job, "UNU API returned error"))
+	}
+	dead, err := db.ListDeadJobs(ctx, rdb)
+	require.NoError(t, err)
+	require.Len(t, dead, 2)
+	require.Equal(t, 4, dead[0].Job.Row)
+	require.Equal(t, 12, dead[1].Job.Row)
+	require.Equal(t, 5, dead[1].Job.Attempts)
+	require.Equal(t, "UNU API returned error", dead[1].Reason)
+
+	got, err := db.GetDeadJob(ctx, rdb, 12)
+	require.NoError(t, err)
+	require.Equal(t, 3, got.Job.FolderId)
+
+	removed, err := db.RemoveDeadJob(ctx, rdb, 12)
+	require.NoError(t, err)
+	require.True(t, removed)
+	removed, err = db.RemoveDeadJob(ctx, rdb, 12)
+	require.NoError(t, err)
+	require.False(t, removed)
+	dead, err = db.ListDeadJobs(ctx, rdb)
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// RowLockTTL время жизни блокировки строки, если её не продлевать
+const RowLockTTL = 2 * time.Minute
+
+// Снимаем и продлеваем блокировку только если она всё ещё принадлежит нам
+var (
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// RowLockedError строка уже обрабатывается другим пользователем или экземпляром бота
+type RowLockedError struct {
+	Row   string
+	Owner string
+}
+
+func (e *RowLockedError) Error() string {
+	return fmt.Sprintf("%v: строка %s, владелец %s", models.ErrorRowLocked, e.Row, e.Owner)
+}
+
+func (e *RowLockedError) Unwrap() error {
+	return models.ErrorRowLocked
+}
+
+// RowLock блокировка строки таблицы. Значение ключа — токен владельца вида owner|random,
+// поэтому снять или продлить блокировку может только тот, кто её взял
+type RowLock struct {
+	rdb   *redis.Client
+	key   string
+	token string
+	ttl   time.Duration
+}
+
+func (db *Db) lockKey(rowNumber string) string {
+	return db.rowPrefix() + ":lock:" + rowNumber
+}
+
+// LockRow берёт блокировку строки на время ttl. Если строку уже обрабатывает кто-то другой,
+// возвращает *RowLockedError с именем владельца
+func (db *Db) LockRow(ctx context.Context, rdb *redis.Client, rowNumber, owner string, ttl time.Duration) (*RowLock, error) {
+	if validateRowNumber(rowNumber) != nil {
+		return nil, models.ErrorIncorrectData
+	}
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	lock := &RowLock{
+		rdb:   rdb,
+		key:   db.lockKey(rowNumber),
+		token: owner + "|" + hex.EncodeToString(random),
+		ttl:   ttl,
+	}
+	ok, err := rdb.SetNX(ctx, lock.key, lock.token, ttl).Result()
+	if err != nil {
+		slog.Error("Ошибка блокировки строки", "ROW", rowNumber, "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	if ok {
+		return lock, nil
+	}
+	holder, err := rdb.Get(ctx, lock.key).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, models.ErrorDatabase
+	}
+	return nil, &RowLockedError{Row: rowNumber, Owner: lockOwner(holder)}
+}
+
+func lockOwner(token string) string {
+	owner, _, _ := strings.Cut(token, "|")
+	if owner == "" {
+		return "неизвестно"
+	}
+	return owner
+}
+
+// Extend продлевает блокировку ещё на ttl. Если блокировка истекла и её взял кто-то другой, возвращает models.ErrorLockLost
+func (l *RowLock) Extend(ctx context.Context) error {
+	res, err := extendLockScript.Run(ctx, l.rdb, []string{l.key}, l.token, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return models.ErrorDatabase
+	}
+	if res == 0 {
+		return models.ErrorLockLost
+	}
+	return nil
+}
+
+// Release снимает блокировку, если она всё ещё наша
+func (l *RowLock) Release(ctx context.Context) error {
+	err := releaseLockScript.Run(ctx, l.rdb, []string{l.key}, l.token).Err()
+	if err != nil {
+		slog.Error("Ошибка снятия блокировки строки", "KEY", l.key, "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+// KeepAlive продлевает блокировку каждую треть ttl, пока идёт долгий запрос к API.
+// Возвращённый контекст отменяется с причиной models.ErrorLockLost, если блокировку перехватили:
+// работу со строкой под ним нужно прекратить. Функция stop останавливает продление
+func (l *RowLock) KeepAlive(ctx context.Context) (lockCtx context.Context, stop func()) {
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-lockCtx.Done():
+				return
+			case <-ticker.C:
+				err := l.Extend(lockCtx)
+				if errors.Is(err, models.ErrorLockLost) {
+					slog.Warn("Блокировка строки потеряна, обработка строки прервана", "KEY", l.key)
+					cancel(models.ErrorLockLost)
+					return
+				}
+				// Redis временно недоступен: пробуем снова, пока блокировка не истекла
+				if err != nil && lockCtx.Err() == nil {
+					slog.Warn("Не удалось продлить блокировку строки", "KEY", l.key, "ERROR", err)
+				}
+			}
+		}
+	}()
+	return lockCtx, func() {
+		cancel(nil)
+		wg.Wait()
+	}
+}
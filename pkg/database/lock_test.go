@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockRow(t *testing.T) {
+	ctx := context.TODO()
+	db, rdb := testRedis(t)
+
+	lock, err := db.LockRow(ctx, rdb, "7", "@first", time.Minute)
+	require.NoError(t, err)
+
+	_, err = db.LockRow(ctx, rdb, "7", "@second", time.Minute)
+	require.ErrorIs(t, err, models.ErrorRowLocked)
+	var locked *RowLockedError
+	require.ErrorAs(t, err, &locked)
+	require.Equal(t, "@first", locked.Owner)
+
+	// Другие строки не заблокированы
+	other, err := db.LockRow(ctx, rdb, "8", "@second", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, other.Release(ctx))
+
+	require.NoError(t, lock.Extend(ctx))
+	require.NoError(t, lock.Release(ctx))
+	require.ErrorIs(t, lock.Extend(ctx), models.ErrorLockLost)
+
+	second, err := db.LockRow(ctx, rdb, "7", "@second", time.Minute)
+	require.NoError(t, err)
+	// Старый владелец не может снять чужую блокировку
+	require.NoError(t, lock.Release(ctx))
+	_, err = db.LockRow(ctx, rdb, "7", "@first", time.Minute)
+	require.ErrorIs(t, err, models.ErrorRowLocked)
+	require.NoError(t, second.Release(ctx))
+
+	_, err = db.LockRow(ctx, rdb, "abc", "@first", time.Minute)
+	require.ErrorIs(t, err, models.ErrorIncorrectData)
+}
+
+func TestLockRowExpires(t *testing.T) {
+	ctx := context.TODO()
+	server := miniredis.RunT(t)
+	db := NewDB(server.Addr(), "", 0)
+	rdb := db.Connect()
+	defer rdb.Close()
+
+	lock, err := db.LockRow(ctx, rdb, "3", "@first", time.Second)
+	require.NoError(t, err)
+	server.FastForward(2 * time.Second)
+	_, err = db.LockRow(ctx, rdb, "3", "@second", time.Second)
+	require.NoError(t, err)
+	require.ErrorIs(t, lock.Extend(ctx), models.ErrorLockLost)
+}
+
+func TestKeepAliveLockLost(t *testing.T) {
+	ctx := context.TODO()
+	server := miniredis.RunT(t)
+	db := NewDB(server.Addr(), "", 0)
+	rdb := db.Connect()
+	defer rdb.Close()
+
+	lock, err := db.LockRow(ctx, rdb, "5", "@first", 300*time.Millisecond)
+	require.NoError(t, err)
+	lockCtx, stop := lock.KeepAlive(ctx)
+	defer stop()
+
+	// Продление держит блокировку дольше ttl
+	time.Sleep(400 * time.Millisecond)
+	require.NoError(t, lockCtx.Err())
+
+	// Блокировку перехватили: контекст отменяется с причиной ErrorLockLost
+	server.Set(db.lockKey("5"), "@second|token")
+	select {
+	case <-lockCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("контекст не отменён после потери блокировки")
+	}
+	require.ErrorIs(t, context.Cause(lockCtx), models.ErrorLockLost)
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+func notifyKey(kind, id string) string {
+	return fmt.Sprintf("unu:notify:%s:%s", kind, id)
+}
+
+// MarkNotified отмечает, что уведомление kind об объекте id уже отправлено.
+// Возвращает true, если отметки ещё не было и уведомление нужно отправить
+func (db *Db) MarkNotified(ctx context.Context, rdb *redis.Client, kind, id string, ttl time.Duration) (bool, error) {
+	res, err := rdb.SetNX(ctx, notifyKey(kind, id), time.Now().Unix(), ttl).Result()
+	if err != nil {
+		slog.Error("Ошибка сохранения отметки об уведомлении", "KIND", kind, "ID", id, "ERROR", err)
+		return false, models.ErrorDatabase
+	}
+	return res, nil
+}
+
+// ClearNotified снимает отметку, чтобы уведомление могло прийти снова, когда ситуация повторится
+func (db *Db) ClearNotified(ctx context.Context, rdb *redis.Client, kind, id string) error {
+	err := rdb.Del(ctx, notifyKey(kind, id)).Err()
+	if err != nil {
+		return models.ErrorDatabase
+	}
+	return nil
+}
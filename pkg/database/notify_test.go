@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkNotified(t *testing.T) {
+	db, rdb := testRedis(t)
+	ctx := context.TODO()
+	require.NoError(t, db.ClearNotified(ctx, rdb, "test", "1"))
+
+	first, err := db.MarkNotified(ctx, rdb, "test", "1", time.Minute)
+	require.NoError(t, err)
+	require.True(t, first)
+
+	second, err := db.MarkNotified(ctx, rdb, "test", "1", time.Minute)
+	require.NoError(t, err)
+	require.False(t, second)
+
+	require.NoError(t, db.ClearNotified(ctx, rdb, "test", "1"))
+	again, err := db.MarkNotified(ctx, rdb, "test", "1", time.Minute)
+	require.NoError(t, err)
+	require.True(t, again)
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// Сколько последних переходов хранить в истории строки
+const rowHistoryLimit = 50
+
+// stateKey хэш с текущим состоянием строки: status, task_id, reason, attempts, updated_at
+func (db *Db) stateKey(rowNumber string) string {
+	return db.rowPrefix() + ":state:" + rowNumber
+}
+
+// historyKey список переходов строки в формате JSON
+func (db *Db) historyKey(rowNumber string) string {
+	return db.rowPrefix() + ":history:" + rowNumber
+}
+
+// statusIndexKey множество номеров строк, которые сейчас находятся на этапе status
+func (db *Db) statusIndexKey(status string) string {
+	return db.rowPrefix() + ":by-status:" + status
+}
+
+// setRowStatusScript меняет этап строки. KEYS: состояние, история, индекс нового этапа.
+// ARGV: этап, причина, время, ID задачи, этап failed, префикс индексов, номер строки, запись истории, размер истории
+var setRowStatusScript = redis.NewScript(`
+local previous = redis.call("HGET", KEYS[1], "status")
+redis.call("HSET", KEYS[1], "status", ARGV[1], "reason", ARGV[2], "updated_at", ARGV[3])
+if ARGV[4] ~= "0" then
+	redis.call("HSET", KEYS[1], "task_id", ARGV[4])
+end
+if ARGV[1] == ARGV[5] then
+	redis.call("HINCRBY", KEYS[1], "attempts", 1)
+end
+if previous and previous ~= ARGV[1] then
+	redis.call("SREM", ARGV[6] .. previous, ARGV[7])
+end
+redis.call("SADD", KEYS[3], ARGV[7])
+redis.call("RPUSH", KEYS[2], ARGV[8])
+redis.call("LTRIM", KEYS[2], -tonumber(ARGV[9]), -1)
+return 1`)
+
+// SetRowStatus переводит строку на этап event.Status и добавляет переход в историю.
+// Для этапа failed счётчик попыток увеличивается, для created сохраняется ID задачи
+func (db *Db) SetRowStatus(ctx context.Context, rdb *redis.Client, rowNumber string, event models.RowEvent) error {
+	if err := validateRowEvent(rowNumber, event); err != nil {
+		return err
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	history, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// Прежний этап читается и меняется в одном скрипте, иначе при одновременной смене этапа
+	// строка могла остаться в индексе двух этапов
+	err = setRowStatusScript.Run(ctx, rdb,
+		[]string{db.stateKey(rowNumber), db.historyKey(rowNumber), db.statusIndexKey(event.Status)},
+		event.Status, event.Reason, event.Time.Unix(), event.TaskId, models.RowFailed,
+		db.statusIndexKey(""), rowNumber, string(history), rowHistoryLimit).Err()
+	if err != nil {
+		slog.Error("Ошибка сохранения этапа строки", "ROW", rowNumber, "STATUS", event.Status, "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+// GetRowStatus возвращает текущее состояние строки с историей.
+// Если строка ещё не обрабатывалась, возвращается models.ErrorNotFound
+func (db *Db) GetRowStatus(ctx context.Context, rdb *redis.Client, rowNumber string) (*models.RowState, error) {
+	if validateRowNumber(rowNumber) != nil {
+		return nil, models.ErrorIncorrectData
+	}
+	fields, err := rdb.HGetAll(ctx, db.stateKey(rowNumber)).Result()
+	if err != nil {
+		slog.Error("Ошибка получения этапа строки", "ROW", rowNumber, "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	if len(fields) == 0 {
+		return nil, models.ErrorNotFound
+	}
+	state := &models.RowState{
+		Row:    rowNumber,
+		Status: fields["status"],
+		Reason: fields["reason"],
+	}
+	state.TaskId, _ = strconv.Atoi(fields["task_id"])
+	state.Attempts, _ = strconv.Atoi(fields["attempts"])
+	if updated, err := strconv.ParseInt(fields["updated_at"], 10, 64); err == nil {
+		state.UpdatedAt = time.Unix(updated, 0)
+	}
+
+	history, err := rdb.LRange(ctx, db.historyKey(rowNumber), 0, -1).Result()
+	if err != nil {
+		return nil, models.ErrorDatabase
+	}
+	for _, value := range history {
+		var event models.RowEvent
+		if err := json.Unmarshal([]byte(value), &event); err != nil {
+			slog.Warn("Некорректная запись в истории строки", "ROW", rowNumber, "VALUE", value)
+			continue
+		}
+		state.History = append(state.History, event)
+	}
+	return state, nil
+}
+
+// ListRowsByStatus возвращает номера строк на этапе status по возрастанию
+func (db *Db) ListRowsByStatus(ctx context.Context, rdb *redis.Client, status string) ([]string, error) {
+	if err := validateStatus(status); err != nil {
+		return nil, err
+	}
+	members, err := rdb.SMembers(ctx, db.statusIndexKey(status)).Result()
+	if err != nil {
+		return nil, models.ErrorDatabase
+	}
+	return sortRowNumbers(members), nil
+}
+
+// sortRowNumbers сортирует номера строк как числа, некорректные номера отбрасывает
+func sortRowNumbers(members []string) []string {
+	rows := make([]int, 0, len(members))
+	for _, member := range members {
+		row, err := strconv.Atoi(member)
+		if err != nil {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	sort.Ints(rows)
+	sorted := make([]string, len(rows))
+	for i, row := range rows {
+		sorted[i] = strconv.Itoa(row)
+	}
+	return sorted
+}
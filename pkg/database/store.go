@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// Store хранилище строк таблицы: строки, ожидающие создания задачи, этапы их обработки
+// и строки уже созданных задач. Все реализации ведут себя одинаково: некорректный номер строки —
+// models.ErrorIncorrectData, отсутствующая запись — models.ErrorNotFound
+type Store interface {
+	AddRow(ctx context.Context, rowNumber string, rowObject *models.RowObject) error
+	GetRow(ctx context.Context, rowNumber string) (*models.RowObject, error)
+	// GetRows загружает сразу несколько строк. Строк, которых нет в хранилище, не будет в результате
+	GetRows(ctx context.Context, rowNumbers []string) (map[string]*models.RowObject, error)
+	DelRow(ctx context.Context, rowNumber string) (int64, error)
+	// ListPending возвращает номера сохранённых строк, задачи по которым ещё не созданы, по возрастанию
+	ListPending(ctx context.Context) ([]string, error)
+
+	SetRowStatus(ctx context.Context, rowNumber string, event models.RowEvent) error
+	GetRowStatus(ctx context.Context, rowNumber string) (*models.RowState, error)
+	ListRowsByStatus(ctx context.Context, status string) ([]string, error)
+
+	SaveTaskRow(ctx context.Context, taskId int, rowObject *models.RowObject) error
+	GetTaskRow(ctx context.Context, taskId int) (*models.RowObject, error)
+	// GetTaskProjects проекты задач для отчёта о расходах. SaveTaskRow запоминает проект задачи без срока хранения,
+	// поэтому он находится и после того, как строка задачи истекла. Задач без проекта не будет в результате
+	GetTaskProjects(ctx context.Context, taskIds []int) (map[int]string, error)
+
+	Close() error
+}
+
+// Варианты хранилища строк
+const (
+	BackendRedis  = "redis"
+	BackendSQLite = "sqlite"
+	BackendMemory = "memory"
+)
+
+// NewStore открывает хранилище строк. Для redis используется подключение rdb,
+// для sqlite — файл sqlitePath (остальные данные бота и в этом случае лежат в Redis). Строки хранятся отдельно для листа db.Sheet таблицы db.SpreadsheetId
+func NewStore(backend string, db *Db, rdb *redis.Client, sqlitePath string) (Store, error) {
+	switch backend {
+	case BackendRedis, "":
+		return NewRedisStore(db, rdb), nil
+	case BackendSQLite:
+		return NewSQLiteStore(sqlitePath, db.SpreadsheetId, db.Sheet)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("%w: неизвестное хранилище %s", models.ErrorIncorrectData, backend)
+}
+
+// RedisStore хранилище строк в Redis
+type RedisStore struct {
+	db  *Db
+	rdb *redis.Client
+}
+
+func NewRedisStore(db *Db, rdb *redis.Client) *RedisStore {
+	return &RedisStore{
+		db:  db,
+		rdb: rdb,
+	}
+}
+
+func (s *RedisStore) AddRow(ctx context.Context, rowNumber string, rowObject *models.RowObject) error {
+	return s.db.AddRow(ctx, s.rdb, rowNumber, rowObject)
+}
+
+func (s *RedisStore) GetRow(ctx context.Context, rowNumber string) (*models.RowObject, error) {
+	return s.db.GetRow(ctx, s.rdb, rowNumber)
+}
+
+func (s *RedisStore) GetRows(ctx context.Context, rowNumbers []string) (map[string]*models.RowObject, error) {
+	return s.db.GetRows(ctx, s.rdb, rowNumbers)
+}
+
+func (s *RedisStore) DelRow(ctx context.Context, rowNumber string) (int64, error) {
+	return s.db.DelRow(ctx, s.rdb, rowNumber)
+}
+
+func (s *RedisStore) ListPending(ctx context.Context) ([]string, error) {
+	return s.db.CheckUnfullfilledRows(ctx, s.rdb)
+}
+
+func (s *RedisStore) SetRowStatus(ctx context.Context, rowNumber string, event models.RowEvent) error {
+	return s.db.SetRowStatus(ctx, s.rdb, rowNumber, event)
+}
+
+func (s *RedisStore) GetRowStatus(ctx context.Context, rowNumber string) (*models.RowState, error) {
+	return s.db.GetRowStatus(ctx, s.rdb, rowNumber)
+}
+
+func (s *RedisStore) ListRowsByStatus(ctx context.Context, status string) ([]string, error) {
+	return s.db.ListRowsByStatus(ctx, s.rdb, status)
+}
+
+func (s *RedisStore) SaveTaskRow(ctx context.Context, taskId int, rowObject *models.RowObject) error {
+	return s.db.SaveTaskRow(ctx, s.rdb, taskId, rowObject)
+}
+
+func (s *RedisStore) GetTaskRow(ctx context.Context, taskId int) (*models.RowObject, error) {
+	return s.db.GetTaskRow(ctx, s.rdb, taskId)
+}
+
+func (s *RedisStore) GetTaskProjects(ctx context.Context, taskIds []int) (map[int]string, error) {
+	return s.db.GetTaskProjects(ctx, s.rdb, taskIds)
+}
+
+// Close ничего не делает: подключением к Redis владеет тот, кто его создал
+func (s *RedisStore) Close() error {
+	return nil
+}
+
+// validateRowEvent общая для всех хранилищ проверка перехода строки на новый этап
+func validateRowEvent(rowNumber string, event models.RowEvent) error {
+	if validateRowNumber(rowNumber) != nil || !slices.Contains(models.RowStatuses, event.Status) {
+		return models.ErrorIncorrectData
+	}
+	return nil
+}
+
+func validateStatus(status string) error {
+	if !slices.Contains(models.RowStatuses, status) {
+		return fmt.Errorf("%w: неизвестный этап %s", models.ErrorIncorrectData, status)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// MemoryStore хранилище строк в памяти процесса. Данные теряются при перезапуске, подходит для тестов
+type MemoryStore struct {
+	mu       sync.Mutex
+	rows     map[string]models.RowObject
+	states   map[string]*models.RowState
+	taskRows map[int]memoryTaskRow
+	projects map[int]string
+}
+
+type memoryTaskRow struct {
+	row       models.RowObject
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		rows:     make(map[string]models.RowObject),
+		states:   make(map[string]*models.RowState),
+		taskRows: make(map[int]memoryTaskRow),
+		projects: make(map[int]string),
+	}
+}
+
+func (s *MemoryStore) AddRow(ctx context.Context, rowNumber string, rowObject *models.RowObject) error {
+	err := validateRowObject(rowNumber, rowObject)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows[rowNumber] = *rowObject
+	return nil
+}
+
+func (s *MemoryStore) GetRow(ctx context.Context, rowNumber string) (*models.RowObject, error) {
+	if validateRowNumber(rowNumber) != nil {
+		return nil, models.ErrorIncorrectData
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row, ok := s.rows[rowNumber]
+	if !ok {
+		return nil, models.ErrorNotFound
+	}
+	return &row, nil
+}
+
+func (s *MemoryStore) GetRows(ctx context.Context, rowNumbers []string) (map[string]*models.RowObject, error) {
+	for _, rowNumber := range rowNumbers {
+		if validateRowNumber(rowNumber) != nil {
+			return nil, models.ErrorIncorrectData
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows := make(map[string]*models.RowObject, len(rowNumbers))
+	for _, rowNumber := range rowNumbers {
+		if row, ok := s.rows[rowNumber]; ok {
+			rows[rowNumber] = &row
+		}
+	}
+	return rows, nil
+}
+
+func (s *MemoryStore) DelRow(ctx context.Context, rowNumber string) (int64, error) {
+	if validateRowNumber(rowNumber) != nil {
+		return 0, models.ErrorIncorrectData
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rows[rowNumber]; !ok {
+		return 0, nil
+	}
+	delete(s.rows, rowNumber)
+	return 1, nil
+}
+
+func (s *MemoryStore) ListPending(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows := make([]string, 0, len(s.rows))
+	for rowNumber := range s.rows {
+		rows = append(rows, rowNumber)
+	}
+	return sortRowNumbers(rows), nil
+}
+
+func (s *MemoryStore) SetRowStatus(ctx context.Context, rowNumber string, event models.RowEvent) error {
+	if err := validateRowEvent(rowNumber, event); err != nil {
+		return err
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[rowNumber]
+	if !ok {
+		state = &models.RowState{Row: rowNumber}
+		s.states[rowNumber] = state
+	}
+	state.Status = event.Status
+	state.Reason = event.Reason
+	state.UpdatedAt = event.Time.Truncate(time.Second)
+	if event.TaskId != 0 {
+		state.TaskId = event.TaskId
+	}
+	if event.Status == models.RowFailed {
+		state.Attempts++
+	}
+	state.History = append(state.History, event)
+	if len(state.History) > rowHistoryLimit {
+		state.History = state.History[len(state.History)-rowHistoryLimit:]
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetRowStatus(ctx context.Context, rowNumber string) (*models.RowState, error) {
+	if validateRowNumber(rowNumber) != nil {
+		return nil, models.ErrorIncorrectData
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[rowNumber]
+	if !ok {
+		return nil, models.ErrorNotFound
+	}
+	copyState := *state
+	copyState.History = append([]models.RowEvent(nil), state.History...)
+	return &copyState, nil
+}
+
+func (s *MemoryStore) ListRowsByStatus(ctx context.Context, status string) ([]string, error) {
+	if err := validateStatus(status); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows := []string{}
+	for _, state := range s.states {
+		if state.Status == status {
+			rows = append(rows, state.Row)
+		}
+	}
+	return sortRowNumbers(rows), nil
+}
+
+func (s *MemoryStore) SaveTaskRow(ctx context.Context, taskId int, rowObject *models.RowObject) error {
+	if taskId <= 0 || rowObject == nil {
+		return models.ErrorIncorrectData
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.taskRows[taskId] = memoryTaskRow{row: *rowObject, expiresAt: time.Now().Add(taskRowTTL)}
+	s.projects[taskId] = rowObject.Object.Project
+	return nil
+}
+
+func (s *MemoryStore) GetTaskProjects(ctx context.Context, taskIds []int) (map[int]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	projects := make(map[int]string, len(taskIds))
+	for _, taskId := range taskIds {
+		if project := s.projects[taskId]; project != "" {
+			projects[taskId] = project
+		}
+	}
+	return projects, nil
+}
+
+func (s *MemoryStore) GetTaskRow(ctx context.Context, taskId int) (*models.RowObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	taskRow, ok := s.taskRows[taskId]
+	if !ok || time.Now().After(taskRow.expiresAt) {
+		return nil, models.ErrorNotFound
+	}
+	return &taskRow.row, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,399 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS rows (
+	spreadsheet_id TEXT NOT NULL,
+	sheet          TEXT NOT NULL,
+	row            INTEGER NOT NULL,
+	data           TEXT NOT NULL,
+	PRIMARY KEY (spreadsheet_id, sheet, row)
+);
+CREATE TABLE IF NOT EXISTS row_states (
+	spreadsheet_id TEXT NOT NULL,
+	sheet          TEXT NOT NULL,
+	row            INTEGER NOT NULL,
+	status         TEXT NOT NULL,
+	task_id        INTEGER NOT NULL DEFAULT 0,
+	reason         TEXT NOT NULL DEFAULT '',
+	attempts       INTEGER NOT NULL DEFAULT 0,
+	updated_at     INTEGER NOT NULL,
+	PRIMARY KEY (spreadsheet_id, sheet, row)
+);
+CREATE INDEX IF NOT EXISTS row_states_status ON row_states (spreadsheet_id, sheet, status);
+CREATE TABLE IF NOT EXISTS row_history (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	spreadsheet_id TEXT NOT NULL,
+	sheet          TEXT NOT NULL,
+	row            INTEGER NOT NULL,
+	event          TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS row_history_row ON row_history (spreadsheet_id, sheet, row);
+CREATE TABLE IF NOT EXISTS task_rows (
+	task_id    INTEGER PRIMARY KEY,
+	data       TEXT NOT NULL,
+	expires_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS task_projects (
+	task_id INTEGER PRIMARY KEY,
+	project TEXT NOT NULL
+);
+`
+
+// SQLiteStore хранилище строк, их истории и строк задач в файле SQLite. Redis при этом всё равно нужен:
+// в нём остаются роли, блокировки строк, очередь заданий, история текстов и кэш тарифов
+type SQLiteStore struct {
+	sqlDb         *sql.DB
+	spreadsheetId string
+	sheet         string
+}
+
+// NewSQLiteStore открывает (или создаёт) файл path и готовит таблицы
+func NewSQLiteStore(path, spreadsheetId, sheet string) (*SQLiteStore, error) {
+	sqlDb, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		slog.Error("Не удалось открыть файл SQLite", "PATH", path, "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	// SQLite не любит параллельную запись, одного соединения для бота достаточно
+	sqlDb.SetMaxOpenConns(1)
+	_, err = sqlDb.Exec(sqliteSchema)
+	if err != nil {
+		slog.Error("Не удалось создать таблицы SQLite", "PATH", path, "ERROR", err)
+		sqlDb.Close()
+		return nil, models.ErrorDatabase
+	}
+	return &SQLiteStore{
+		sqlDb:         sqlDb,
+		spreadsheetId: spreadsheetId,
+		sheet:         sheet,
+	}, nil
+}
+
+func (s *SQLiteStore) AddRow(ctx context.Context, rowNumber string, rowObject *models.RowObject) error {
+	err := validateRowObject(rowNumber, rowObject)
+	if err != nil {
+		return err
+	}
+	row, err := strconv.Atoi(rowNumber)
+	if err != nil {
+		return models.ErrorIncorrectData
+	}
+	data, err := json.Marshal(rowObject)
+	if err != nil {
+		return err
+	}
+	_, err = s.sqlDb.ExecContext(ctx,
+		`INSERT INTO rows (spreadsheet_id, sheet, row, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (spreadsheet_id, sheet, row) DO UPDATE SET data = excluded.data`,
+		s.spreadsheetId, s.sheet, row, string(data))
+	if err != nil {
+		slog.Error("Ошибка сохранения строки в SQLite", "ROW", rowNumber, "ERROR", err)
+		return models.ErrorGetValueFromDatabase
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetRow(ctx context.Context, rowNumber string) (*models.RowObject, error) {
+	if validateRowNumber(rowNumber) != nil {
+		return nil, models.ErrorIncorrectData
+	}
+	var data string
+	err := s.sqlDb.QueryRowContext(ctx,
+		`SELECT data FROM rows WHERE spreadsheet_id = ? AND sheet = ? AND row = ?`,
+		s.spreadsheetId, s.sheet, rowNumber).Scan(&data)
+	return decodeRow(data, err)
+}
+
+func (s *SQLiteStore) GetRows(ctx context.Context, rowNumbers []string) (map[string]*models.RowObject, error) {
+	rows := make(map[string]*models.RowObject, len(rowNumbers))
+	if len(rowNumbers) == 0 {
+		return rows, nil
+	}
+	args := []interface{}{s.spreadsheetId, s.sheet}
+	for _, rowNumber := range rowNumbers {
+		if validateRowNumber(rowNumber) != nil {
+			return nil, models.ErrorIncorrectData
+		}
+		args = append(args, rowNumber)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(rowNumbers)), ", ")
+	result, err := s.sqlDb.QueryContext(ctx,
+		`SELECT row, data FROM rows WHERE spreadsheet_id = ? AND sheet = ? AND row IN (`+placeholders+`)`, args...)
+	if err != nil {
+		slog.Error("Ошибка получения строк из SQLite", "COUNT", len(rowNumbers), "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	defer result.Close()
+	for result.Next() {
+		var row int
+		var data string
+		if err := result.Scan(&row, &data); err != nil {
+			return nil, models.ErrorDatabase
+		}
+		rowObject, err := decodeRow(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		rows[strconv.Itoa(row)] = rowObject
+	}
+	if err := result.Err(); err != nil {
+		return nil, models.ErrorDatabase
+	}
+	return rows, nil
+}
+
+func (s *SQLiteStore) DelRow(ctx context.Context, rowNumber string) (int64, error) {
+	if validateRowNumber(rowNumber) != nil {
+		return 0, models.ErrorIncorrectData
+	}
+	res, err := s.sqlDb.ExecContext(ctx,
+		`DELETE FROM rows WHERE spreadsheet_id = ? AND sheet = ? AND row = ?`,
+		s.spreadsheetId, s.sheet, rowNumber)
+	if err != nil {
+		return 0, models.ErrorDatabase
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, models.ErrorDatabase
+	}
+	return deleted, nil
+}
+
+func (s *SQLiteStore) ListPending(ctx context.Context) ([]string, error) {
+	return s.queryRows(ctx,
+		`SELECT row FROM rows WHERE spreadsheet_id = ? AND sheet = ? ORDER BY row`,
+		s.spreadsheetId, s.sheet)
+}
+
+func (s *SQLiteStore) SetRowStatus(ctx context.Context, rowNumber string, event models.RowEvent) error {
+	if err := validateRowEvent(rowNumber, event); err != nil {
+		return err
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	history, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	if event.Status == models.RowFailed {
+		failed = 1
+	}
+
+	tx, err := s.sqlDb.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ErrorDatabase
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO row_states (spreadsheet_id, sheet, row, status, task_id, reason, attempts, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (spreadsheet_id, sheet, row) DO UPDATE SET
+			status = excluded.status,
+			task_id = CASE WHEN excluded.task_id != 0 THEN excluded.task_id ELSE row_states.task_id END,
+			reason = excluded.reason,
+			attempts = row_states.attempts + excluded.attempts,
+			updated_at = excluded.updated_at`,
+		s.spreadsheetId, s.sheet, rowNumber, event.Status, event.TaskId, event.Reason, failed, event.Time.Unix())
+	if err == nil {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO row_history (spreadsheet_id, sheet, row, event) VALUES (?, ?, ?, ?)`,
+			s.spreadsheetId, s.sheet, rowNumber, string(history))
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM row_history WHERE spreadsheet_id = ? AND sheet = ? AND row = ? AND id NOT IN (
+				SELECT id FROM row_history WHERE spreadsheet_id = ? AND sheet = ? AND row = ? ORDER BY id DESC LIMIT ?)`,
+			s.spreadsheetId, s.sheet, rowNumber, s.spreadsheetId, s.sheet, rowNumber, rowHistoryLimit)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.Error("Ошибка сохранения этапа строки в SQLite", "ROW", rowNumber, "STATUS", event.Status, "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetRowStatus(ctx context.Context, rowNumber string) (*models.RowState, error) {
+	if validateRowNumber(rowNumber) != nil {
+		return nil, models.ErrorIncorrectData
+	}
+	state := &models.RowState{Row: rowNumber}
+	var updated int64
+	err := s.sqlDb.QueryRowContext(ctx,
+		`SELECT status, task_id, reason, attempts, updated_at FROM row_states
+		WHERE spreadsheet_id = ? AND sheet = ? AND row = ?`,
+		s.spreadsheetId, s.sheet, rowNumber).Scan(&state.Status, &state.TaskId, &state.Reason, &state.Attempts, &updated)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrorNotFound
+	}
+	if err != nil {
+		slog.Error("Ошибка получения этапа строки из SQLite", "ROW", rowNumber, "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	state.UpdatedAt = time.Unix(updated, 0)
+
+	history, err := s.sqlDb.QueryContext(ctx,
+		`SELECT event FROM row_history WHERE spreadsheet_id = ? AND sheet = ? AND row = ? ORDER BY id`,
+		s.spreadsheetId, s.sheet, rowNumber)
+	if err != nil {
+		return nil, models.ErrorDatabase
+	}
+	defer history.Close()
+	for history.Next() {
+		var value string
+		if err := history.Scan(&value); err != nil {
+			return nil, models.ErrorDatabase
+		}
+		var event models.RowEvent
+		if err := json.Unmarshal([]byte(value), &event); err != nil {
+			slog.Warn("Некорректная запись в истории строки", "ROW", rowNumber, "VALUE", value)
+			continue
+		}
+		state.History = append(state.History, event)
+	}
+	if err := history.Err(); err != nil {
+		return nil, models.ErrorDatabase
+	}
+	return state, nil
+}
+
+func (s *SQLiteStore) ListRowsByStatus(ctx context.Context, status string) ([]string, error) {
+	if err := validateStatus(status); err != nil {
+		return nil, err
+	}
+	return s.queryRows(ctx,
+		`SELECT row FROM row_states WHERE spreadsheet_id = ? AND sheet = ? AND status = ? ORDER BY row`,
+		s.spreadsheetId, s.sheet, status)
+}
+
+func (s *SQLiteStore) SaveTaskRow(ctx context.Context, taskId int, rowObject *models.RowObject) error {
+	if taskId <= 0 || rowObject == nil {
+		return models.ErrorIncorrectData
+	}
+	data, err := json.Marshal(rowObject)
+	if err != nil {
+		return err
+	}
+	tx, err := s.sqlDb.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ErrorDatabase
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO task_rows (task_id, data, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (task_id) DO UPDATE SET data = excluded.data, expires_at = excluded.expires_at`,
+		taskId, string(data), time.Now().Add(taskRowTTL).Unix())
+	if err == nil {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO task_projects (task_id, project) VALUES (?, ?)
+			ON CONFLICT (task_id) DO UPDATE SET project = excluded.project`,
+			taskId, rowObject.Object.Project)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.Error("Ошибка сохранения строки задачи в SQLite", "TASK_ID", taskId, "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetTaskProjects(ctx context.Context, taskIds []int) (map[int]string, error) {
+	projects := make(map[int]string, len(taskIds))
+	if len(taskIds) == 0 {
+		return projects, nil
+	}
+	args := make([]interface{}, len(taskIds))
+	for i, taskId := range taskIds {
+		args[i] = taskId
+	}
+	result, err := s.sqlDb.QueryContext(ctx,
+		`SELECT task_id, project FROM task_projects WHERE project <> '' AND task_id IN (?`+strings.Repeat(", ?", len(taskIds)-1)+`)`,
+		args...)
+	if err != nil {
+		slog.Error("Ошибка запроса проектов задач из SQLite", "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	defer result.Close()
+	for result.Next() {
+		var taskId int
+		var project string
+		if err := result.Scan(&taskId, &project); err != nil {
+			return nil, models.ErrorDatabase
+		}
+		projects[taskId] = project
+	}
+	if result.Err() != nil {
+		return nil, models.ErrorDatabase
+	}
+	return projects, nil
+}
+
+func (s *SQLiteStore) GetTaskRow(ctx context.Context, taskId int) (*models.RowObject, error) {
+	var data string
+	err := s.sqlDb.QueryRowContext(ctx,
+		`SELECT data FROM task_rows WHERE task_id = ? AND expires_at > ?`,
+		taskId, time.Now().Unix()).Scan(&data)
+	return decodeRow(data, err)
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.sqlDb.Close()
+}
+
+func (s *SQLiteStore) queryRows(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	result, err := s.sqlDb.QueryContext(ctx, query, args...)
+	if err != nil {
+		slog.Error("Ошибка запроса строк из SQLite", "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	defer result.Close()
+	rows := []string{}
+	for result.Next() {
+		var row int
+		if err := result.Scan(&row); err != nil {
+			return nil, models.ErrorDatabase
+		}
+		rows = append(rows, strconv.Itoa(row))
+	}
+	if err := result.Err(); err != nil {
+		return nil, models.ErrorDatabase
+	}
+	return rows, nil
+}
+
+// decodeRow разбирает JSON строки, прочитанный из SQLite
+func decodeRow(data string, err error) (*models.RowObject, error) {
+	if err == sql.ErrNoRows {
+		return nil, models.ErrorNotFound
+	}
+	if err != nil {
+		slog.Error("Ошибка получения строки из SQLite", "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	var rowObject models.RowObject
+	err = json.Unmarshal([]byte(data), &rowObject)
+	if err != nil {
+		slog.Error("Проблема размаршалливания JSON в структуру", "ERROR", err)
+		return nil, models.ErrorUnmarshallJSON
+	}
+	return &rowObject, nil
+}
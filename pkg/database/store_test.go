@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+// testRedis поднимает Redis в памяти, чтобы тестам не нужен был настоящий сервер
+func testRedis(t *testing.T) (*Db, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	db := NewDB(server.Addr(), "", 0)
+	rdb := db.Connect()
+	t.Cleanup(func() { rdb.Close() })
+	return db, rdb
+}
+
+// testStores возвращает все реализации Store для одного и того же листа таблицы
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	db, rdb := testRedis(t)
+	db = db.ForSheet("store-sheet-id", "BOT")
+	stores := make(map[string]Store)
+	for _, backend := range []string{BackendRedis, BackendSQLite, BackendMemory} {
+		store, err := NewStore(backend, db, rdb, filepath.Join(t.TempDir(), "unu.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		stores[backend] = store
+	}
+	return stores
+}
+
+func TestStoreRows(t *testing.T) {
+	ctx := context.TODO()
+	row := models.NewRowObject(1, "Проект", "site.com", 2, "Описание", "01.01.2024")
+	for backend, store := range testStores(t) {
+		t.Run(backend, func(t *testing.T) {
+			for _, rowNumber := range []string{"12", "3", "7"} {
+				require.NoError(t, store.AddRow(ctx, rowNumber, row))
+			}
+			require.ErrorIs(t, store.AddRow(ctx, "5", models.NewRowObject(0, "", "", 0, "", "")), models.ErrorIncorrectData)
+
+			got, err := store.GetRow(ctx, "7")
+			require.NoError(t, err)
+			require.Equal(t, row, got)
+			_, err = store.GetRow(ctx, "8")
+			require.ErrorIs(t, err, models.ErrorNotFound)
+			_, err = store.GetRow(ctx, "-1")
+			require.ErrorIs(t, err, models.ErrorIncorrectData)
+
+			pending, err := store.ListPending(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []string{"3", "7", "12"}, pending)
+
+			deleted, err := store.DelRow(ctx, "7")
+			require.NoError(t, err)
+			require.EqualValues(t, 1, deleted)
+			deleted, err = store.DelRow(ctx, "7")
+			require.NoError(t, err)
+			require.EqualValues(t, 0, deleted)
+			pending, err = store.ListPending(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []string{"3", "12"}, pending)
+		})
+	}
+}
+
+func TestStoreGetRows(t *testing.T) {
+	ctx := context.TODO()
+	first := models.NewRowObject(1, "Первый", "first.com", 1, "Описание", "01.01.2024")
+	second := models.NewRowObject(1, "Второй", "second.com", 2, "Описание", "02.01.2024")
+	for backend, store := range testStores(t) {
+		t.Run(backend, func(t *testing.T) {
+			require.NoError(t, store.AddRow(ctx, "4", first))
+			require.NoError(t, store.AddRow(ctx, "15", second))
+
+			rows, err := store.GetRows(ctx, []string{"4", "5", "15"})
+			require.NoError(t, err)
+			require.Equal(t, map[string]*models.RowObject{"4": first, "15": second}, rows)
+
+			rows, err = store.GetRows(ctx, nil)
+			require.NoError(t, err)
+			require.Empty(t, rows)
+
+			_, err = store.GetRows(ctx, []string{"4", "abc"})
+			require.ErrorIs(t, err, models.ErrorIncorrectData)
+		})
+	}
+}
+
+func TestStoreRowStatus(t *testing.T) {
+	ctx := context.TODO()
+	for backend, store := range testStores(t) {
+		t.Run(backend, func(t *testing.T) {
+			_, err := store.GetRowStatus(ctx, "42")
+			require.ErrorIs(t, err, models.ErrorNotFound)
+
+			require.NoError(t, store.SetRowStatus(ctx, "42", models.RowEvent{Status: models.RowQueued}))
+			require.NoError(t, store.SetRowStatus(ctx, "42", models.RowEvent{Status: models.RowFailed, Reason: "таймаут"}))
+			require.NoError(t, store.SetRowStatus(ctx, "42", models.RowEvent{Status: models.RowFailed, Reason: "таймаут"}))
+			require.NoError(t, store.SetRowStatus(ctx, "9", models.RowEvent{Status: models.RowFailed, Reason: "нет ссылки"}))
+
+			failed, err := store.ListRowsByStatus(ctx, models.RowFailed)
+			require.NoError(t, err)
+			require.Equal(t, []string{"9", "42"}, failed)
+
+			require.NoError(t, store.SetRowStatus(ctx, "42", models.RowEvent{Status: models.RowCreated, TaskId: 1234}))
+			state, err := store.GetRowStatus(ctx, "42")
+			require.NoError(t, err)
+			require.Equal(t, models.RowCreated, state.Status)
+			require.Equal(t, 1234, state.TaskId)
+			require.Equal(t, 2, state.Attempts)
+			require.Len(t, state.History, 4)
+			require.Equal(t, "таймаут", state.History[1].Reason)
+
+			failed, err = store.ListRowsByStatus(ctx, models.RowFailed)
+			require.NoError(t, err)
+			require.Equal(t, []string{"9"}, failed)
+
+			require.ErrorIs(t, store.SetRowStatus(ctx, "42", models.RowEvent{Status: "unknown"}), models.ErrorIncorrectData)
+			_, err = store.ListRowsByStatus(ctx, "unknown")
+			require.ErrorIs(t, err, models.ErrorIncorrectData)
+		})
+	}
+}
+
+// Одновременная смена этапа оставляет строку в индексе ровно одного этапа
+func TestStoreRowStatusConcurrent(t *testing.T) {
+	ctx := context.TODO()
+	db, rdb := testRedis(t)
+	statuses := []string{models.RowReading, models.RowValidated, models.RowSent, models.RowFailed, models.RowCreated}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(status string) {
+			defer wg.Done()
+			require.NoError(t, db.SetRowStatus(ctx, rdb, "42", models.RowEvent{Status: status}))
+		}(statuses[i%len(statuses)])
+	}
+	wg.Wait()
+
+	state, err := db.GetRowStatus(ctx, rdb, "42")
+	require.NoError(t, err)
+	for _, status := range statuses {
+		rows, err := db.ListRowsByStatus(ctx, rdb, status)
+		require.NoError(t, err)
+		if status == state.Status {
+			require.Equal(t, []string{"42"}, rows)
+		} else {
+			require.Empty(t, rows, status)
+		}
+	}
+}
+
+func TestStoreTaskRows(t *testing.T) {
+	ctx := context.TODO()
+	row := models.NewRowObject(1, "Проект", "site.com", 1, "Описание", "01.01.2024")
+	for backend, store := range testStores(t) {
+		t.Run(backend, func(t *testing.T) {
+			require.NoError(t, store.SaveTaskRow(ctx, 555, row))
+			got, err := store.GetTaskRow(ctx, 555)
+			require.NoError(t, err)
+			require.Equal(t, row, got)
+
+			_, err = store.GetTaskRow(ctx, 556)
+			require.ErrorIs(t, err, models.ErrorNotFound)
+			require.ErrorIs(t, store.SaveTaskRow(ctx, 0, row), models.ErrorIncorrectData)
+
+			projects, err := store.GetTaskProjects(ctx, []int{555, 556})
+			require.NoError(t, err)
+			require.Equal(t, map[int]string{555: "Проект"}, projects)
+			projects, err = store.GetTaskProjects(ctx, nil)
+			require.NoError(t, err)
+			require.Empty(t, projects)
+		})
+	}
+}
+
+func TestNewStoreUnknownBackend(t *testing.T) {
+	_, err := NewStore("mongo", NewDB("", "", 0), nil, "")
+	require.ErrorIs(t, err, models.ErrorIncorrectData)
+}
+
+// Проект задачи нужен для отчёта о расходах и после того, как строка задачи истекла
+func TestTaskProjectOutlivesTaskRow(t *testing.T) {
+	ctx := context.TODO()
+	db, rdb := testRedis(t)
+	require.NoError(t, db.SaveTaskRow(ctx, rdb, 555, models.NewRowObject(1, "Проект", "site.com", 1, "Описание", "01.01.2024")))
+	require.NoError(t, rdb.Del(ctx, taskKey(555)).Err())
+
+	_, err := db.GetTaskRow(ctx, rdb, 555)
+	require.ErrorIs(t, err, models.ErrorNotFound)
+	projects, err := db.GetTaskProjects(ctx, rdb, []int{555})
+	require.NoError(t, err)
+	require.Equal(t, map[int]string{555: "Проект"}, projects)
+	ttl, err := rdb.TTL(ctx, taskProjectsKey).Result()
+	require.NoError(t, err)
+	require.Less(t, ttl, time.Duration(0))
+}
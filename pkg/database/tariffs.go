@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+const tariffsKey = "unu:cache:tariffs"
+
+// SaveTariffs кладёт ответ get_tariffs в кэш на ttl
+func (db *Db) SaveTariffs(ctx context.Context, rdb *redis.Client, data []byte, ttl time.Duration) error {
+	err := rdb.Set(ctx, tariffsKey, data, ttl).Err()
+	if err != nil {
+		slog.Error("Ошибка сохранения тарифов в кэш", "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+// GetTariffs возвращает тарифы из кэша. Если кэш пуст или устарел, возвращается models.ErrorZeroValue
+func (db *Db) GetTariffs(ctx context.Context, rdb *redis.Client) ([]byte, error) {
+	data, err := rdb.Get(ctx, tariffsKey).Bytes()
+	if err == redis.Nil {
+		return nil, models.ErrorZeroValue
+	}
+	if err != nil {
+		slog.Error("Ошибка получения тарифов из кэша", "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	return data, nil
+}
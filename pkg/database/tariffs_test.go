@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTariffsCache(t *testing.T) {
+	db, rdb := testRedis(t)
+	ctx := context.TODO()
+	require.NoError(t, rdb.Del(ctx, tariffsKey).Err())
+
+	_, err := db.GetTariffs(ctx, rdb)
+	require.ErrorIs(t, err, models.ErrorZeroValue)
+
+	require.NoError(t, db.SaveTariffs(ctx, rdb, []byte(`[{"id":"3"}]`), time.Minute))
+	data, err := db.GetTariffs(ctx, rdb)
+	require.NoError(t, err)
+	require.Equal(t, `[{"id":"3"}]`, string(data))
+
+	ttl, err := rdb.TTL(ctx, tariffsKey).Result()
+	require.NoError(t, err)
+	require.Greater(t, ttl, time.Duration(0))
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/utils"
+)
+
+const (
+	// Размер сигнатуры MinHash: 16 полос по 4 значения. Тексты со сходством 0.8 и выше
+	// почти наверняка совпадут хотя бы в одной полосе
+	textSignatureSize = 64
+	textBands         = 16
+
+	// TextHistoryTTL сколько помнить отправленные тексты
+	TextHistoryTTL = 180 * 24 * time.Hour
+	// DefaultTextSimilarity с какого сходства текст считается повтором
+	DefaultTextSimilarity = 0.8
+)
+
+// TextHistory тексты отзывов, по которым уже создавались задачи. Общая для всех таблиц и листов,
+// чтобы находить тексты, взятые из прошлых месяцев
+type TextHistory struct {
+	rdb       *redis.Client
+	threshold float64
+}
+
+func NewTextHistory(rdb *redis.Client) *TextHistory {
+	return &TextHistory{
+		rdb:       rdb,
+		threshold: DefaultTextSimilarity,
+	}
+}
+
+func textKey(hash string) string {
+	return "unu:texts:fp:" + hash
+}
+
+// textBandKey множество отпечатков, у которых совпадает полоса band сигнатуры
+func textBandKey(band int, signature []uint64) string {
+	rows := textSignatureSize / textBands
+	hasher := fnv.New64a()
+	for _, value := range signature[band*rows : (band+1)*rows] {
+		fmt.Fprint(hasher, value, ":")
+	}
+	return fmt.Sprintf("unu:texts:band:%d:%x", band, hasher.Sum64())
+}
+
+// SaveText запоминает текст, по которому создана задача. Hash и Signature вычисляются из text
+func (h *TextHistory) SaveText(ctx context.Context, text string, fp models.TextFingerprint) error {
+	if utils.NormalizeText(text) == "" {
+		return models.ErrorIncorrectData
+	}
+	fp.Hash = utils.TextHash(text)
+	fp.Signature = utils.MinHash(text, textSignatureSize)
+	if fp.Time.IsZero() {
+		fp.Time = time.Now().Truncate(time.Second)
+	}
+	data, err := json.Marshal(fp)
+	if err != nil {
+		return models.ErrorIncorrectData
+	}
+	pipe := h.rdb.TxPipeline()
+	pipe.Set(ctx, textKey(fp.Hash), data, TextHistoryTTL)
+	for band := 0; band < textBands; band++ {
+		key := textBandKey(band, fp.Signature)
+		pipe.SAdd(ctx, key, fp.Hash)
+		pipe.Expire(ctx, key, TextHistoryTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка сохранения отпечатка текста", "ROW", fp.Row, "TASK_ID", fp.TaskId, "ERROR", err)
+		return models.ErrorDatabase
+	}
+	return nil
+}
+
+// FindSimilarTexts ищет отправленные раньше тексты, совпадающие с text или похожие на него.
+// Сначала идут самые похожие
+func (h *TextHistory) FindSimilarTexts(ctx context.Context, text string) ([]models.TextMatch, error) {
+	if utils.NormalizeText(text) == "" {
+		return nil, nil
+	}
+	hash := utils.TextHash(text)
+	signature := utils.MinHash(text, textSignatureSize)
+
+	pipe := h.rdb.Pipeline()
+	bands := make([]*redis.StringSliceCmd, textBands)
+	for band := range bands {
+		bands[band] = pipe.SMembers(ctx, textBandKey(band, signature))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.Error("Ошибка поиска похожих текстов", "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	candidates := []string{textKey(hash)}
+	for _, band := range bands {
+		for _, candidate := range band.Val() {
+			if key := textKey(candidate); !slices.Contains(candidates, key) {
+				candidates = append(candidates, key)
+			}
+		}
+	}
+
+	values, err := h.rdb.MGet(ctx, candidates...).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		slog.Error("Ошибка получения отпечатков текстов", "ERROR", err)
+		return nil, models.ErrorDatabase
+	}
+	matches := []models.TextMatch{}
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// Отпечаток устарел, а полоса ещё хранит на него ссылку
+			continue
+		}
+		var fp models.TextFingerprint
+		if err := json.Unmarshal([]byte(data), &fp); err != nil {
+			continue
+		}
+		similarity := utils.MinHashSimilarity(signature, fp.Signature)
+		if fp.Hash == hash {
+			similarity = 1
+		}
+		if similarity >= h.threshold {
+			matches = append(matches, models.TextMatch{TextFingerprint: fp, Similarity: similarity})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b models.TextMatch) int {
+		switch {
+		case a.Similarity > b.Similarity:
+			return -1
+		case a.Similarity < b.Similarity:
+			return 1
+		}
+		return 0
+	})
+	return matches, nil
+}
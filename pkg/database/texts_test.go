@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextHistory(t *testing.T) {
+	ctx := context.TODO()
+	_, rdb := testRedis(t)
+	history := NewTextHistory(rdb)
+
+	original := "Отличная клиника, врачи внимательные и вежливые, записали быстро, всё объяснили, цены адекватные, всем советую"
+	matches, err := history.FindSimilarTexts(ctx, original)
+	require.NoError(t, err)
+	require.Empty(t, matches)
+
+	require.NoError(t, history.SaveText(ctx, original, models.TextFingerprint{Row: "5", TaskId: 1234, Project: "Клиника"}))
+	require.NoError(t, history.SaveText(ctx, "Заказывали пиццу на день рождения, привезли горячей и вовремя", models.TextFingerprint{Row: "6", TaskId: 1235}))
+	require.ErrorIs(t, history.SaveText(ctx, " !!! ", models.TextFingerprint{Row: "7"}), models.ErrorIncorrectData)
+
+	// Тот же текст с другим регистром и пунктуацией
+	matches, err = history.FindSimilarTexts(ctx, "ОТЛИЧНАЯ клиника врачи внимательные и вежливые записали быстро всё объяснили цены адекватные всем советую!!!")
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, 1.0, matches[0].Similarity)
+	require.Equal(t, "5", matches[0].Row)
+	require.Equal(t, 1234, matches[0].TaskId)
+
+	// Почти тот же текст
+	matches, err = history.FindSimilarTexts(ctx, "Отличная клиника, врачи внимательные и вежливые, записали быстро, всё объяснили, цены адекватные, всем рекомендую")
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Less(t, matches[0].Similarity, 1.0)
+	require.GreaterOrEqual(t, matches[0].Similarity, DefaultTextSimilarity)
+
+	matches, err = history.FindSimilarTexts(ctx, "Хорошее кафе, вкусный кофе и свежая выпечка")
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
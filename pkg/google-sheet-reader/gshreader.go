@@ -3,7 +3,6 @@ package googlesheetreader
 import (
 	"context"
 	"fmt"
-	"log"
 	"log/slog"
 
 	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
@@ -11,8 +10,32 @@ import (
 	"google.golang.org/api/sheets/v4"
 )
 
-func googleServiceConstructor(ctx context.Context) *sheets.Service {
-	svc, err := sheets.NewService(ctx, option.WithCredentialsFile("creds.json"))
+// DefaultCredentialsFile ключ сервисного аккаунта Google, если в настройках не указан другой
+const DefaultCredentialsFile = "creds.json"
+
+// Sheets доступ к одной Google таблице с ключом сервисного аккаунта из credentialsFile
+type Sheets struct {
+	spreadsheetId   string
+	credentialsFile string
+}
+
+func New(spreadsheetId, credentialsFile string) *Sheets {
+	if credentialsFile == "" {
+		credentialsFile = DefaultCredentialsFile
+	}
+	return &Sheets{
+		spreadsheetId:   spreadsheetId,
+		credentialsFile: credentialsFile,
+	}
+}
+
+// SpreadsheetId возвращает ID таблицы
+func (s *Sheets) SpreadsheetId() string {
+	return s.spreadsheetId
+}
+
+func (s *Sheets) service(ctx context.Context) *sheets.Service {
+	svc, err := sheets.NewService(ctx, option.WithCredentialsFile(s.credentialsFile))
 	if err != nil {
 		slog.Error("Err is:", "ERROR", err)
 	}
@@ -25,15 +48,23 @@ func googleServiceConstructor(ctx context.Context) *sheets.Service {
 // 2 - гендерный пол
 // 3 - текст отзыва
 // 5 - дата публикации
-func Reader(spreadsheetId, spreadsheetName string, rowNumber string) (*sheets.ValueRange, error) {
+func (s *Sheets) Reader(spreadsheetName string, rowNumber string) (*sheets.ValueRange, error) {
 	readRange := fmt.Sprintf("%s!A%s:F%s", spreadsheetName, rowNumber, rowNumber)
 
 	ctx := context.Background()
-	svc := googleServiceConstructor(ctx)
+	svc := s.service(ctx)
+	if svc == nil {
+		return nil, models.ErrorGoogleSheet
+	}
 
-	resp, err := svc.Spreadsheets.Values.Get(spreadsheetId, readRange).Do()
+	resp, err := svc.Spreadsheets.Values.Get(s.spreadsheetId, readRange).Do()
 	if err != nil {
-		log.Fatalf("Unable to retrieve data from sheet: %v", err)
+		slog.Error("Unable to retrieve data from sheet", "ERROR", err)
+		return nil, models.ErrorGoogleSheet
+	}
+	if len(resp.Values) == 0 || len(resp.Values[0]) < 6 {
+		slog.Warn("Строка таблицы пустая или заполнена не полностью", "ROW", rowNumber)
+		return nil, models.ErrorZeroValue
 	}
 	if len(fmt.Sprint(resp.Values[0][3])) > 2300 {
 		// TODO: Проверка работает корректно. Нужно обработать кейс, что делать если длина комментария больше 2300 символов.
@@ -45,21 +76,61 @@ func Reader(spreadsheetId, spreadsheetName string, rowNumber string) (*sheets.Va
 
 	return resp, nil
 }
-func ReaderFromCell(spreadsheetId, spreadsheetName string, cell string) (*sheets.ValueRange, error) {
+
+func (s *Sheets) ReaderFromCell(spreadsheetName string, cell string) (*sheets.ValueRange, error) {
 
 	readRange := fmt.Sprintf("%s!%s:%s", spreadsheetName, cell, cell)
 
 	ctx := context.Background()
-	svc, err := sheets.NewService(ctx, option.WithCredentialsFile("creds.json"))
-	if err != nil {
-		log.Printf("\n Err is %v", err)
+	svc := s.service(ctx)
+	if svc == nil {
 		return nil, models.ErrorGoogleSheet
 	}
-	resp, err := svc.Spreadsheets.Values.Get(spreadsheetId, readRange).Do()
+	resp, err := svc.Spreadsheets.Values.Get(s.spreadsheetId, readRange).Do()
 	if err != nil {
-		log.Fatalf("Unable to retrieve data from sheet: %v", err)
+		slog.Error("Unable to retrieve data from sheet", "ERROR", err)
 		return nil, models.ErrorGoogleSheet
 	}
+	if len(resp.Values) == 0 || len(resp.Values[0]) == 0 {
+		return nil, models.ErrorZeroValue
+	}
 
 	return resp, nil
 }
+
+// Writer заменяет содержимое листа spreadsheetName значениями values, начиная с ячейки A1
+func (s *Sheets) Writer(spreadsheetName string, values [][]interface{}) error {
+	ctx := context.Background()
+	svc := s.service(ctx)
+	if svc == nil {
+		return models.ErrorGoogleSheet
+	}
+
+	_, err := svc.Spreadsheets.Values.Clear(s.spreadsheetId, spreadsheetName, &sheets.ClearValuesRequest{}).Do()
+	if err != nil {
+		slog.Error("Unable to clear sheet", "SHEET", spreadsheetName, "ERROR", err)
+		return models.ErrorGoogleSheet
+	}
+	_, err = svc.Spreadsheets.Values.Update(s.spreadsheetId, spreadsheetName+"!A1", &sheets.ValueRange{Values: values}).
+		ValueInputOption("USER_ENTERED").Do()
+	if err != nil {
+		slog.Error("Unable to write data to sheet", "SHEET", spreadsheetName, "ERROR", err)
+		return models.ErrorGoogleSheet
+	}
+	return nil
+}
+
+// Reader читает строку таблицы с ключом из DefaultCredentialsFile
+func Reader(spreadsheetId, spreadsheetName string, rowNumber string) (*sheets.ValueRange, error) {
+	return New(spreadsheetId, DefaultCredentialsFile).Reader(spreadsheetName, rowNumber)
+}
+
+// ReaderFromCell читает одну ячейку таблицы с ключом из DefaultCredentialsFile
+func ReaderFromCell(spreadsheetId, spreadsheetName string, cell string) (*sheets.ValueRange, error) {
+	return New(spreadsheetId, DefaultCredentialsFile).ReaderFromCell(spreadsheetName, cell)
+}
+
+// Writer заменяет содержимое листа с ключом из DefaultCredentialsFile
+func Writer(spreadsheetId, spreadsheetName string, values [][]interface{}) error {
+	return New(spreadsheetId, DefaultCredentialsFile).Writer(spreadsheetName, values)
+}
@@ -1 +1,162 @@
 package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Options настройки логгера. Обычно берутся из config.Log и config.Config.Secrets
+type Options struct {
+	Level   string   // debug, info, warn или error
+	Format  string   // text или json
+	Secrets []string // значения, которые заменяются на *** в сообщениях и атрибутах
+}
+
+// masked чем заменяются токены и ключи
+const masked = "***"
+
+// secretParams параметры запросов с ключами, которые скрываются, даже если самого ключа нет в Secrets
+var secretParams = regexp.MustCompile(`(?i)\b(api_key|token|password)=[^&\s"]+`)
+
+// New создаёт slog.Logger, который пишет в w в формате opts.Format, скрывает секреты
+// и добавляет к каждой записи атрибуты из контекста (см. With)
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+		return nil, fmt.Errorf("уровень логов %q: %w", opts.Level, err)
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var next slog.Handler
+	switch opts.Format {
+	case "json":
+		next = slog.NewJSONHandler(w, handlerOpts)
+	case "text", "":
+		next = slog.NewTextHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("формат логов %q: нужен text или json", opts.Format)
+	}
+	return slog.New(&handler{next: next, redactor: newRedactor(opts.Secrets)}), nil
+}
+
+type ctxKey struct{}
+
+// With возвращает контекст, в котором все записи лога получат атрибуты args
+// (пары ключ-значение, как в slog.Logger.Info). Атрибуты родительского контекста сохраняются,
+// атрибут с тем же ключом заменяется
+func With(ctx context.Context, args ...any) context.Context {
+	record := slog.NewRecord(time.Time{}, 0, "", 0)
+	record.Add(args...)
+	attrs := append([]slog.Attr{}, Attrs(ctx)...)
+	record.Attrs(func(attr slog.Attr) bool {
+		i := slices.IndexFunc(attrs, func(old slog.Attr) bool { return old.Key == attr.Key })
+		if i >= 0 {
+			attrs[i] = attr
+		} else {
+			attrs = append(attrs, attr)
+		}
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+// WithUpdate помечает контекст обработки одного обновления Telegram
+func WithUpdate(ctx context.Context, updateID, chatID int64) context.Context {
+	return With(ctx, "UPDATE_ID", updateID, "CHAT_ID", chatID)
+}
+
+// WithRow помечает контекст обработки строки таблицы
+func WithRow(ctx context.Context, row string) context.Context {
+	return With(ctx, "ROW", row)
+}
+
+// Attrs атрибуты, добавленные в контекст через With
+func Attrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return attrs
+}
+
+// handler дополняет записи атрибутами контекста и скрывает секреты перед передачей в next
+type handler struct {
+	next     slog.Handler
+	redactor *strings.Replacer
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, h.redact(r.Message), r.PC)
+	for _, attr := range Attrs(ctx) {
+		record.AddAttrs(h.redactAttr(attr))
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		record.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, record)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactAttr(attr)
+	}
+	return &handler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+// newRedactor заменяет секреты как есть и в виде параметра URL
+func newRedactor(secrets []string) *strings.Replacer {
+	var pairs []string
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		pairs = append(pairs, secret, masked)
+		if escaped := url.QueryEscape(secret); escaped != secret {
+			pairs = append(pairs, escaped, masked)
+		}
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// redact скрывает секреты из Options и значения параметров api_key, token и password
+func (h *handler) redact(text string) string {
+	return secretParams.ReplaceAllString(h.redactor.Replace(text), "$1="+masked)
+}
+
+func (h *handler) redactAttr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, item := range group {
+			redacted[i] = h.redactAttr(item)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		// Ошибки и прочие значения проверяем в том виде, в каком их напечатает обработчик
+		text := fmt.Sprint(value.Any())
+		if redacted := h.redact(text); redacted != text {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
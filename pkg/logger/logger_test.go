@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedaction(t *testing.T) {
+	var out bytes.Buffer
+	log, err := New(&out, Options{Level: "debug", Format: "text", Secrets: []string{"123:tg-secret", "unu-key"}})
+	require.NoError(t, err)
+
+	log.Debug("запрос https://api.telegram.org/bot123:tg-secret/getMe",
+		"PARAMS", "action=get_balance&api_key=unu-key",
+		"ERROR", errors.New("Post \"https://unu.im/api?api_key=other-key\": timeout"),
+		"URL", "https://example.com/?token=123%3Atg-secret")
+	text := out.String()
+	assert.NotContains(t, text, "tg-secret")
+	assert.NotContains(t, text, "unu-key")
+	assert.NotContains(t, text, "other-key")
+	assert.Contains(t, text, "bot***/getMe")
+	assert.Contains(t, text, "api_key=***")
+
+	out.Reset()
+	log.With("TOKEN", "unu-key").Info("готово")
+	assert.Contains(t, out.String(), "TOKEN=***")
+}
+
+func TestContextAttrs(t *testing.T) {
+	var out bytes.Buffer
+	log, err := New(&out, Options{Level: "info", Format: "json"})
+	require.NoError(t, err)
+
+	ctx := WithRow(WithUpdate(context.Background(), 42, 100), "7")
+	log.DebugContext(ctx, "не попадёт в лог")
+	log.InfoContext(ctx, "строка обработана", "TASK_ID", 555)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "строка обработана", record["msg"])
+	assert.Equal(t, 42.0, record["UPDATE_ID"])
+	assert.Equal(t, 100.0, record["CHAT_ID"])
+	assert.Equal(t, "7", record["ROW"])
+	assert.Equal(t, 555.0, record["TASK_ID"])
+	// Родительский контекст не меняется, повторный ключ заменяет прежнее значение
+	assert.Len(t, Attrs(WithUpdate(context.Background(), 1, 1)), 2)
+	attrs := Attrs(WithRow(ctx, "8"))
+	require.Len(t, attrs, 3)
+	assert.Equal(t, "8", attrs[2].Value.String())
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, Options{Level: "verbose"})
+	require.Error(t, err)
+	_, err = New(&bytes.Buffer{}, Options{Level: "info", Format: "xml"})
+	require.Error(t, err)
+}
@@ -1,6 +1,9 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	// Errors
@@ -9,14 +12,142 @@ var (
 	ErrorUnmarshallJSON       = errors.New("Promblem with decoding from JSON")
 	ErrorIncorrectData        = errors.New("Incorrect data")
 	ErrorDatabase             = errors.New("Error with database")
+	ErrorRedisUnavailable     = errors.New("Redis is unavailable")
+	ErrorNotFound             = errors.New("Record not found")
+	ErrorRowLocked            = errors.New("Row is already being processed")
+	ErrorLockLost             = errors.New("Row lock is lost")
+	ErrorDuplicateText        = errors.New("Review text duplicates an earlier one")
+	ErrorTaskCreated          = errors.New("Task for this row is already created")
 	LongMessage               = errors.New("Long message. Length bigger 2300 symbols")
 	ErrorMatchingSite         = errors.New("Error with matching choose site. Please check correct name")
 	ErrorGoogleSheet          = errors.New("Error with getting value from google sheet")
+	ErrorAccessDenied         = errors.New("Access denied")
+	ErrorUnknownRole          = errors.New("Unknown role")
+	ErrorUNUAPI               = errors.New("UNU API returned error")
+	ErrorUnknownTariff        = errors.New("Unknown tariff")
+	ErrorTariffPrice          = errors.New("Price is lower than tariff minimum")
+	ErrorTariffTargeting      = errors.New("Tariff does not support targeting")
+	ErrorConfig               = errors.New("Invalid configuration")
 	// Other
 	GenderMale   = "мужской"
 	GenderFemale = "женский"
 )
 
+// Роли пользователей Telegram. Каждая следующая роль включает права предыдущей
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// RoleLevel возвращает уровень роли для сравнения прав. Для неизвестной роли возвращает 0
+func RoleLevel(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// Этапы обработки строки таблицы
+const (
+	RowQueued    = "queued"    // строка выбрана для создания задачи
+	RowReading   = "reading"   // читаем строку из таблицы
+	RowValidated = "validated" // строка прочитана и прошла проверки
+	RowSent      = "sent"      // запрос add_task отправлен в UNU
+	RowCreated   = "created"   // задача создана, TaskId заполнен
+	RowFailed    = "failed"    // ошибка, причина в Reason, число попыток в Attempts
+	RowSkipped   = "skipped"   // строка пропущена, например пустая
+)
+
+// RowStatuses все этапы в порядке обработки
+var RowStatuses = []string{RowQueued, RowReading, RowValidated, RowSent, RowCreated, RowFailed, RowSkipped}
+
+// RowEvent переход строки на новый этап
+type RowEvent struct {
+	Status string    `json:"status"`
+	TaskId int       `json:"task_id,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	Hash   string    `json:"hash,omitempty"` // хэш содержимого строки, по которому создана задача (этап created)
+	Time   time.Time `json:"time"`
+}
+
+// RowState текущее состояние строки и история переходов
+type RowState struct {
+	Row       string
+	Status    string
+	TaskId    int
+	Reason    string
+	Attempts  int
+	UpdatedAt time.Time
+	History   []RowEvent
+}
+
+// LastCreated переход на этап created, если с тех пор строку только ставили в очередь или начинали читать,
+// но задачу по ней не отправляли. Иначе nil
+func (s *RowState) LastCreated() *RowEvent {
+	for i := len(s.History) - 1; i >= 0; i-- {
+		switch s.History[i].Status {
+		case RowQueued, RowReading, RowValidated:
+			continue
+		case RowCreated:
+			return &s.History[i]
+		}
+		return nil
+	}
+	return nil
+}
+
+// TaskJob задание очереди на создание задачи по строке таблицы
+type TaskJob struct {
+	Row       int    `json:"row"`
+	FolderId  int    `json:"folder_id"`
+	UserId    int64  `json:"user_id"`
+	Owner     string `json:"owner"`    // кто поставил строку в очередь, например @username
+	ChatID    int64  `json:"chat_id"`  // куда прислать отчёт
+	Batch     string `json:"batch"`    // пачка строк из одной команды /create_task
+	Attempts  int    `json:"attempts"` // сколько раз задание уже завершалось ошибкой
+	LastError string `json:"last_error,omitempty"`
+	// Оператор разрешил создать задачу, хотя такой текст уже отправлялся
+	AllowDuplicate bool `json:"allow_duplicate,omitempty"`
+}
+
+// DeadJob задание, которое не удалось выполнить. Число попыток и последняя ошибка — в Job
+type DeadJob struct {
+	Job    TaskJob   `json:"job"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+// TextFingerprint отпечаток текста отзыва, по которому уже создавалась задача
+type TextFingerprint struct {
+	Hash      string    `json:"hash"`      // utils.TextHash
+	Signature []uint64  `json:"signature"` // utils.MinHash для поиска почти одинаковых текстов
+	Row       string    `json:"row"`
+	TaskId    int       `json:"task_id"`
+	Project   string    `json:"project"`
+	Time      time.Time `json:"time"`
+}
+
+// TextMatch найденный повтор текста и сходство от 0 до 1 (1 — тексты совпадают)
+type TextMatch struct {
+	TextFingerprint
+	Similarity float64
+}
+
+// JobBatch итог обработки пачки заданий, отправляется в чат, когда обработаны все строки
+type JobBatch struct {
+	ID      string
+	ChatID  int64
+	Total   int
+	Created int
+	Lines   []string
+}
+
 type RowObject struct {
 	UserId int `json:"userId"`
 	Object struct {
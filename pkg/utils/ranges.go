@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+)
+
+// MaxRangeSize ограничивает количество номеров, которые можно ввести за один раз
+const MaxRangeSize = 500
+
+// ParseNumberRanges разбирает ввод пользователя вида "7", "2-15", "2:15" или "3, 5, 10-12"
+// и возвращает отсортированный список номеров без повторов
+func ParseNumberRanges(input string) ([]int, error) {
+	seen := make(map[int]bool)
+	numbers := []int{}
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		begin, end, err := parseRange(part)
+		if err != nil {
+			slog.Warn("Некорректный диапазон номеров", "INPUT", part)
+			return nil, models.ErrorIncorrectData
+		}
+		if end-begin+1 > MaxRangeSize || len(numbers)+(end-begin+1) > MaxRangeSize {
+			return nil, models.ErrorIncorrectData
+		}
+		for number := begin; number <= end; number++ {
+			if !seen[number] {
+				seen[number] = true
+				numbers = append(numbers, number)
+			}
+		}
+	}
+	if len(numbers) == 0 {
+		return nil, models.ErrorIncorrectData
+	}
+	return SortByUp(numbers), nil
+}
+
+func parseRange(part string) (int, int, error) {
+	bounds := strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == ':' })
+	if len(bounds) == 0 || len(bounds) > 2 || strings.Count(part, "-")+strings.Count(part, ":") >= 2 {
+		return 0, 0, models.ErrorIncorrectData
+	}
+	begin, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	end := begin
+	if len(bounds) == 2 {
+		end, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if err != nil {
+			return 0, 0, err
+		}
+	} else if strings.ContainsAny(part, "-:") {
+		return 0, 0, models.ErrorIncorrectData
+	}
+	if begin <= 0 || end < begin {
+		return 0, 0, models.ErrorIncorrectData
+	}
+	return begin, end, nil
+}
+
+// FormatNumberRanges сворачивает отсортированный список номеров обратно в короткую запись: "2-5, 8, 10-11"
+func FormatNumberRanges(numbers []int) string {
+	parts := []string{}
+	for i := 0; i < len(numbers); {
+		j := i
+		for j+1 < len(numbers) && numbers[j+1] == numbers[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(numbers[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(numbers[i])+"-"+strconv.Itoa(numbers[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNumberRanges(t *testing.T) {
+	positive := map[string][]int{
+		"7":             {7},
+		"2-5":           {2, 3, 4, 5},
+		"2:4":           {2, 3, 4},
+		" 3, 5, 10-12 ": {3, 5, 10, 11, 12},
+		"5, 3-5, 4":     {3, 4, 5},
+		"9 - 10":        {9, 10},
+	}
+	for input, expRes := range positive {
+		gotRes, err := ParseNumberRanges(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expRes, gotRes, input)
+	}
+
+	negative := []string{"", " , ", "0", "-3", "5-2", "a-b", "2-", "1-2-3", "1-100000", "2;3"}
+	for _, input := range negative {
+		_, err := ParseNumberRanges(input)
+		require.ErrorIs(t, err, models.ErrorIncorrectData, input)
+	}
+}
+
+func TestFormatNumberRanges(t *testing.T) {
+	assert.Equal(t, "2-5, 8, 10-11", FormatNumberRanges([]int{2, 3, 4, 5, 8, 10, 11}))
+	assert.Equal(t, "7", FormatNumberRanges([]int{7}))
+	assert.Equal(t, "", FormatNumberRanges(nil))
+}
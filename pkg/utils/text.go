@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// NormalizeText приводит текст к виду для сравнения: нижний регистр, ё→е,
+// без знаков препинания и повторяющихся пробелов
+func NormalizeText(text string) string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	return strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// Shingles возвращает множество шинглов из size подряд идущих слов нормализованного текста.
+// Если слов меньше size, весь текст считается одним шинглом
+func Shingles(text string, size int) map[string]struct{} {
+	words := strings.Fields(NormalizeText(text))
+	shingles := make(map[string]struct{})
+	if len(words) == 0 {
+		return shingles
+	}
+	if len(words) < size {
+		shingles[strings.Join(words, " ")] = struct{}{}
+		return shingles
+	}
+	for i := 0; i+size <= len(words); i++ {
+		shingles[strings.Join(words[i:i+size], " ")] = struct{}{}
+	}
+	return shingles
+}
+
+// Containment возвращает долю шинглов из expected, которые встречаются в actual (от 0 до 1).
+// В отличие от сравнения целиком, лишний текст вокруг (ссылка, приписки) не снижает оценку
+func Containment(expected, actual string) float64 {
+	expectedShingles := Shingles(expected, 2)
+	if len(expectedShingles) == 0 {
+		return 0
+	}
+	actualShingles := Shingles(actual, 2)
+	if len(actualShingles) == 0 {
+		return 0
+	}
+	// Для очень коротких текстов сравниваем по словам, иначе шинглы не совпадут из-за границ
+	if len(expectedShingles) == 1 || len(actualShingles) == 1 {
+		expectedShingles = Shingles(expected, 1)
+		actualShingles = Shingles(actual, 1)
+	}
+	found := 0
+	for shingle := range expectedShingles {
+		if _, ok := actualShingles[shingle]; ok {
+			found++
+		}
+	}
+	return float64(found) / float64(len(expectedShingles))
+}
+
+// TextHash отпечаток нормализованного текста: у текстов, отличающихся только регистром,
+// пунктуацией и пробелами, он одинаковый
+func TextHash(text string) string {
+	sum := sha256.Sum256([]byte(NormalizeText(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// MinHash сигнатура текста из size значений по шинглам из трёх слов. Доля совпадающих позиций
+// двух сигнатур приближает долю общих шинглов текстов (коэффициент Жаккара)
+func MinHash(text string, size int) []uint64 {
+	signature := make([]uint64, size)
+	for i := range signature {
+		signature[i] = math.MaxUint64
+	}
+	for shingle := range Shingles(text, 3) {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(shingle))
+		base := hasher.Sum64()
+		for i := range signature {
+			if value := mix64(base + uint64(i)*0x9E3779B97F4A7C15); value < signature[i] {
+				signature[i] = value
+			}
+		}
+	}
+	return signature
+}
+
+// MinHashSimilarity доля совпадающих позиций двух сигнатур одного размера (от 0 до 1)
+func MinHashSimilarity(a, b []uint64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] && a[i] != math.MaxUint64 {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(a))
+}
+
+// mix64 перемешивает биты (splitmix64), из него получается семейство независимых хеш-функций для MinHash
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xBF58476D1CE4E5B9
+	x ^= x >> 27
+	x *= 0x94D049BB133111EB
+	x ^= x >> 31
+	return x
+}
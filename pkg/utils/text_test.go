@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeText(t *testing.T) {
+	assert.Equal(t, "все отлично 10 из 10", NormalizeText("  Всё ОТЛИЧНО!!! 10 из 10 :)  "))
+	assert.Equal(t, "", NormalizeText(" ... "))
+}
+
+func TestContainment(t *testing.T) {
+	expected := "Отличная клиника, врачи внимательные, всем советую!"
+	assert.Equal(t, 1.0, Containment(expected, "Готово: https://site.ru/review/1 Отличная клиника врачи внимательные всем советую"))
+	assert.Less(t, Containment(expected, "Хорошее кафе, вкусный кофе"), 0.2)
+	assert.Equal(t, 0.0, Containment(expected, ""))
+	assert.Equal(t, 1.0, Containment("Супер", "супер!"))
+}
+
+func TestTextHash(t *testing.T) {
+	assert.Equal(t, TextHash("Отличный сервис, всем советую!"), TextHash("  отличный СЕРВИС всем советую"))
+	assert.NotEqual(t, TextHash("Отличный сервис"), TextHash("Ужасный сервис"))
+}
+
+func TestMinHash(t *testing.T) {
+	original := "Отличная клиника, врачи внимательные и вежливые, записали быстро, всё объяснили, цены адекватные, всем советую"
+	edited := "Отличная клиника, врачи внимательные и вежливые, записали быстро, всё объяснили, цены адекватные, всем рекомендую"
+	other := "Заказывали пиццу на день рождения, привезли горячей и вовремя, курьер вежливый"
+
+	signature := MinHash(original, 64)
+	assert.Len(t, signature, 64)
+	assert.Equal(t, signature, MinHash(strings.ToUpper(original), 64))
+	assert.Equal(t, 1.0, MinHashSimilarity(signature, MinHash(original, 64)))
+	assert.Greater(t, MinHashSimilarity(signature, MinHash(edited, 64)), 0.6)
+	assert.Less(t, MinHashSimilarity(signature, MinHash(other, 64)), 0.2)
+	assert.Equal(t, 0.0, MinHashSimilarity(MinHash("", 64), MinHash("", 64)))
+}
@@ -0,0 +1,197 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/utils"
+)
+
+const (
+	// Формат даты публикации, к которому приводятся даты из таблицы
+	DateLayout = "02.01.2006"
+
+	// Ограничения по умолчанию. Длиннее 2300 символов UNU описание не принимает
+	DefaultMaxTextLength    = 2300
+	DefaultMaxProjectLength = 255
+)
+
+// Названия полей строки в сообщениях об ошибках
+const (
+	FieldRow         = "строка"
+	FieldUserId      = "пользователь"
+	FieldProject     = "проект"
+	FieldLink        = "ссылка"
+	FieldGender      = "пол"
+	FieldText        = "текст"
+	FieldPublication = "дата публикации"
+)
+
+// FieldError ошибка в одном поле строки
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors все ошибки строки сразу, чтобы клиент мог исправить их за один раз.
+// errors.Is(err, models.ErrorIncorrectData) для них выполняется
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = fieldError.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e Errors) Unwrap() error {
+	return models.ErrorIncorrectData
+}
+
+// Fields возвращает названия полей с ошибками
+func (e Errors) Fields() []string {
+	fields := make([]string, len(e))
+	for i, fieldError := range e {
+		fields[i] = fieldError.Field
+	}
+	return fields
+}
+
+// Rules настройки проверки строки
+type Rules struct {
+	MaxTextLength    int
+	MaxProjectLength int
+	// Platform возвращает название площадки по ссылке или ошибку, если площадка не поддерживается.
+	// Если не задана, площадка не проверяется
+	Platform func(link string) (string, error)
+}
+
+// DefaultRules проверки, которые не зависят от списка площадок: подходят для сохранения строки в базу
+var DefaultRules = Rules{
+	MaxTextLength:    DefaultMaxTextLength,
+	MaxProjectLength: DefaultMaxProjectLength,
+}
+
+// Validator проверяет строки таблицы и запоминает их тексты, чтобы находить повторы между строками
+type Validator struct {
+	rules Rules
+	texts map[string]string // нормализованный текст → номер строки
+}
+
+func NewValidator(rules Rules) *Validator {
+	return &Validator{
+		rules: rules,
+		texts: make(map[string]string),
+	}
+}
+
+// ValidateRow проверяет одну строку по DefaultRules без поиска повторов
+func ValidateRow(rowNumber string, obj *models.RowObject) error {
+	return NewValidator(DefaultRules).Validate(rowNumber, obj)
+}
+
+// Remember запоминает текст уже известной строки, например сохранённой ранее, для поиска повторов
+func (v *Validator) Remember(rowNumber, text string) {
+	if normalized := utils.NormalizeText(text); normalized != "" {
+		v.texts[normalized] = rowNumber
+	}
+}
+
+// Validate проверяет все поля строки и возвращает Errors со всеми найденными ошибками или nil.
+// Текст проверенной строки запоминается для поиска повторов в следующих строках
+func (v *Validator) Validate(rowNumber string, obj *models.RowObject) error {
+	var errs Errors
+	add := func(field, message string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(message, args...)})
+	}
+	if strings.TrimSpace(rowNumber) == "" {
+		add(FieldRow, "не указан номер строки")
+	}
+	if obj == nil {
+		add(FieldRow, "строка пустая")
+		return errs
+	}
+	fields := obj.Object
+
+	if obj.UserId <= 0 {
+		add(FieldUserId, "не указан пользователь, который создаёт задачу")
+	}
+
+	project := strings.TrimSpace(fields.Project)
+	if project == "" {
+		add(FieldProject, "не заполнено название проекта")
+	} else if v.rules.MaxProjectLength > 0 && utf8.RuneCountInString(project) > v.rules.MaxProjectLength {
+		add(FieldProject, "название длиннее %d символов", v.rules.MaxProjectLength)
+	}
+
+	link := strings.TrimSpace(fields.Link)
+	if link == "" {
+		add(FieldLink, "не заполнена ссылка")
+	} else if !validLink(link) {
+		add(FieldLink, "«%s» не похоже на ссылку, пример: https://yandex.ru/maps/org/123", link)
+	} else if v.rules.Platform != nil {
+		if _, err := v.rules.Platform(link); err != nil {
+			add(FieldLink, "площадка не поддерживается")
+		}
+	}
+
+	if fields.Gender != 1 && fields.Gender != 2 {
+		add(FieldGender, "укажите «м» или «ж»")
+	}
+
+	text := strings.TrimSpace(fields.TextDescription)
+	if text == "" {
+		add(FieldText, "не заполнен текст отзыва")
+	} else if v.rules.MaxTextLength > 0 && utf8.RuneCountInString(text) > v.rules.MaxTextLength {
+		add(FieldText, "текст длиннее %d символов (сейчас %d)", v.rules.MaxTextLength, utf8.RuneCountInString(text))
+	} else if normalized := utils.NormalizeText(text); normalized != "" {
+		if other, ok := v.texts[normalized]; ok && other != rowNumber {
+			add(FieldText, "такой же текст уже есть в строке %s", other)
+		} else {
+			v.texts[normalized] = rowNumber
+		}
+	}
+
+	date := strings.TrimSpace(fields.DateOfPublication)
+	if date == "" {
+		add(FieldPublication, "не заполнена дата")
+	} else if _, err := time.Parse(DateLayout, date); err != nil {
+		add(FieldPublication, "«%s» не является датой в формате ДД.ММ.ГГГГ", date)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validLink ссылка должна быть http(s) с доменом. Схему в таблице часто не пишут, тогда подразумевается https
+func validLink(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	host := parsed.Hostname()
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 || strings.ContainsAny(host, " _") {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shakirovformal/unu_project_api_realizer/pkg/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRow(t *testing.T) {
+	require.NoError(t, ValidateRow("2", models.NewRowObject(1, "Проект", "https://yandex.ru/maps/org/123", 1, "Отличный сервис", "29.02.2024")))
+	require.NoError(t, ValidateRow("2", models.NewRowObject(1, "Проект", "site.com", 2, "Отличный сервис", "01.01.2024")))
+
+	err := ValidateRow("", models.NewRowObject(0, " ", "not-a-valid-url", 3, "", "2024-13-45"))
+	require.ErrorIs(t, err, models.ErrorIncorrectData)
+	var fieldErrors Errors
+	require.ErrorAs(t, err, &fieldErrors)
+	require.Equal(t, []string{FieldRow, FieldUserId, FieldProject, FieldLink, FieldGender, FieldText, FieldPublication}, fieldErrors.Fields())
+	require.Contains(t, err.Error(), "ссылка: «not-a-valid-url» не похоже на ссылку")
+
+	err = ValidateRow("2", models.NewRowObject(1, "Проект", "site.com", 1, strings.Repeat("я", DefaultMaxTextLength+1), "30.02.2023"))
+	require.ErrorAs(t, err, &fieldErrors)
+	require.Equal(t, []string{FieldText, FieldPublication}, fieldErrors.Fields())
+
+	require.ErrorIs(t, ValidateRow("2", nil), models.ErrorIncorrectData)
+}
+
+func TestValidLink(t *testing.T) {
+	for _, link := range []string{"site.com", "https://yandex.ru/maps/org/123", "http://otzovik.com/reviews/x", "maps.app.goo.gl/abc"} {
+		require.True(t, validLink(link), link)
+	}
+	for _, link := range []string{"not-a-valid-url", "NULL", "ftp://site.com", "https://", "site..com", "-site.com", "my site.com"} {
+		require.False(t, validLink(link), link)
+	}
+}
+
+func TestValidatorPlatformAndDuplicates(t *testing.T) {
+	rules := DefaultRules
+	rules.Platform = func(link string) (string, error) {
+		if strings.Contains(link, "yandex.ru/maps") {
+			return "Яндекс Карты", nil
+		}
+		return "", errors.New("unknown")
+	}
+	validator := NewValidator(rules)
+	validator.Remember("5", "Отличный сервис, всем советую!")
+
+	err := validator.Validate("7", models.NewRowObject(1, "Проект", "https://example.com", 1, "отличный сервис всем советую", "01.01.2024"))
+	var fieldErrors Errors
+	require.ErrorAs(t, err, &fieldErrors)
+	require.Equal(t, Errors{
+		{Field: FieldLink, Message: "площадка не поддерживается"},
+		{Field: FieldText, Message: "такой же текст уже есть в строке 5"},
+	}, fieldErrors)
+
+	// Та же строка при повторной проверке повтором не считается
+	require.NoError(t, validator.Validate("5", models.NewRowObject(1, "Проект", "https://yandex.ru/maps/org/1", 1, "Отличный сервис, всем советую!", "01.01.2024")))
+	require.NoError(t, validator.Validate("8", models.NewRowObject(1, "Проект", "https://yandex.ru/maps/org/2", 1, "Другой текст", "01.01.2024")))
+	err = validator.Validate("9", models.NewRowObject(1, "Проект", "https://yandex.ru/maps/org/3", 1, "Другой текст", "01.01.2024"))
+	require.ErrorContains(t, err, "строке 8")
+}